@@ -0,0 +1,511 @@
+package cmd
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	ec2Internal "awsselfrev/internal/aws/service/ec2"
+	s3Internal "awsselfrev/internal/aws/service/s3"
+	"awsselfrev/internal/cfn"
+	"awsselfrev/internal/color"
+	"awsselfrev/internal/config"
+	"awsselfrev/internal/table"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cwltypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/spf13/cobra"
+)
+
+var cfnCmd = &cobra.Command{
+	Use:   "cfn <template|directory>...",
+	Short: "Check CloudFormation templates before deployment",
+	Long: `The "cfn" command checks the resources in CloudFormation templates (YAML or
+JSON) with the same rules as the live checks. Directories are searched for
+*.yaml, *.yml, *.json and *.template files, so a CDK cdk.out directory can be
+checked directly; files that are not templates are skipped with a warning.
+
+Short-form intrinsic functions (!Ref, !Sub, !GetAtt, ...) are accepted. A Ref
+to a template parameter uses the parameter's default value; other intrinsic
+values count as set for presence checks (e.g. KmsKeyId: !GetAtt Key.Arn) and
+as unset for boolean settings.
+
+The RESOURCE column shows the template file name and the logical ID.`,
+	Args: cobra.MinimumNArgs(1),
+	// cfn only reads the templates, so it does not need AWS credentials.
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		applyFlags(cmd)
+		if emitRemediation != "" {
+			// The resources do not exist yet; fix the templates instead.
+			log.Fatalf("--emit-remediation only works with the live checks")
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		rules := config.LoadRules()
+		tbl := table.SetTable()
+		_, _, _ = color.SetLevelColor()
+
+		templates := loadTemplates(args)
+		if len(templates) == 0 {
+			table.AddRow(tbl, []string{"CloudFormation", "-", "-", "No templates", "-", "-"})
+		}
+		for _, t := range templates {
+			checkTemplate(t, tbl, rules)
+		}
+
+		renderResults("CloudFormation", tbl, rules)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cfnCmd)
+}
+
+// loadTemplates loads the templates given as arguments. Files found by
+// walking a directory are skipped with a warning when they are not templates
+// (e.g. the manifest.json of cdk.out) or fail to parse; files named
+// explicitly must be templates.
+func loadTemplates(paths []string) []*cfn.Template {
+	var templates []*cfn.Template
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			log.Fatalf("Failed to read %s: %v", path, err)
+		}
+		if !info.IsDir() {
+			t, err := cfn.Load(path)
+			if err != nil {
+				log.Fatalf("Failed to parse template %s: %v", path, err)
+			}
+			templates = append(templates, t)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			switch strings.ToLower(filepath.Ext(file)) {
+			case ".yaml", ".yml", ".json", ".template":
+			default:
+				return nil
+			}
+			t, err := cfn.Load(file)
+			if err != nil {
+				log.Printf("Warning: Skipping %s: %v", file, err)
+				return nil
+			}
+			templates = append(templates, t)
+			return nil
+		})
+		if err != nil {
+			log.Fatalf("Failed to read %s: %v", path, err)
+		}
+	}
+	return templates
+}
+
+func checkTemplate(t *cfn.Template, tbl *table.Table, rules config.RulesConfig) {
+	for _, r := range selectedTemplateResources(t, "AWS::S3::Bucket") {
+		checkTemplateS3Bucket(r, tbl, rules)
+	}
+	for _, r := range selectedTemplateResources(t, "AWS::RDS::DBCluster") {
+		checkTemplateDBCluster(r, tbl, rules)
+	}
+	for _, r := range selectedTemplateResources(t, "AWS::RDS::DBInstance") {
+		checkTemplateDBInstance(r, tbl, rules)
+	}
+	for _, r := range selectedTemplateResources(t, "AWS::EC2::VPC") {
+		checkTemplateVPC(t, r, tbl, rules)
+	}
+	for _, r := range selectedTemplateResources(t, "AWS::ElasticLoadBalancingV2::LoadBalancer") {
+		checkTemplateLoadBalancer(r, tbl, rules)
+	}
+	for _, r := range selectedTemplateResources(t, "AWS::Logs::LogGroup") {
+		checkTemplateLogGroup(r, tbl, rules)
+	}
+	for _, r := range selectedTemplateResources(t, "AWS::ECR::Repository") {
+		checkTemplateECRRepository(r, tbl, rules)
+	}
+	for _, r := range selectedTemplateResources(t, "AWS::ECS::Cluster") {
+		checkTemplateECSCluster(r, tbl, rules)
+	}
+	for _, r := range selectedTemplateResources(t, "AWS::ECS::Service") {
+		checkTemplateECSService(r, tbl, rules)
+	}
+	for _, r := range selectedTemplateResources(t, "AWS::ECS::TaskDefinition") {
+		checkTemplateTaskDefinition(r, tbl, rules)
+	}
+}
+
+// selectedTemplateResources applies --resource-filter to the logical ID and
+// the literal tags.
+func selectedTemplateResources(t *cfn.Template, resourceType string) []*cfn.Resource {
+	var selected []*cfn.Resource
+	for _, r := range t.OfType(resourceType) {
+		if config.Resources.Match(r.LogicalID, "", r.Tags) {
+			selected = append(selected, r)
+		}
+	}
+	return selected
+}
+
+// templateName is the RESOURCE of a template resource, e.g. "app.yaml:Bucket".
+func templateName(r *cfn.Resource) string {
+	return filepath.Base(r.TemplatePath()) + ":" + r.LogicalID
+}
+
+// As with Terraform plans, template resources are converted to the API types
+// the live checks use, with the CloudFormation defaults for unset properties.
+
+func checkTemplateS3Bucket(r *cfn.Resource, tbl *table.Table, rules config.RulesConfig) {
+	resource := templateName(r)
+	name := r.String("BucketName")
+	if name == "" {
+		// Generated bucket names start with the stack name and logical ID.
+		name = strings.ToLower(r.LogicalID)
+	}
+
+	// New buckets are encrypted with SSE-S3 by default.
+	table.AddResult(tbl, rules.Get("s3-encryption"), "Pass", resource, "Enabled")
+
+	// New buckets also block all public access by default. A declared
+	// PublicAccessBlockConfiguration replaces the defaults, with unset
+	// settings off. The account-level settings are not part of the template.
+	bucketBlock := s3Internal.AllPublicAccessBlocked
+	if r.IsSet("PublicAccessBlockConfiguration") {
+		bucketBlock = s3Internal.PublicAccessBlock{
+			BlockPublicAcls:       templateBool(r, false, "PublicAccessBlockConfiguration", "BlockPublicAcls"),
+			IgnorePublicAcls:      templateBool(r, false, "PublicAccessBlockConfiguration", "IgnorePublicAcls"),
+			BlockPublicPolicy:     templateBool(r, false, "PublicAccessBlockConfiguration", "BlockPublicPolicy"),
+			RestrictPublicBuckets: templateBool(r, false, "PublicAccessBlockConfiguration", "RestrictPublicBuckets"),
+		}
+	}
+	checkPublicAccessBlock(bucketBlock, s3Internal.PublicAccessBlock{}, resource, tbl, rules)
+
+	// New buckets have ACLs disabled.
+	ownership := r.String("OwnershipControls", "Rules", "ObjectOwnership")
+	if ownership == "" {
+		ownership = string(s3types.ObjectOwnershipBucketOwnerEnforced)
+	}
+	checkObjectOwnership(ownership, resource, tbl, rules)
+
+	// Log sources are only detected in the account, so templates and plans
+	// classify buckets by name and tags.
+	logBucket := s3Internal.IsLogBucket(name, r.Tags, nil)
+	ruleLife := rules.Get("s3-lifecycle")
+	if logBucket && !r.IsSet("LifecycleConfiguration") {
+		table.AddResult(tbl, ruleLife, "Fail", resource, "Disabled")
+	} else {
+		table.AddResult(tbl, ruleLife, "Pass", resource, "Enabled")
+	}
+
+	ruleLock := rules.Get("s3-object-lock")
+	objectLock, _ := r.Bool("ObjectLockEnabled")
+	if logBucket && !objectLock {
+		table.AddResult(tbl, ruleLock, "Fail", resource, "Disabled")
+	} else {
+		table.AddResult(tbl, ruleLock, "Pass", resource, "Enabled")
+	}
+
+	ruleKms := rules.Get("s3-sse-kms-encryption")
+	sseAlgorithm := r.String("BucketEncryption", "ServerSideEncryptionConfiguration", "ServerSideEncryptionByDefault", "SSEAlgorithm")
+	if !logBucket && !strings.HasPrefix(sseAlgorithm, "aws:kms") {
+		table.AddResult(tbl, ruleKms, "Fail", resource, "Disabled")
+	} else {
+		table.AddResult(tbl, ruleKms, "Pass", resource, "Enabled")
+	}
+	bucketKey, _ := r.Bool("BucketEncryption", "ServerSideEncryptionConfiguration", "BucketKeyEnabled")
+	checkBucketKey(s3Internal.DefaultEncryption{Algorithm: sseAlgorithm, BucketKeyEnabled: bucketKey}, resource, tbl, rules)
+
+	ruleLog := rules.Get("s3-server-access-logging")
+	if !logBucket && !r.IsSet("LoggingConfiguration") {
+		table.AddResult(tbl, ruleLog, "Fail", resource, "Disabled")
+	} else {
+		table.AddResult(tbl, ruleLog, "Pass", resource, "Enabled")
+	}
+}
+
+func checkTemplateDBCluster(r *cfn.Resource, tbl *table.Table, rules config.RulesConfig) {
+	cluster := rdstypes.DBCluster{
+		DBClusterIdentifier:     aws.String(templateName(r)),
+		StorageEncrypted:        aws.Bool(templateBool(r, false, "StorageEncrypted")),
+		DeletionProtection:      aws.Bool(templateBool(r, false, "DeletionProtection")),
+		BackupRetentionPeriod:   aws.Int32(int32(templateInt(r, 1, "BackupRetentionPeriod"))),
+		DBClusterParameterGroup: aws.String(templateParameterGroup(r, "DBClusterParameterGroupName")),
+	}
+	if window := r.String("PreferredMaintenanceWindow"); window != "" {
+		cluster.PreferredMaintenanceWindow = aws.String(window)
+	}
+
+	checkStorageEncryption(cluster, tbl, rules)
+	checkDeletionProtection(cluster, tbl, rules)
+	checkClusterBackupEnabled(cluster, tbl, rules)
+	checkClusterDefaultParameterGroup(cluster, tbl, rules)
+	checkClusterMaintenanceWindow(cluster, tbl, rules)
+}
+
+func checkTemplateDBInstance(r *cfn.Resource, tbl *table.Table, rules config.RulesConfig) {
+	instance := rdstypes.DBInstance{
+		DBInstanceIdentifier:       aws.String(templateName(r)),
+		AutoMinorVersionUpgrade:    aws.Bool(templateBool(r, true, "AutoMinorVersionUpgrade")),
+		PubliclyAccessible:         aws.Bool(templateBool(r, false, "PubliclyAccessible")),
+		PerformanceInsightsEnabled: aws.Bool(templateBool(r, false, "EnablePerformanceInsights")),
+		DBParameterGroups: []rdstypes.DBParameterGroupStatus{
+			{DBParameterGroupName: aws.String(templateParameterGroup(r, "DBParameterGroupName"))},
+		},
+	}
+	if window := r.String("PreferredMaintenanceWindow"); window != "" {
+		instance.PreferredMaintenanceWindow = aws.String(window)
+	}
+
+	checkAutoMinorVersionUpgrade(instance, tbl, rules)
+	checkInstanceDefaultParameterGroup(instance, tbl, rules)
+	checkPublicAccessibility(instance, tbl, rules)
+	checkPerformanceInsights(instance, tbl, rules)
+	checkInstanceMaintenanceWindow(instance, tbl, rules)
+
+	if !r.IsSet("DBClusterIdentifier") {
+		// A standalone instance has no cluster, so the cluster-level settings
+		// are checked on the instance itself.
+		instance.StorageEncrypted = aws.Bool(templateBool(r, false, "StorageEncrypted"))
+		instance.DeletionProtection = aws.Bool(templateBool(r, false, "DeletionProtection"))
+		instance.BackupRetentionPeriod = aws.Int32(int32(templateInt(r, 1, "BackupRetentionPeriod")))
+		checkInstanceStorageEncryption(instance, tbl, rules)
+		checkInstanceDeletionProtection(instance, tbl, rules)
+		checkInstanceBackupEnabled(instance, tbl, rules)
+	}
+}
+
+// templateParameterGroup returns the parameter group name, or the default
+// group RDS assigns when the property is unset.
+func templateParameterGroup(r *cfn.Resource, property string) string {
+	if name := r.String(property); name != "" {
+		return name
+	}
+	if r.IsSet(property) {
+		return "(computed)"
+	}
+	return "default." + r.String("Engine")
+}
+
+func checkTemplateVPC(t *cfn.Template, vpc *cfn.Resource, tbl *table.Table, rules config.RulesConfig) {
+	resource := templateName(vpc)
+
+	ruleName := rules.Get("vpc-name-tag")
+	if name, ok := vpc.Tags()["Name"]; !ok {
+		table.AddResult(tbl, ruleName, "Fail", resource, "Missing")
+	} else {
+		table.AddResult(tbl, ruleName, "Pass", resource, name)
+	}
+
+	ruleDnsH := rules.Get("vpc-dns-hostname")
+	if !templateBool(vpc, false, "EnableDnsHostnames") {
+		table.AddResult(tbl, ruleDnsH, "Fail", resource, "Disabled")
+	} else {
+		table.AddResult(tbl, ruleDnsH, "Pass", resource, "Enabled")
+	}
+
+	ruleDnsS := rules.Get("vpc-dns-support")
+	if !templateBool(vpc, true, "EnableDnsSupport") {
+		table.AddResult(tbl, ruleDnsS, "Fail", resource, "Disabled")
+	} else {
+		table.AddResult(tbl, ruleDnsS, "Pass", resource, "Enabled")
+	}
+
+	var flowLogs []*cfn.Resource
+	for _, fl := range t.OfType("AWS::EC2::FlowLog") {
+		if fl.RefersTo(vpc.LogicalID, "ResourceId") {
+			flowLogs = append(flowLogs, fl)
+		}
+	}
+	ruleFlow := rules.Get("vpc-flow-logs")
+	if len(flowLogs) == 0 {
+		table.AddResult(tbl, ruleFlow, "Fail", resource, "Disabled")
+		return
+	}
+	customFormat := false
+	for _, fl := range flowLogs {
+		if ec2Internal.IsCustomFlowLogFormat(fl.String("LogFormat")) {
+			customFormat = true
+		}
+	}
+	ruleFormat := rules.Get("vpc-flow-logs-custom-format")
+	if !customFormat {
+		table.AddResult(tbl, ruleFormat, "Fail", resource, "Invalid")
+	} else {
+		table.AddResult(tbl, ruleFormat, "Pass", resource, "Valid")
+	}
+	table.AddResult(tbl, ruleFlow, "Pass", resource, "Enabled")
+}
+
+func checkTemplateLoadBalancer(r *cfn.Resource, tbl *table.Table, rules config.RulesConfig) {
+	if lbType := r.String("Type"); lbType != "" && lbType != "application" {
+		return
+	}
+
+	lb := elbtypes.LoadBalancer{LoadBalancerName: aws.String(templateName(r))}
+	attrs := &elasticloadbalancingv2.DescribeLoadBalancerAttributesOutput{}
+	for _, item := range r.List("LoadBalancerAttributes") {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		key, _ := m["Key"].(string)
+		value, _ := m["Value"].(string)
+		if b, ok := m["Value"].(bool); ok {
+			value = "false"
+			if b {
+				value = "true"
+			}
+		}
+		attrs.Attributes = append(attrs.Attributes, elbtypes.LoadBalancerAttribute{Key: aws.String(key), Value: aws.String(value)})
+	}
+
+	checkELBAccessLogs(lb, attrs, tbl, rules)
+	checkELBConnectionLogs(lb, attrs, tbl, rules)
+	checkELBDeletionProtection(lb, attrs, tbl, rules)
+}
+
+func checkTemplateLogGroup(r *cfn.Resource, tbl *table.Table, rules config.RulesConfig) {
+	logGroup := cwltypes.LogGroup{LogGroupName: aws.String(templateName(r))}
+	if days, ok := r.Int("RetentionInDays"); ok {
+		logGroup.RetentionInDays = aws.Int32(int32(days))
+	}
+	if r.IsSet("KmsKeyId") {
+		logGroup.KmsKeyId = aws.String(r.String("KmsKeyId"))
+	}
+
+	if logGroup.RetentionInDays == nil && r.IsSet("RetentionInDays") {
+		// Computed by an intrinsic function, so a retention is set.
+		table.AddResult(tbl, rules.Get("cloudwatch-retention"), "Pass", templateName(r), "(computed)")
+	} else {
+		checkLogGroupRetention(logGroup, tbl, rules)
+	}
+	checkLogGroupKmsEncryption(logGroup, tbl, rules)
+}
+
+func checkTemplateECRRepository(r *cfn.Resource, tbl *table.Table, rules config.RulesConfig) {
+	mutability := ecrtypes.ImageTagMutabilityMutable
+	if v := r.String("ImageTagMutability"); v != "" {
+		mutability = ecrtypes.ImageTagMutability(v)
+	}
+	repo := ecrtypes.Repository{
+		RepositoryName:     aws.String(templateName(r)),
+		ImageTagMutability: mutability,
+		ImageScanningConfiguration: &ecrtypes.ImageScanningConfiguration{
+			ScanOnPush: templateBool(r, false, "ImageScanningConfiguration", "ScanOnPush"),
+		},
+	}
+
+	checkTagImmutability(repo, tbl, rules)
+	checkImageScanningConfiguration(repo, tbl, rules)
+
+	rule := rules.Get("ecr-lifecycle-policy")
+	if !r.IsSet("LifecyclePolicy") {
+		table.AddResult(tbl, rule, "Fail", templateName(r), "Missing")
+	} else {
+		table.AddResult(tbl, rule, "Pass", templateName(r), "Set")
+	}
+}
+
+func checkTemplateECSCluster(r *cfn.Resource, tbl *table.Table, rules config.RulesConfig) {
+	cluster := ecstypes.Cluster{ClusterName: aws.String(templateName(r))}
+	for _, item := range r.List("ClusterSettings") {
+		if m, ok := item.(map[string]interface{}); ok {
+			name, _ := m["Name"].(string)
+			value, _ := m["Value"].(string)
+			cluster.Settings = append(cluster.Settings, ecstypes.ClusterSetting{
+				Name:  ecstypes.ClusterSettingName(name),
+				Value: aws.String(value),
+			})
+		}
+	}
+	if r.IsSet("Configuration", "ExecuteCommandConfiguration") {
+		logging := ecstypes.ExecuteCommandLoggingDefault
+		if v := r.String("Configuration", "ExecuteCommandConfiguration", "Logging"); v != "" {
+			logging = ecstypes.ExecuteCommandLogging(v)
+		}
+		cluster.Configuration = &ecstypes.ClusterConfiguration{
+			ExecuteCommandConfiguration: &ecstypes.ExecuteCommandConfiguration{Logging: logging},
+		}
+	}
+
+	checkContainerInsights(cluster, tbl, rules)
+	checkECSExecLogging(cluster, tbl, rules)
+}
+
+func checkTemplateECSService(r *cfn.Resource, tbl *table.Table, rules config.RulesConfig) {
+	propagateTags := ecstypes.PropagateTagsNone
+	if v := r.String("PropagateTags"); v != "" {
+		propagateTags = ecstypes.PropagateTags(v)
+	}
+	service := ecstypes.Service{
+		ServiceName:   aws.String(templateName(r)),
+		PropagateTags: propagateTags,
+		DeploymentConfiguration: &ecstypes.DeploymentConfiguration{
+			DeploymentCircuitBreaker: &ecstypes.DeploymentCircuitBreaker{
+				Enable: templateBool(r, false, "DeploymentConfiguration", "DeploymentCircuitBreaker", "Enable"),
+			},
+		},
+	}
+
+	checkCircuitBreaker(service, tbl, rules)
+	checkPropagateTags(service, tbl, rules)
+}
+
+func checkTemplateTaskDefinition(r *cfn.Resource, tbl *table.Table, rules config.RulesConfig) {
+	td := &ecstypes.TaskDefinition{}
+	if arch := r.String("RuntimePlatform", "CpuArchitecture"); arch != "" {
+		td.RuntimePlatform = &ecstypes.RuntimePlatform{CpuArchitecture: ecstypes.CPUArchitecture(arch)}
+	}
+	for _, item := range r.List("ContainerDefinitions") {
+		c, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := c["Name"].(string)
+		container := ecstypes.ContainerDefinition{Name: aws.String(name)}
+		envs, _ := c["Environment"].([]interface{})
+		for _, e := range envs {
+			if env, ok := e.(map[string]interface{}); ok {
+				envName, _ := env["Name"].(string)
+				envValue, _ := env["Value"].(string)
+				container.Environment = append(container.Environment, ecstypes.KeyValuePair{
+					Name:  aws.String(envName),
+					Value: aws.String(envValue),
+				})
+			}
+		}
+		td.ContainerDefinitions = append(td.ContainerDefinitions, container)
+	}
+
+	checkCpuArchitecture(td, aws.String(templateName(r)), tbl, rules)
+	checkSensitiveEnvironmentVariables(td, aws.String(templateName(r)), tbl, rules)
+}
+
+// templateBool returns a boolean property, or def when it is unset or
+// computed by an intrinsic function.
+func templateBool(r *cfn.Resource, def bool, path ...string) bool {
+	if v, ok := r.Bool(path...); ok {
+		return v
+	}
+	return def
+}
+
+// templateInt returns a number property, or def when it is unset or
+// computed by an intrinsic function.
+func templateInt(r *cfn.Resource, def int, path ...string) int {
+	if v, ok := r.Int(path...); ok {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"awsselfrev/internal/cfn"
+	"awsselfrev/internal/config"
+	"awsselfrev/internal/table"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const intrinsicTemplate = `
+Parameters:
+  Public:
+    Type: String
+    Default: "true"
+  Retention:
+    Type: Number
+    Default: 14
+  KeyArn:
+    Type: String
+Conditions:
+  IsProd: !Equals [!Ref AWS::AccountId, "111122223333"]
+Resources:
+  PublicDb:
+    Type: AWS::RDS::DBInstance
+    Properties:
+      Engine: mysql
+      PubliclyAccessible: !Ref Public
+      DeletionProtection: true
+      StorageEncrypted: true
+  ConditionalDb:
+    Type: AWS::RDS::DBInstance
+    Properties:
+      Engine: mysql
+      DBClusterIdentifier: !Ref Cluster
+      PubliclyAccessible: !If [IsProd, false, true]
+  Logs:
+    Type: AWS::Logs::LogGroup
+    Properties:
+      LogGroupName: !Sub "/app/${AWS::StackName}"
+      RetentionInDays: !Ref Retention
+      KmsKeyId: !Ref KeyArn
+  ConditionalLogs:
+    Type: AWS::Logs::LogGroup
+    Properties:
+      RetentionInDays: !If [IsProd, 365, 7]
+  Task:
+    Type: AWS::ECS::TaskDefinition
+    Properties:
+      RuntimePlatform:
+        CpuArchitecture: ARM64
+      ContainerDefinitions:
+        - Name: app
+          Environment:
+            - Name: DB_PASSWORD
+              Value: !Sub "{{resolve:ssm:/${AWS::StackName}/db}}"
+            - Name: LOG_LEVEL
+              Value: info
+`
+
+func TestCheckTemplateIntrinsicFunctions(t *testing.T) {
+	tmpl, err := cfn.Parse([]byte(intrinsicTemplate))
+	if !assert.NoError(t, err) {
+		return
+	}
+	tmpl.Path = "app.yaml"
+
+	rules := rdsTestRules()
+	for _, key := range []string{"cloudwatch-retention", "cloudwatch-log-group-encryption"} {
+		rules.Rules[key] = config.Rule{Service: "CloudWatchLogs", Level: "Warning"}
+	}
+	for _, key := range []string{"ecs-cpu-architecture", "ecs-sensitive-environment-variables"} {
+		rules.Rules[key] = config.Rule{Service: "ECS", Level: "Warning"}
+	}
+
+	tbl := table.SetTable()
+	checkTemplate(tmpl, tbl, rules)
+
+	results := make(map[string][]string)
+	for _, row := range tbl.Rows() {
+		switch row.RuleKey {
+		case "rds-public-access", "rds-deletion-protection", "cloudwatch-retention",
+			"cloudwatch-log-group-encryption", "ecs-sensitive-environment-variables":
+			results[row.Resource] = append(results[row.Resource], row.RuleKey+" "+row.Status+" "+row.Setting)
+		}
+	}
+
+	assert.Equal(t, map[string][]string{
+		// !Ref to a parameter uses its default.
+		"app.yaml:PublicDb": {
+			"rds-public-access Fail Public",
+			"rds-deletion-protection Pass Enabled",
+		},
+		// !If is not evaluated, so the boolean falls back to the RDS default;
+		// a cluster member has no deletion protection row.
+		"app.yaml:ConditionalDb": {
+			"rds-public-access Pass Private",
+		},
+		// RetentionInDays resolves to the parameter default; the key ARN
+		// parameter has no default but counts as set.
+		"app.yaml:Logs": {
+			"cloudwatch-retention Pass 14 days",
+			"cloudwatch-log-group-encryption Pass Enabled",
+		},
+		"app.yaml:ConditionalLogs": {
+			"cloudwatch-retention Pass (computed)",
+			"cloudwatch-log-group-encryption Fail Disabled",
+		},
+		// The variable name decides, whatever Fn::Sub computes as the value.
+		"app.yaml:Task": {
+			"ecs-sensitive-environment-variables Fail Found: DB_PASSWORD",
+		},
+	}, results)
+}
+
+func TestCheckTemplateS3BucketDefaults(t *testing.T) {
+	tmpl, err := cfn.Parse([]byte(`
+Resources:
+  Assets:
+    Type: AWS::S3::Bucket
+  Partial:
+    Type: AWS::S3::Bucket
+    Properties:
+      PublicAccessBlockConfiguration:
+        BlockPublicAcls: true
+      OwnershipControls:
+        Rules:
+          - ObjectOwnership: ObjectWriter
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	tmpl.Path = "app.yaml"
+
+	tbl := table.SetTable()
+	checkTemplate(tmpl, tbl, s3TestRules())
+
+	results := make(map[string][]string)
+	for _, row := range tbl.Rows() {
+		switch row.RuleKey {
+		case "s3-encryption", "s3-public-access", "s3-object-ownership":
+			results[row.Resource] = append(results[row.Resource], row.RuleKey+" "+row.Status+" "+row.Setting)
+		}
+	}
+
+	assert.Equal(t, map[string][]string{
+		// Undeclared settings take the defaults of new buckets.
+		"app.yaml:Assets": {
+			"s3-encryption Pass Enabled",
+			"s3-public-access Pass Enabled",
+			"s3-object-ownership Pass BucketOwnerEnforced",
+		},
+		"app.yaml:Partial": {
+			"s3-encryption Pass Enabled",
+			"s3-public-access Fail Off: IgnorePublicAcls, BlockPublicPolicy, RestrictPublicBuckets",
+			"s3-object-ownership Fail ObjectWriter",
+		},
+	}, results)
+}
@@ -2,15 +2,16 @@ package cmd
 
 import (
 	"context"
-	"log"
 
 	"awsselfrev/internal/aws/api"
 	"awsselfrev/internal/color"
 	"awsselfrev/internal/config"
+	"awsselfrev/internal/inventory"
 	"awsselfrev/internal/table"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
-	"github.com/olekukonko/tablewriter"
+	cftypes "github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
 	"github.com/spf13/cobra"
 )
 
@@ -28,7 +29,7 @@ var cloudfrontCmd = &cobra.Command{
 
 		checkCloudFrontConfigurations(client, tbl, rules)
 
-		table.Render("CloudFront", tbl)
+		renderResults("CloudFront", tbl, rules)
 	},
 }
 
@@ -36,10 +37,13 @@ func init() {
 	rootCmd.AddCommand(cloudfrontCmd)
 }
 
-func checkCloudFrontConfigurations(client api.CloudFrontClient, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkCloudFrontConfigurations(client api.CloudFrontClient, tbl *table.Table, rules config.RulesConfig) {
+	if !needsService(rules, "CloudFront") {
+		return
+	}
 	resp, err := client.ListDistributions(context.TODO(), &cloudfront.ListDistributionsInput{})
 	if err != nil {
-		log.Fatalf("Failed to list CloudFront distributions: %v", err)
+		fatalf("Failed to list CloudFront distributions: %v", err)
 	}
 
 	if resp.DistributionList == nil || len(resp.DistributionList.Items) == 0 {
@@ -49,13 +53,29 @@ func checkCloudFrontConfigurations(client api.CloudFrontClient, tbl *tablewriter
 
 	if resp.DistributionList != nil {
 		for _, distSummary := range resp.DistributionList.Items {
+			if !config.Resources.Match(aws.ToString(distSummary.Id), aws.ToString(distSummary.ARN), cloudFrontTags(client, distSummary.ARN)) {
+				continue
+			}
 			checkLoggingEnabled(client, distSummary.Id, tbl, rules)
+			collectResource(tbl, rules, inventory.TypeCloudFrontDistribution, aws.ToString(distSummary.Id), aws.ToString(distSummary.ARN), distSummary)
 		}
 	}
 }
 
+func cloudFrontTags(client api.CloudFrontClient, arn *string) func() map[string]string {
+	return lazyTags("CloudFront distribution "+aws.ToString(arn), func() (map[string]string, error) {
+		resp, err := client.ListTagsForResource(context.TODO(), &cloudfront.ListTagsForResourceInput{
+			Resource: arn,
+		})
+		if err != nil || resp.Tags == nil {
+			return nil, err
+		}
+		return tagMap(resp.Tags.Items, func(t cftypes.Tag) (*string, *string) { return t.Key, t.Value }), nil
+	})
+}
+
 // checkLoggingEnabled checks if either Standard Logging or Real-time Logging is enabled using GetDistributionConfig
-func checkLoggingEnabled(client api.CloudFrontClient, distID *string, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkLoggingEnabled(client api.CloudFrontClient, distID *string, tbl *table.Table, rules config.RulesConfig) {
 	if distID == nil {
 		return
 	}
@@ -64,7 +84,7 @@ func checkLoggingEnabled(client api.CloudFrontClient, distID *string, tbl *table
 		Id: distID,
 	})
 	if err != nil {
-		log.Fatalf("Failed to get CloudFront distribution config for %s: %v", *distID, err)
+		fatalf("Failed to get CloudFront distribution config for %s: %v", *distID, err)
 	}
 
 	distConfig := configResp.DistributionConfig
@@ -97,8 +117,8 @@ func checkLoggingEnabled(client api.CloudFrontClient, distID *string, tbl *table
 
 	rule := rules.Get("cloudfront-logging-enabled")
 	if !standardLoggingEnabled && !realtimeLoggingEnabled {
-		table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), *distID, "Disabled", rule.Issue})
+		table.AddResult(tbl, rule, "Fail", *distID, "Disabled")
 	} else {
-		table.AddRow(tbl, []string{rule.Service, "Pass", "-", *distID, "Enabled", rule.Issue})
+		table.AddResult(tbl, rule, "Pass", *distID, "Enabled")
 	}
 }
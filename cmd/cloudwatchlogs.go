@@ -3,16 +3,16 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"log"
 
 	"awsselfrev/internal/aws/api"
 	"awsselfrev/internal/color"
 	"awsselfrev/internal/config"
+	"awsselfrev/internal/inventory"
 	"awsselfrev/internal/table"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
-	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 )
 
@@ -30,41 +30,60 @@ var cloudwatchlogsCmd = &cobra.Command{
 
 		checkCloudWatchLogsConfigurations(client, tbl, rules)
 
-		table.Render("CloudWatchLogs", tbl)
+		renderResults("CloudWatchLogs", tbl, rules)
 	},
 }
 
-func checkCloudWatchLogsConfigurations(client api.CloudWatchLogsClient, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkCloudWatchLogsConfigurations(client api.CloudWatchLogsClient, tbl *table.Table, rules config.RulesConfig) {
+	if !needsService(rules, "CloudWatchLogs") {
+		return
+	}
 	resp, err := client.DescribeLogGroups(context.TODO(), &cloudwatchlogs.DescribeLogGroupsInput{})
 	if err != nil {
-		log.Fatalf("Failed to describe log groups: %v", err)
+		fatalf("Failed to describe log groups: %v", err)
 	}
 	if len(resp.LogGroups) == 0 {
 		table.AddRow(tbl, []string{"CloudWatchLogs", "-", "-", "No log groups", "-", "-"})
 		return
 	}
 	for _, logGroup := range resp.LogGroups {
+		if !config.Resources.Match(*logGroup.LogGroupName, aws.ToString(logGroup.LogGroupArn), logGroupTags(client, logGroup.LogGroupArn)) {
+			continue
+		}
 		checkLogGroupRetention(logGroup, tbl, rules)
 		checkLogGroupKmsEncryption(logGroup, tbl, rules)
+		collectResource(tbl, rules, inventory.TypeLogGroup, *logGroup.LogGroupName, aws.ToString(logGroup.LogGroupArn), logGroup)
 	}
 }
 
-func checkLogGroupRetention(logGroup types.LogGroup, tbl *tablewriter.Table, rules config.RulesConfig) {
+func logGroupTags(client api.CloudWatchLogsClient, arn *string) func() map[string]string {
+	return lazyTags("log group "+aws.ToString(arn), func() (map[string]string, error) {
+		resp, err := client.ListTagsForResource(context.TODO(), &cloudwatchlogs.ListTagsForResourceInput{
+			ResourceArn: arn,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return resp.Tags, nil
+	})
+}
+
+func checkLogGroupRetention(logGroup types.LogGroup, tbl *table.Table, rules config.RulesConfig) {
 	rule := rules.Get("cloudwatch-retention")
 	if logGroup.RetentionInDays == nil {
-		table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), *logGroup.LogGroupName, "Never", rule.Issue})
+		table.AddResult(tbl, rule, "Fail", *logGroup.LogGroupName, "Never")
 	} else {
 		val := fmt.Sprintf("%d days", *logGroup.RetentionInDays)
-		table.AddRow(tbl, []string{rule.Service, "Pass", "-", *logGroup.LogGroupName, val, rule.Issue})
+		table.AddResult(tbl, rule, "Pass", *logGroup.LogGroupName, val)
 	}
 }
 
-func checkLogGroupKmsEncryption(logGroup types.LogGroup, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkLogGroupKmsEncryption(logGroup types.LogGroup, tbl *table.Table, rules config.RulesConfig) {
 	rule := rules.Get("cloudwatch-log-group-encryption")
 	if logGroup.KmsKeyId == nil {
-		table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), *logGroup.LogGroupName, "Disabled", rule.Issue})
+		table.AddResult(tbl, rule, "Fail", *logGroup.LogGroupName, "Disabled")
 	} else {
-		table.AddRow(tbl, []string{rule.Service, "Pass", "-", *logGroup.LogGroupName, "Enabled", rule.Issue})
+		table.AddResult(tbl, rule, "Pass", *logGroup.LogGroupName, "Enabled")
 	}
 }
 
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"log"
+
+	"awsselfrev/internal/config"
+	"awsselfrev/internal/custom"
+	"awsselfrev/internal/inventory"
+	"awsselfrev/internal/policy"
+	"awsselfrev/internal/table"
+)
+
+// policyPaths holds the Rego files or directories from --policy.
+var policyPaths []string
+
+// recordInventory keeps every collected resource, for the inventory command.
+var recordInventory bool
+
+// collected holds the resource documents gathered since the last render,
+// for evaluation against the Rego policies and for the inventory command.
+var collected []inventory.Resource
+
+// seen holds the resources already collected, so a resource reached through
+// several parents (e.g. a task definition shared by services) is collected once.
+var seen = make(map[string]bool)
+
+// collectResource hands a selected resource to the custom rules in rules.yaml
+// and, with --policy or for the inventory command, keeps it. v is the API
+// object describing the resource; it is only normalized into a document when
+// needed. The check loops call it for each resource they reach, so collection
+// shares their API calls and stops when they do.
+func collectResource(tbl *table.Table, rules config.RulesConfig, resourceType string, name string, arn string, v interface{}) {
+	id := resourceType + "/" + name + "/" + arn
+	if seen[id] {
+		return
+	}
+	seen[id] = true
+
+	keep := len(policyPaths) > 0 || recordInventory
+	targeted := custom.Targets(rules, resourceType)
+	if !targeted && !keep {
+		return
+	}
+	res := inventory.New(resourceType, name, arn, v)
+	if keep {
+		collected = append(collected, res)
+	}
+	if !targeted {
+		return
+	}
+	results, err := custom.Evaluate(rules, res)
+	if err != nil {
+		log.Printf("Warning: Failed to evaluate custom rules: %v", err)
+	}
+	for _, result := range results {
+		table.AddResult(tbl, result.Rule, result.Status, res.Name, result.Setting)
+	}
+}
+
+// needs reports whether a check has to fetch its data: one of its rules is
+// enabled, or the resource documents are collected, which hold every attribute
+// the checks read. Checks of rules left out by --rules, --exclude-rules or
+// --min-level make no API calls.
+func needs(rules config.RulesConfig, keys ...string) bool {
+	if collectsDocuments(rules) {
+		return true
+	}
+	for _, key := range keys {
+		if rules.Get(key).IsEnabled() {
+			return true
+		}
+	}
+	return false
+}
+
+// needsService reports whether the checks of service have to run at all.
+func needsService(rules config.RulesConfig, service string) bool {
+	if collectsDocuments(rules) {
+		return true
+	}
+	for _, rule := range rules.Rules {
+		if rule.Service == service && !rule.IsCustom() && rule.IsEnabled() {
+			return true
+		}
+	}
+	return false
+}
+
+// collectsDocuments reports whether the collected resources are used: by an
+// enabled custom rule, the Rego policies or the inventory command.
+func collectsDocuments(rules config.RulesConfig) bool {
+	if len(policyPaths) > 0 || recordInventory {
+		return true
+	}
+	for _, rule := range rules.Rules {
+		if rule.IsCustom() && rule.IsEnabled() {
+			return true
+		}
+	}
+	return false
+}
+
+// renderResults finishes the results and renders the table.
+func renderResults(serviceName string, tbl *table.Table, rules config.RulesConfig) {
+	finishResults(serviceName, tbl, rules)
+	table.Render(serviceName, tbl)
+}
+
+// finishResults evaluates the Rego policies against the collected resources,
+// adds their findings, writes the --emit-remediation file, records the run
+// with --record-history and posts the --notify digests.
+func finishResults(serviceName string, tbl *table.Table, rules config.RulesConfig) {
+	if len(policyPaths) > 0 {
+		evaluatePolicies(tbl, rules)
+	}
+	resetCollected()
+	if emitRemediation != "" {
+		writeRemediation(tbl, rules)
+	}
+	// Offline checks (tf-plan, cfn) have no account and are not recorded.
+	if recordHistory && AccountID != "" {
+		appendHistory(serviceName, tbl)
+	}
+	if len(notifiers) > 0 {
+		sendNotifications(serviceName, tbl)
+	}
+}
+
+func resetCollected() {
+	collected = nil
+	seen = make(map[string]bool)
+}
+
+func evaluatePolicies(tbl *table.Table, rules config.RulesConfig) {
+	findings, err := policy.Evaluate(policyPaths, collected)
+	if err != nil {
+		fatalf("Failed to evaluate policies: %v", err)
+	}
+
+	for _, f := range findings {
+		if _, ok := rules.Rules[f.Rule]; !ok {
+			fatalf("Policy finding refers to rule %q, which is missing from rules.yaml", f.Rule)
+		}
+		table.AddResult(tbl, rules.Get(f.Rule), f.Status, f.Resource, f.Setting)
+	}
+}
@@ -7,11 +7,11 @@ import (
 	ec2Internal "awsselfrev/internal/aws/service/ec2"
 	"awsselfrev/internal/color"
 	"awsselfrev/internal/config"
+	"awsselfrev/internal/inventory"
 	"awsselfrev/internal/table"
-	"log"
 
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
-	"github.com/olekukonko/tablewriter"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/spf13/cobra"
 )
 
@@ -31,62 +31,92 @@ var ec2Cmd = &cobra.Command{
 
 		checkEC2Configurations(client, tbl, rules)
 
-		table.Render("EC2", tbl)
+		renderResults("EC2", tbl, rules)
 	},
 }
 
-func checkEC2Configurations(client api.EC2Client, tbl *tablewriter.Table, rules config.RulesConfig) {
-	// 1. EBS Default Encryption
-	ebsEncryptionEnabled, err := ec2Internal.IsEbsDefaultEncryptionEnabled(client)
-	if err != nil {
-		log.Fatalf("Failed to check EBS default encryption: %v", err)
+func checkEC2Configurations(client api.EC2Client, tbl *table.Table, rules config.RulesConfig) {
+	if !needsService(rules, "EC2") {
+		return
 	}
-	ruleEbs := rules.Get("ec2-ebs-default-encryption")
-	if !ebsEncryptionEnabled {
-		table.AddRow(tbl, []string{ruleEbs.Service, "Fail", color.ColorizeLevel(ruleEbs.Level), "-", "Disabled", ruleEbs.Issue})
-	} else {
-		table.AddRow(tbl, []string{ruleEbs.Service, "Pass", "-", "-", "Enabled", ruleEbs.Issue})
+	// 1. EBS Default Encryption
+	if needs(rules, "ec2-ebs-default-encryption") {
+		ebsEncryptionEnabled, err := ec2Internal.IsEbsDefaultEncryptionEnabled(client)
+		if err != nil {
+			fatalf("Failed to check EBS default encryption: %v", err)
+		}
+		ruleEbs := rules.Get("ec2-ebs-default-encryption")
+		if !ebsEncryptionEnabled {
+			table.AddResult(tbl, ruleEbs, "Fail", "-", "Disabled")
+		} else {
+			table.AddResult(tbl, ruleEbs, "Pass", "-", "Enabled")
+		}
 	}
 
 	// 2. Volume Encryption
+	if needs(rules, "ec2-volume-encryption") {
+		checkVolumeEncryption(client, tbl, rules)
+	}
+
+	// 3. Snapshot Encryption
+	if needs(rules, "ec2-snapshot-encryption") {
+		checkSnapshotEncryption(client, tbl, rules)
+	}
+}
+
+func checkVolumeEncryption(client api.EC2Client, tbl *table.Table, rules config.RulesConfig) {
 	volumesResp, err := client.DescribeVolumes(context.TODO(), &ec2.DescribeVolumesInput{})
 	if err != nil {
-		log.Fatalf("Failed to describe volumes: %v", err)
+		fatalf("Failed to describe volumes: %v", err)
 	}
 	ruleVol := rules.Get("ec2-volume-encryption")
 	if len(volumesResp.Volumes) == 0 {
-		table.AddRow(tbl, []string{ruleVol.Service, "Pass", "-", "No volumes", "-", ruleVol.Issue})
+		table.AddResult(tbl, ruleVol, "Pass", "No volumes", "-")
 	} else {
 		for _, v := range volumesResp.Volumes {
+			if !config.Resources.Match(*v.VolumeId, "", tagsOf(v.Tags, ec2Tag)) {
+				continue
+			}
 			if !*v.Encrypted {
-				table.AddRow(tbl, []string{ruleVol.Service, "Fail", color.ColorizeLevel(ruleVol.Level), *v.VolumeId, "Disabled", ruleVol.Issue})
+				table.AddResult(tbl, ruleVol, "Fail", *v.VolumeId, "Disabled")
 			} else {
-				table.AddRow(tbl, []string{ruleVol.Service, "Pass", "-", *v.VolumeId, "Enabled", ruleVol.Issue})
+				table.AddResult(tbl, ruleVol, "Pass", *v.VolumeId, "Enabled")
 			}
+			collectResource(tbl, rules, inventory.TypeEC2Volume, *v.VolumeId, "", v)
 		}
 	}
+}
 
-	// 3. Snapshot Encryption
+func checkSnapshotEncryption(client api.EC2Client, tbl *table.Table, rules config.RulesConfig) {
 	snapshotsResp, err := client.DescribeSnapshots(context.TODO(), &ec2.DescribeSnapshotsInput{
 		OwnerIds: []string{"self"},
 	})
 	if err != nil {
-		log.Fatalf("Failed to describe snapshots: %v", err)
+		fatalf("Failed to describe snapshots: %v", err)
 	}
 	ruleSnap := rules.Get("ec2-snapshot-encryption")
 	if len(snapshotsResp.Snapshots) == 0 {
-		table.AddRow(tbl, []string{ruleSnap.Service, "Pass", "-", "No snapshots", "-", ruleSnap.Issue})
+		table.AddResult(tbl, ruleSnap, "Pass", "No snapshots", "-")
 	} else {
 		for _, s := range snapshotsResp.Snapshots {
+			if !config.Resources.Match(*s.SnapshotId, "", tagsOf(s.Tags, ec2Tag)) {
+				continue
+			}
 			if !*s.Encrypted {
-				table.AddRow(tbl, []string{ruleSnap.Service, "Fail", color.ColorizeLevel(ruleSnap.Level), *s.SnapshotId, "Disabled", ruleSnap.Issue})
+				table.AddResult(tbl, ruleSnap, "Fail", *s.SnapshotId, "Disabled")
 			} else {
-				table.AddRow(tbl, []string{ruleSnap.Service, "Pass", "-", *s.SnapshotId, "Enabled", ruleSnap.Issue})
+				table.AddResult(tbl, ruleSnap, "Pass", *s.SnapshotId, "Enabled")
 			}
+			collectResource(tbl, rules, inventory.TypeEC2Snapshot, *s.SnapshotId, "", s)
 		}
 	}
 }
 
+// ec2Tag reads an EC2 tag for tagsOf.
+func ec2Tag(t types.Tag) (*string, *string) {
+	return t.Key, t.Value
+}
+
 func init() {
 	rootCmd.AddCommand(ec2Cmd)
 }
@@ -3,18 +3,17 @@ package cmd
 import (
 	"context"
 	"errors"
-	"log"
 
 	"awsselfrev/internal/aws/api"
 	"awsselfrev/internal/color"
 	"awsselfrev/internal/config"
+	"awsselfrev/internal/inventory"
 	"awsselfrev/internal/table"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/service/ecr"
 	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
-	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 )
 
@@ -34,7 +33,7 @@ var ecrCmd = &cobra.Command{
 
 		checkECRConfigurations(client, tbl, rules)
 
-		table.Render("ECR", tbl)
+		renderResults("ECR", tbl, rules)
 	},
 }
 
@@ -42,12 +41,15 @@ func init() {
 	rootCmd.AddCommand(ecrCmd)
 }
 
-func checkECRConfigurations(client api.ECRClient, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkECRConfigurations(client api.ECRClient, tbl *table.Table, rules config.RulesConfig) {
+	if !needsService(rules, "ECR") {
+		return
+	}
 	resp, err := client.DescribeRepositories(context.TODO(), &ecr.DescribeRepositoriesInput{
 		MaxResults: aws.Int32(100),
 	})
 	if err != nil {
-		log.Fatalf("Failed to describe ECR repositories: %v", err)
+		fatalf("Failed to describe ECR repositories: %v", err)
 	}
 
 	if len(resp.Repositories) == 0 {
@@ -56,31 +58,49 @@ func checkECRConfigurations(client api.ECRClient, tbl *tablewriter.Table, rules
 	}
 
 	for _, repo := range resp.Repositories {
+		if !config.Resources.Match(*repo.RepositoryName, aws.ToString(repo.RepositoryArn), ecrTags(client, repo.RepositoryArn)) {
+			continue
+		}
 		checkTagImmutability(repo, tbl, rules)
 		checkImageScanningConfiguration(repo, tbl, rules)
-		checkLifecyclePolicy(client, *repo.RepositoryName, tbl, rules)
+		if needs(rules, "ecr-lifecycle-policy") {
+			checkLifecyclePolicy(client, *repo.RepositoryName, tbl, rules)
+		}
+		collectResource(tbl, rules, inventory.TypeECRRepository, *repo.RepositoryName, aws.ToString(repo.RepositoryArn), repo)
 	}
 }
 
-func checkTagImmutability(repo types.Repository, tbl *tablewriter.Table, rules config.RulesConfig) {
+func ecrTags(client api.ECRClient, arn *string) func() map[string]string {
+	return lazyTags("repository "+aws.ToString(arn), func() (map[string]string, error) {
+		resp, err := client.ListTagsForResource(context.TODO(), &ecr.ListTagsForResourceInput{
+			ResourceArn: arn,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return tagMap(resp.Tags, func(t types.Tag) (*string, *string) { return t.Key, t.Value }), nil
+	})
+}
+
+func checkTagImmutability(repo types.Repository, tbl *table.Table, rules config.RulesConfig) {
 	rule := rules.Get("ecr-tag-immutability")
 	if repo.ImageTagMutability == types.ImageTagMutabilityMutable {
-		table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), *repo.RepositoryName, "Mutable", rule.Issue})
+		table.AddResult(tbl, rule, "Fail", *repo.RepositoryName, "Mutable")
 	} else {
-		table.AddRow(tbl, []string{rule.Service, "Pass", "-", *repo.RepositoryName, "Immutable", rule.Issue})
+		table.AddResult(tbl, rule, "Pass", *repo.RepositoryName, "Immutable")
 	}
 }
 
-func checkImageScanningConfiguration(repo types.Repository, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkImageScanningConfiguration(repo types.Repository, tbl *table.Table, rules config.RulesConfig) {
 	rule := rules.Get("ecr-image-scanning")
 	if !repo.ImageScanningConfiguration.ScanOnPush {
-		table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), *repo.RepositoryName, "Disabled", rule.Issue})
+		table.AddResult(tbl, rule, "Fail", *repo.RepositoryName, "Disabled")
 	} else {
-		table.AddRow(tbl, []string{rule.Service, "Pass", "-", *repo.RepositoryName, "Enabled", rule.Issue})
+		table.AddResult(tbl, rule, "Pass", *repo.RepositoryName, "Enabled")
 	}
 }
 
-func checkLifecyclePolicy(client api.ECRClient, repoName string, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkLifecyclePolicy(client api.ECRClient, repoName string, tbl *table.Table, rules config.RulesConfig) {
 	_, err := client.GetLifecyclePolicy(context.TODO(), &ecr.GetLifecyclePolicyInput{
 		RepositoryName: aws.String(repoName),
 	})
@@ -88,11 +108,11 @@ func checkLifecyclePolicy(client api.ECRClient, repoName string, tbl *tablewrite
 	if err != nil {
 		var re *awshttp.ResponseError
 		if errors.As(err, &re) && re.HTTPStatusCode() == 400 {
-			table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), repoName, "Missing", rule.Issue})
+			table.AddResult(tbl, rule, "Fail", repoName, "Missing")
 		} else {
-			log.Fatalf("Failed to describe lifecycle policy for repository %s: %v", repoName, err)
+			fatalf("Failed to describe lifecycle policy for repository %s: %v", repoName, err)
 		}
 	} else {
-		table.AddRow(tbl, []string{rule.Service, "Pass", "-", repoName, "Set", rule.Issue})
+		table.AddResult(tbl, rule, "Pass", repoName, "Set")
 	}
 }
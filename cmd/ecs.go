@@ -8,12 +8,13 @@ import (
 	"awsselfrev/internal/aws/api"
 	"awsselfrev/internal/color"
 	"awsselfrev/internal/config"
+	"awsselfrev/internal/inventory"
 	"awsselfrev/internal/table"
 	"strings"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
 	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
-	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 )
 
@@ -33,7 +34,7 @@ var ecsCmd = &cobra.Command{
 
 		checkECSConfigurations(client, tbl, rules)
 
-		table.Render("ECS", tbl)
+		renderResults("ECS", tbl, rules)
 	},
 }
 
@@ -41,11 +42,14 @@ func init() {
 	rootCmd.AddCommand(ecsCmd)
 }
 
-func checkECSConfigurations(client api.ECSClient, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkECSConfigurations(client api.ECSClient, tbl *table.Table, rules config.RulesConfig) {
+	if !needsService(rules, "ECS") {
+		return
+	}
 	// 1. Check Clusters
 	listResp, err := client.ListClusters(context.TODO(), &ecs.ListClustersInput{})
 	if err != nil {
-		log.Fatalf("Failed to list ECS clusters: %v", err)
+		fatalf("Failed to list ECS clusters: %v", err)
 	}
 
 	if len(listResp.ClusterArns) == 0 {
@@ -54,22 +58,33 @@ func checkECSConfigurations(client api.ECSClient, tbl *tablewriter.Table, rules
 	}
 
 	if len(listResp.ClusterArns) > 0 {
-		descResp, err := client.DescribeClusters(context.TODO(), &ecs.DescribeClustersInput{
+		input := &ecs.DescribeClustersInput{
 			Clusters: listResp.ClusterArns,
-		})
+		}
+		if config.Resources.HasTagSelectors() {
+			input.Include = []types.ClusterField{types.ClusterFieldTags}
+		}
+		descResp, err := client.DescribeClusters(context.TODO(), input)
 		if err != nil {
-			log.Fatalf("Failed to describe ECS clusters: %v", err)
+			fatalf("Failed to describe ECS clusters: %v", err)
 		}
 
 		for _, cluster := range descResp.Clusters {
-			checkContainerInsights(cluster, tbl, rules)
-			checkECSExecLogging(cluster, tbl, rules)
-			checkServices(client, *cluster.ClusterArn, *cluster.ClusterName, tbl, rules)
+			// Services of a selected cluster are selected too.
+			selected := config.Resources.Match(*cluster.ClusterName, *cluster.ClusterArn, tagsOf(cluster.Tags, ecsTag))
+			if selected {
+				checkContainerInsights(cluster, tbl, rules)
+				checkECSExecLogging(cluster, tbl, rules)
+				collectResource(tbl, rules, inventory.TypeECSCluster, *cluster.ClusterName, *cluster.ClusterArn, cluster)
+			}
+			if needs(rules, "ecs-service-circuit-breaker", "ecs-cpu-architecture", "ecs-sensitive-environment-variables", "ecs-propagate-tags") {
+				checkServices(client, *cluster.ClusterArn, *cluster.ClusterName, selected, tbl, rules)
+			}
 		}
 	}
 }
 
-func checkContainerInsights(cluster types.Cluster, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkContainerInsights(cluster types.Cluster, tbl *table.Table, rules config.RulesConfig) {
 	enabled := false
 	for _, setting := range cluster.Settings {
 		if setting.Name == types.ClusterSettingNameContainerInsights && setting.Value != nil && *setting.Value == "enabled" {
@@ -80,13 +95,13 @@ func checkContainerInsights(cluster types.Cluster, tbl *tablewriter.Table, rules
 
 	rule := rules.Get("ecs-container-insights")
 	if !enabled {
-		table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), *cluster.ClusterName, "Disabled", rule.Issue})
+		table.AddResult(tbl, rule, "Fail", *cluster.ClusterName, "Disabled")
 	} else {
-		table.AddRow(tbl, []string{rule.Service, "Pass", "-", *cluster.ClusterName, "Enabled", rule.Issue})
+		table.AddResult(tbl, rule, "Pass", *cluster.ClusterName, "Enabled")
 	}
 }
 
-func checkECSExecLogging(cluster types.Cluster, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkECSExecLogging(cluster types.Cluster, tbl *table.Table, rules config.RulesConfig) {
 	enabled := false
 	if cluster.Configuration != nil && cluster.Configuration.ExecuteCommandConfiguration != nil {
 		conf := cluster.Configuration.ExecuteCommandConfiguration
@@ -97,49 +112,64 @@ func checkECSExecLogging(cluster types.Cluster, tbl *tablewriter.Table, rules co
 
 	rule := rules.Get("ecs-exec-logging")
 	if !enabled {
-		table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), *cluster.ClusterName, "Disabled", rule.Issue})
+		table.AddResult(tbl, rule, "Fail", *cluster.ClusterName, "Disabled")
 	} else {
-		table.AddRow(tbl, []string{rule.Service, "Pass", "-", *cluster.ClusterName, "Enabled", rule.Issue})
+		table.AddResult(tbl, rule, "Pass", *cluster.ClusterName, "Enabled")
 	}
 }
 
-func checkServices(client api.ECSClient, clusterArn string, clusterName string, tbl *tablewriter.Table, rules config.RulesConfig) {
+// ecsTag reads an ECS tag for tagsOf.
+func ecsTag(t types.Tag) (*string, *string) {
+	return t.Key, t.Value
+}
+
+func checkServices(client api.ECSClient, clusterArn string, clusterName string, clusterSelected bool, tbl *table.Table, rules config.RulesConfig) {
 	// List Services
 	// Note: Pagination should be handled for production, but kept simple for now as per previous pattern.
 	svcResp, err := client.ListServices(context.TODO(), &ecs.ListServicesInput{
 		Cluster: &clusterArn,
 	})
 	if err != nil {
-		log.Fatalf("Failed to list services for cluster %s: %v", clusterName, err)
+		fatalf("Failed to list services for cluster %s: %v", clusterName, err)
 	}
 
 	if len(svcResp.ServiceArns) > 0 {
-		descResp, err := client.DescribeServices(context.TODO(), &ecs.DescribeServicesInput{
+		input := &ecs.DescribeServicesInput{
 			Cluster:  &clusterArn,
 			Services: svcResp.ServiceArns,
-		})
+		}
+		if config.Resources.HasTagSelectors() {
+			input.Include = []types.ServiceField{types.ServiceFieldTags}
+		}
+		descResp, err := client.DescribeServices(context.TODO(), input)
 		if err != nil {
-			log.Fatalf("Failed to describe services for cluster %s: %v", clusterName, err)
+			fatalf("Failed to describe services for cluster %s: %v", clusterName, err)
 		}
 
 		for _, service := range descResp.Services {
+			if !clusterSelected && !config.Resources.Match(*service.ServiceName, aws.ToString(service.ServiceArn), tagsOf(service.Tags, ecsTag)) {
+				continue
+			}
 			checkCircuitBreaker(service, tbl, rules)
-			checkCpuArchitectureAndSensitiveInfo(client, service, tbl, rules)
+			if needs(rules, "ecs-cpu-architecture", "ecs-sensitive-environment-variables") {
+				checkCpuArchitectureAndSensitiveInfo(client, service, tbl, rules)
+			}
 			checkPropagateTags(service, tbl, rules)
+			collectResource(tbl, rules, inventory.TypeECSService, *service.ServiceName, aws.ToString(service.ServiceArn), service)
 		}
 	}
 }
 
-func checkPropagateTags(service types.Service, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkPropagateTags(service types.Service, tbl *table.Table, rules config.RulesConfig) {
 	rule := rules.Get("ecs-propagate-tags")
 	if service.PropagateTags == types.PropagateTagsNone {
-		table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), *service.ServiceName, string(service.PropagateTags), rule.Issue})
+		table.AddResult(tbl, rule, "Fail", *service.ServiceName, string(service.PropagateTags))
 	} else {
-		table.AddRow(tbl, []string{rule.Service, "Pass", "-", *service.ServiceName, string(service.PropagateTags), rule.Issue})
+		table.AddResult(tbl, rule, "Pass", *service.ServiceName, string(service.PropagateTags))
 	}
 }
 
-func checkCircuitBreaker(service types.Service, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkCircuitBreaker(service types.Service, tbl *table.Table, rules config.RulesConfig) {
 	// Circuit breaker is in DeploymentConfiguration
 	enabled := false
 	if service.DeploymentConfiguration != nil &&
@@ -150,13 +180,13 @@ func checkCircuitBreaker(service types.Service, tbl *tablewriter.Table, rules co
 
 	rule := rules.Get("ecs-service-circuit-breaker")
 	if !enabled {
-		table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), *service.ServiceName, "Disabled", rule.Issue})
+		table.AddResult(tbl, rule, "Fail", *service.ServiceName, "Disabled")
 	} else {
-		table.AddRow(tbl, []string{rule.Service, "Pass", "-", *service.ServiceName, "Enabled", rule.Issue})
+		table.AddResult(tbl, rule, "Pass", *service.ServiceName, "Enabled")
 	}
 }
 
-func checkCpuArchitectureAndSensitiveInfo(client api.ECSClient, service types.Service, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkCpuArchitectureAndSensitiveInfo(client api.ECSClient, service types.Service, tbl *table.Table, rules config.RulesConfig) {
 	// We need to look at the Task Definition
 	// service.TaskDefinition is an ARN.
 	if service.TaskDefinition == nil {
@@ -172,29 +202,37 @@ func checkCpuArchitectureAndSensitiveInfo(client api.ECSClient, service types.Se
 	}
 
 	// 1. Check CPU Architecture
+	checkCpuArchitecture(tdResp.TaskDefinition, service.ServiceName, tbl, rules)
+
+	// 2. Check Sensitive Environment Variables
+	checkSensitiveEnvironmentVariables(tdResp.TaskDefinition, service.ServiceName, tbl, rules)
+
+	td := tdResp.TaskDefinition
+	tdName := fmt.Sprintf("%s:%d", aws.ToString(td.Family), td.Revision)
+	collectResource(tbl, rules, inventory.TypeECSTaskDefinition, tdName, aws.ToString(td.TaskDefinitionArn), td)
+}
+
+func checkCpuArchitecture(td *types.TaskDefinition, serviceName *string, tbl *table.Table, rules config.RulesConfig) {
 	// CPU Architecture is in RuntimePlatform
 	isArm64 := false
-	if tdResp.TaskDefinition.RuntimePlatform != nil && tdResp.TaskDefinition.RuntimePlatform.CpuArchitecture == types.CPUArchitectureArm64 {
+	if td.RuntimePlatform != nil && td.RuntimePlatform.CpuArchitecture == types.CPUArchitectureArm64 {
 		isArm64 = true
 	}
 
 	arch := "Unknown"
-	if tdResp.TaskDefinition.RuntimePlatform != nil {
-		arch = string(tdResp.TaskDefinition.RuntimePlatform.CpuArchitecture)
+	if td.RuntimePlatform != nil {
+		arch = string(td.RuntimePlatform.CpuArchitecture)
 	}
 
 	ruleArch := rules.Get("ecs-cpu-architecture")
 	if !isArm64 {
-		table.AddRow(tbl, []string{ruleArch.Service, "Fail", color.ColorizeLevel(ruleArch.Level), *service.ServiceName, arch, ruleArch.Issue})
+		table.AddResult(tbl, ruleArch, "Fail", *serviceName, arch)
 	} else {
-		table.AddRow(tbl, []string{ruleArch.Service, "Pass", "-", *service.ServiceName, arch, ruleArch.Issue})
+		table.AddResult(tbl, ruleArch, "Pass", *serviceName, arch)
 	}
-
-	// 2. Check Sensitive Environment Variables
-	checkSensitiveEnvironmentVariables(tdResp.TaskDefinition, service.ServiceName, tbl, rules)
 }
 
-func checkSensitiveEnvironmentVariables(td *types.TaskDefinition, serviceName *string, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkSensitiveEnvironmentVariables(td *types.TaskDefinition, serviceName *string, tbl *table.Table, rules config.RulesConfig) {
 	sensitiveKeywords := []string{"PASSWORD", "TOKEN", "SECRET", "KEY", "CREDENTIAL"}
 	foundSensitive := false
 	var foundKeys []string
@@ -216,8 +254,8 @@ func checkSensitiveEnvironmentVariables(td *types.TaskDefinition, serviceName *s
 	resourceName := *serviceName
 	if foundSensitive {
 		status := fmt.Sprintf("Found: %s", strings.Join(foundKeys, ", "))
-		table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), resourceName, status, rule.Issue})
+		table.AddResult(tbl, rule, "Fail", resourceName, status)
 	} else {
-		table.AddRow(tbl, []string{rule.Service, "Pass", "-", resourceName, "Safe", rule.Issue})
+		table.AddResult(tbl, rule, "Pass", resourceName, "Safe")
 	}
 }
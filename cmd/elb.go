@@ -8,11 +8,12 @@ import (
 	"awsselfrev/internal/aws/api"
 	"awsselfrev/internal/color"
 	"awsselfrev/internal/config"
+	"awsselfrev/internal/inventory"
 	"awsselfrev/internal/table"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
 	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
-	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 )
 
@@ -32,7 +33,7 @@ var elbCmd = &cobra.Command{
 
 		checkELBConfigurations(client, tbl, rules)
 
-		table.Render("ELB", tbl)
+		renderResults("ELB", tbl, rules)
 	},
 }
 
@@ -40,10 +41,13 @@ func init() {
 	rootCmd.AddCommand(elbCmd)
 }
 
-func checkELBConfigurations(client api.ELBv2Client, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkELBConfigurations(client api.ELBv2Client, tbl *table.Table, rules config.RulesConfig) {
+	if !needsService(rules, "ELB") {
+		return
+	}
 	resp, err := client.DescribeLoadBalancers(context.TODO(), &elasticloadbalancingv2.DescribeLoadBalancersInput{})
 	if err != nil {
-		log.Fatalf("Failed to describe load balancers: %v", err)
+		fatalf("Failed to describe load balancers: %v", err)
 	}
 
 	if len(resp.LoadBalancers) == 0 {
@@ -55,22 +59,57 @@ func checkELBConfigurations(client api.ELBv2Client, tbl *tablewriter.Table, rule
 		if lb.Type != types.LoadBalancerTypeEnumApplication {
 			continue
 		}
+		if !config.Resources.Match(*lb.LoadBalancerName, *lb.LoadBalancerArn, elbTags(client, *lb.LoadBalancerArn)) {
+			continue
+		}
 
-		attrs, err := client.DescribeLoadBalancerAttributes(context.TODO(), &elasticloadbalancingv2.DescribeLoadBalancerAttributesInput{
-			LoadBalancerArn: lb.LoadBalancerArn,
-		})
-		if err != nil {
-			log.Fatalf("Failed to describe attributes for ELB %s: %v", *lb.LoadBalancerName, err)
+		attrs := &elasticloadbalancingv2.DescribeLoadBalancerAttributesOutput{}
+		if needs(rules, "alb-access-logging", "alb-connection-logging", "alb-deletion-protection") {
+			var err error
+			attrs, err = client.DescribeLoadBalancerAttributes(context.TODO(), &elasticloadbalancingv2.DescribeLoadBalancerAttributesInput{
+				LoadBalancerArn: lb.LoadBalancerArn,
+			})
+			if err != nil {
+				fatalf("Failed to describe attributes for ELB %s: %v", *lb.LoadBalancerName, err)
+			}
 		}
 
 		checkELBAccessLogs(lb, attrs, tbl, rules)
 		checkELBConnectionLogs(lb, attrs, tbl, rules)
 		checkELBDeletionProtection(lb, attrs, tbl, rules)
-		checkELBTargetGroupHealth(client, lb, tbl, rules)
+		if needs(rules, "elb-target-health") {
+			checkELBTargetGroupHealth(client, lb, tbl, rules)
+		}
+		collectResource(tbl, rules, inventory.TypeLoadBalancer, *lb.LoadBalancerName, *lb.LoadBalancerArn, loadBalancerDocument(lb, attrs))
+	}
+}
+
+// loadBalancerDocument adds the load balancer attributes to the
+// description as an "Attributes" map, e.g. Attributes."deletion_protection.enabled".
+func loadBalancerDocument(lb types.LoadBalancer, attrs *elasticloadbalancingv2.DescribeLoadBalancerAttributesOutput) map[string]interface{} {
+	attributes := make(map[string]string)
+	for _, attr := range attrs.Attributes {
+		attributes[aws.ToString(attr.Key)] = aws.ToString(attr.Value)
 	}
+	return map[string]interface{}{
+		"LoadBalancer": lb,
+		"Attributes":   attributes,
+	}
+}
+
+func elbTags(client api.ELBv2Client, arn string) func() map[string]string {
+	return lazyTags(arn, func() (map[string]string, error) {
+		resp, err := client.DescribeTags(context.TODO(), &elasticloadbalancingv2.DescribeTagsInput{
+			ResourceArns: []string{arn},
+		})
+		if err != nil || len(resp.TagDescriptions) == 0 {
+			return nil, err
+		}
+		return tagMap(resp.TagDescriptions[0].Tags, func(t types.Tag) (*string, *string) { return t.Key, t.Value }), nil
+	})
 }
 
-func checkELBAccessLogs(lb types.LoadBalancer, attrs *elasticloadbalancingv2.DescribeLoadBalancerAttributesOutput, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkELBAccessLogs(lb types.LoadBalancer, attrs *elasticloadbalancingv2.DescribeLoadBalancerAttributesOutput, tbl *table.Table, rules config.RulesConfig) {
 	enabled := false
 	for _, attr := range attrs.Attributes {
 		if *attr.Key == "access_logs.s3.enabled" && *attr.Value == "true" {
@@ -80,13 +119,13 @@ func checkELBAccessLogs(lb types.LoadBalancer, attrs *elasticloadbalancingv2.Des
 	}
 	rule := rules.Get("alb-access-logging")
 	if !enabled {
-		table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), *lb.LoadBalancerName, "Disabled", rule.Issue})
+		table.AddResult(tbl, rule, "Fail", *lb.LoadBalancerName, "Disabled")
 	} else {
-		table.AddRow(tbl, []string{rule.Service, "Pass", "-", *lb.LoadBalancerName, "Enabled", rule.Issue})
+		table.AddResult(tbl, rule, "Pass", *lb.LoadBalancerName, "Enabled")
 	}
 }
 
-func checkELBConnectionLogs(lb types.LoadBalancer, attrs *elasticloadbalancingv2.DescribeLoadBalancerAttributesOutput, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkELBConnectionLogs(lb types.LoadBalancer, attrs *elasticloadbalancingv2.DescribeLoadBalancerAttributesOutput, tbl *table.Table, rules config.RulesConfig) {
 	enabled := false
 	for _, attr := range attrs.Attributes {
 		if *attr.Key == "connection_logs.s3.enabled" && *attr.Value == "true" {
@@ -96,13 +135,13 @@ func checkELBConnectionLogs(lb types.LoadBalancer, attrs *elasticloadbalancingv2
 	}
 	rule := rules.Get("alb-connection-logging")
 	if !enabled {
-		table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), *lb.LoadBalancerName, "Disabled", rule.Issue})
+		table.AddResult(tbl, rule, "Fail", *lb.LoadBalancerName, "Disabled")
 	} else {
-		table.AddRow(tbl, []string{rule.Service, "Pass", "-", *lb.LoadBalancerName, "Enabled", rule.Issue})
+		table.AddResult(tbl, rule, "Pass", *lb.LoadBalancerName, "Enabled")
 	}
 }
 
-func checkELBDeletionProtection(lb types.LoadBalancer, attrs *elasticloadbalancingv2.DescribeLoadBalancerAttributesOutput, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkELBDeletionProtection(lb types.LoadBalancer, attrs *elasticloadbalancingv2.DescribeLoadBalancerAttributesOutput, tbl *table.Table, rules config.RulesConfig) {
 	enabled := false
 	for _, attr := range attrs.Attributes {
 		if *attr.Key == "deletion_protection.enabled" && *attr.Value == "true" {
@@ -112,13 +151,13 @@ func checkELBDeletionProtection(lb types.LoadBalancer, attrs *elasticloadbalanci
 	}
 	rule := rules.Get("alb-deletion-protection")
 	if !enabled {
-		table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), *lb.LoadBalancerName, "Disabled", rule.Issue})
+		table.AddResult(tbl, rule, "Fail", *lb.LoadBalancerName, "Disabled")
 	} else {
-		table.AddRow(tbl, []string{rule.Service, "Pass", "-", *lb.LoadBalancerName, "Enabled", rule.Issue})
+		table.AddResult(tbl, rule, "Pass", *lb.LoadBalancerName, "Enabled")
 	}
 }
 
-func checkELBTargetGroupHealth(client api.ELBv2Client, lb types.LoadBalancer, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkELBTargetGroupHealth(client api.ELBv2Client, lb types.LoadBalancer, tbl *table.Table, rules config.RulesConfig) {
 	tgResp, err := client.DescribeTargetGroups(context.TODO(), &elasticloadbalancingv2.DescribeTargetGroupsInput{
 		LoadBalancerArn: lb.LoadBalancerArn,
 	})
@@ -154,9 +193,9 @@ func checkELBTargetGroupHealth(client api.ELBv2Client, lb types.LoadBalancer, tb
 
 		resourceName := fmt.Sprintf("%s > %s", *lb.LoadBalancerName, *tg.TargetGroupName)
 		if !allHealthy {
-			table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), resourceName, healthStatus, rule.Issue})
+			table.AddResult(tbl, rule, "Fail", resourceName, healthStatus)
 		} else {
-			table.AddRow(tbl, []string{rule.Service, "Pass", "-", resourceName, healthStatus, rule.Issue})
+			table.AddResult(tbl, rule, "Pass", resourceName, healthStatus)
 		}
 	}
 }
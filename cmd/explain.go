@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"awsselfrev/internal/color"
+	"awsselfrev/internal/config"
+
+	"github.com/spf13/cobra"
+)
+
+var explainCmd = &cobra.Command{
+	Use:   "explain <rule-key>",
+	Short: "Show remediation guidance for a rule",
+	Long: `The "explain" command prints what a rule checks and how to fix a failure,
+including AWS CLI and Terraform snippets and links to the AWS documentation.
+
+The rule key is the name used in rules.yaml, e.g. "s3-public-access".`,
+	Args: cobra.ExactArgs(1),
+	// explain only reads rules.yaml, so it does not need AWS credentials.
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		rules := config.LoadRules()
+		var keys []string
+		for key := range rules.Rules {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return keys, cobra.ShellCompDirectiveNoFileComp
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		rules := config.LoadRules()
+		rule, ok := rules.Rules[args[0]]
+		if !ok {
+			log.Fatalf("Rule not found for key: %s", args[0])
+		}
+		rule.Key = args[0]
+
+		fmt.Print(formatExplanation(rule))
+	},
+}
+
+func formatExplanation(rule config.Rule) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", rule.Key)
+	fmt.Fprintf(&b, "  Service: %s\n", rule.Service)
+	fmt.Fprintf(&b, "  Level:   %s\n", color.ColorizeLevel(rule.Level))
+	fmt.Fprintf(&b, "  Issue:   %s\n", rule.Issue)
+
+	if rule.IsCustom() {
+		fmt.Fprintf(&b, "\nCustom rule on %s resources:\n", rule.Resource)
+		if rule.When != "" {
+			fmt.Fprintf(&b, "  When:    %s\n", rule.When)
+		}
+		fmt.Fprintf(&b, "  Assert:  %s\n", rule.Assert)
+		if rule.Setting != "" {
+			fmt.Fprintf(&b, "  Setting: %s\n", rule.Setting)
+		}
+	}
+	if rule.Policy {
+		fmt.Fprintf(&b, "\nFindings come from Rego policies (--policy).\n")
+	}
+
+	if rule.Remediation != "" {
+		fmt.Fprintf(&b, "\nRemediation:\n%s", indent(rule.Remediation))
+	}
+	if rule.CLI != "" {
+		fmt.Fprintf(&b, "\nAWS CLI:\n%s", indent(rule.CLI))
+	}
+	if rule.Terraform != "" {
+		fmt.Fprintf(&b, "\nTerraform:\n%s", indent(rule.Terraform))
+	}
+	if len(rule.Docs) > 0 {
+		fmt.Fprintf(&b, "\nDocumentation:\n")
+		for _, doc := range rule.Docs {
+			fmt.Fprintf(&b, "  - %s\n", doc)
+		}
+	}
+	return b.String()
+}
+
+func indent(text string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		if line == "" {
+			b.WriteString("\n")
+			continue
+		}
+		b.WriteString("  " + line + "\n")
+	}
+	return b.String()
+}
+
+func init() {
+	rootCmd.AddCommand(explainCmd)
+}
@@ -0,0 +1,321 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	"awsselfrev/internal/aws/api"
+	s3Internal "awsselfrev/internal/aws/service/s3"
+	"awsselfrev/internal/config"
+	"awsselfrev/internal/table"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var fixCmd = &cobra.Command{
+	Use:   "fix",
+	Short: "Fix failed checks that have a safe corrective API call",
+	Long: `The "fix" command runs the checks and, for the failed results of the rules
+below, makes the API call that fixes the setting:
+
+- ec2-ebs-default-encryption  enable EBS encryption by default
+- cloudwatch-retention        set the log group retention (--retention-days)
+- ecr-image-scanning          enable scan on push
+- ecr-tag-immutability        make image tags immutable
+- alb-deletion-protection     enable deletion protection on the load balancer
+- rds-deletion-protection     enable deletion protection on the DB cluster or instance
+- s3-public-access            turn on all four Block Public Access settings
+
+By default nothing is changed: the planned changes are listed (dry run). With
+--apply, each change is confirmed on the terminal before it is made, and every
+change made or attempted is logged to standard error (and to --log-file).
+
+--rules, --exclude-rules, --min-level and --resource-filter narrow the fixes
+the same way they narrow the checks.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fixRetentionDays, _ = cmd.Flags().GetInt32("retention-days")
+		if !slices.Contains(retentionDays, fixRetentionDays) {
+			log.Fatalf("Invalid --retention-days %d: CloudWatch Logs accepts %s", fixRetentionDays, joinInt32(retentionDays))
+		}
+
+		cfg := config.LoadConfig()
+		rules := config.LoadRules()
+		tbl := table.SetTable()
+
+		// Only failed results are fixed, whatever --fail-only says.
+		table.FailOnly = false
+		checkEC2Configurations(ec2.NewFromConfig(cfg), tbl, rules)
+		checkCloudWatchLogsConfigurations(cloudwatchlogs.NewFromConfig(cfg), tbl, rules)
+		checkECRConfigurations(ecr.NewFromConfig(cfg), tbl, rules)
+		checkELBConfigurations(elasticloadbalancingv2.NewFromConfig(cfg), tbl, rules)
+		checkRDSConfigurations(rds.NewFromConfig(cfg), tbl, rules)
+		checkS3Configurations(newS3Clients(cfg), newKMSKeys(cfg), newS3ControlClients(cfg), findLogTargets(newLogSourceClients(cfg), rules), tbl, rules)
+		resetCollected()
+
+		fixes := planFixes(tbl.Rows())
+		renderFixes(cmd.OutOrStdout(), fixes)
+
+		apply, _ := cmd.Flags().GetBool("apply")
+		if !apply {
+			if len(fixes) > 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "Dry run: nothing was changed. Run with --apply to make these changes.")
+			}
+			return
+		}
+
+		logger := log.New(os.Stderr, "", log.LstdFlags)
+		if path, _ := cmd.Flags().GetString("log-file"); path != "" {
+			f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+			if err != nil {
+				log.Fatalf("Failed to open %s: %v", path, err)
+			}
+			defer f.Close()
+			logger.SetOutput(io.MultiWriter(os.Stderr, f))
+		}
+
+		clients := &fixClients{
+			ec2:            ec2.NewFromConfig(cfg),
+			cloudwatchlogs: cloudwatchlogs.NewFromConfig(cfg),
+			ecr:            ecr.NewFromConfig(cfg),
+			elb:            elasticloadbalancingv2.NewFromConfig(cfg),
+			rds:            rds.NewFromConfig(cfg),
+			s3: func(region string) api.S3FixClient {
+				return s3.NewFromConfig(cfg, func(o *s3.Options) {
+					if region != "" {
+						o.Region = region
+					}
+				})
+			},
+		}
+		if failed := applyFixes(clients, fixes, cmd.InOrStdin(), cmd.OutOrStdout(), logger); failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// fixRetentionDays is the retention set on log groups that never expire.
+var fixRetentionDays int32 = 365
+
+// retentionDays are the values PutRetentionPolicy accepts.
+var retentionDays = []int32{1, 3, 5, 7, 14, 30, 60, 90, 120, 150, 180, 365, 400, 545, 731, 1096, 1827, 2192, 2557, 2922, 3288, 3653}
+
+func joinInt32(values []int32) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = fmt.Sprint(v)
+	}
+	return strings.Join(s, ", ")
+}
+
+type fixClients struct {
+	ec2            api.EC2FixClient
+	cloudwatchlogs api.CloudWatchLogsFixClient
+	ecr            api.ECRFixClient
+	elb            api.ELBv2FixClient
+	rds            api.RDSFixClient
+	// s3 returns a client of the region, or of the configured region for "";
+	// bucket requests must be sent to the bucket's region.
+	s3 func(region string) api.S3FixClient
+}
+
+// fixer describes and makes the change that fixes a failed rule.
+type fixer struct {
+	change func(resource string) string
+	apply  func(c *fixClients, resource string) error
+}
+
+// fixers holds the rules "fix" can fix, by rule key. Only changes that are
+// safe to make on a running resource belong here.
+var fixers = map[string]fixer{
+	"ec2-ebs-default-encryption": {
+		change: func(string) string { return "Enable EBS encryption by default" },
+		apply: func(c *fixClients, _ string) error {
+			_, err := c.ec2.EnableEbsEncryptionByDefault(context.TODO(), &ec2.EnableEbsEncryptionByDefaultInput{})
+			return err
+		},
+	},
+	"cloudwatch-retention": {
+		change: func(string) string { return fmt.Sprintf("Set retention to %d days", fixRetentionDays) },
+		apply: func(c *fixClients, logGroup string) error {
+			_, err := c.cloudwatchlogs.PutRetentionPolicy(context.TODO(), &cloudwatchlogs.PutRetentionPolicyInput{
+				LogGroupName:    aws.String(logGroup),
+				RetentionInDays: aws.Int32(fixRetentionDays),
+			})
+			return err
+		},
+	},
+	"ecr-image-scanning": {
+		change: func(string) string { return "Enable scan on push" },
+		apply: func(c *fixClients, repo string) error {
+			_, err := c.ecr.PutImageScanningConfiguration(context.TODO(), &ecr.PutImageScanningConfigurationInput{
+				RepositoryName:             aws.String(repo),
+				ImageScanningConfiguration: &ecrtypes.ImageScanningConfiguration{ScanOnPush: true},
+			})
+			return err
+		},
+	},
+	"ecr-tag-immutability": {
+		change: func(string) string { return "Make image tags immutable" },
+		apply: func(c *fixClients, repo string) error {
+			_, err := c.ecr.PutImageTagMutability(context.TODO(), &ecr.PutImageTagMutabilityInput{
+				RepositoryName:     aws.String(repo),
+				ImageTagMutability: ecrtypes.ImageTagMutabilityImmutable,
+			})
+			return err
+		},
+	},
+	"alb-deletion-protection": {
+		change: func(string) string { return "Enable deletion protection" },
+		apply: func(c *fixClients, name string) error {
+			resp, err := c.elb.DescribeLoadBalancers(context.TODO(), &elasticloadbalancingv2.DescribeLoadBalancersInput{
+				Names: []string{name},
+			})
+			if err != nil {
+				return err
+			}
+			if len(resp.LoadBalancers) == 0 {
+				return fmt.Errorf("load balancer %s not found", name)
+			}
+			_, err = c.elb.ModifyLoadBalancerAttributes(context.TODO(), &elasticloadbalancingv2.ModifyLoadBalancerAttributesInput{
+				LoadBalancerArn: resp.LoadBalancers[0].LoadBalancerArn,
+				Attributes: []elbtypes.LoadBalancerAttribute{
+					{Key: aws.String("deletion_protection.enabled"), Value: aws.String("true")},
+				},
+			})
+			return err
+		},
+	},
+	"rds-deletion-protection": {
+		change: func(string) string { return "Enable deletion protection" },
+		apply: func(c *fixClients, id string) error {
+			_, err := c.rds.ModifyDBCluster(context.TODO(), &rds.ModifyDBClusterInput{
+				DBClusterIdentifier: aws.String(id),
+				DeletionProtection:  aws.Bool(true),
+				ApplyImmediately:    aws.Bool(true),
+			})
+			var notFound *rdstypes.DBClusterNotFoundFault
+			if !errors.As(err, &notFound) {
+				return err
+			}
+			// Not a cluster: the result is for a standalone instance.
+			_, err = c.rds.ModifyDBInstance(context.TODO(), &rds.ModifyDBInstanceInput{
+				DBInstanceIdentifier: aws.String(id),
+				DeletionProtection:   aws.Bool(true),
+				ApplyImmediately:     aws.Bool(true),
+			})
+			return err
+		},
+	},
+	"s3-public-access": {
+		change: func(string) string { return "Turn on all Block Public Access settings" },
+		apply: func(c *fixClients, bucket string) error {
+			region, err := s3Internal.GetBucketRegion(c.s3(s3Internal.PartitionRegion(Partition)), bucket)
+			if err != nil {
+				return fmt.Errorf("failed to get the region of bucket %s: %v", bucket, err)
+			}
+			_, err = c.s3(region).PutPublicAccessBlock(context.TODO(), &s3.PutPublicAccessBlockInput{
+				Bucket: aws.String(bucket),
+				PublicAccessBlockConfiguration: &s3types.PublicAccessBlockConfiguration{
+					BlockPublicAcls:       aws.Bool(true),
+					IgnorePublicAcls:      aws.Bool(true),
+					BlockPublicPolicy:     aws.Bool(true),
+					RestrictPublicBuckets: aws.Bool(true),
+				},
+			})
+			return err
+		},
+	},
+}
+
+// fix is a planned change for one failed result.
+type fix struct {
+	Rule     string
+	Resource string
+	Setting  string
+	Change   string
+}
+
+// planFixes returns a fix for each failed result of a fixable rule.
+func planFixes(rows []table.Row) []fix {
+	var fixes []fix
+	for _, row := range rows {
+		f, ok := fixers[row.RuleKey]
+		if !ok || row.Status != "Fail" {
+			continue
+		}
+		fixes = append(fixes, fix{
+			Rule:     row.RuleKey,
+			Resource: row.Resource,
+			Setting:  row.Setting,
+			Change:   f.change(row.Resource),
+		})
+	}
+	return fixes
+}
+
+func renderFixes(out io.Writer, fixes []fix) {
+	if len(fixes) == 0 {
+		fmt.Fprintln(out, "Nothing to fix.")
+		return
+	}
+	tbl := tablewriter.NewWriter(out)
+	tbl.SetAutoWrapText(false)
+	tbl.SetHeader([]string{"RULE", "RESOURCE", "SETTING", "CHANGE"})
+	for _, f := range fixes {
+		tbl.Append([]string{f.Rule, f.Resource, f.Setting, f.Change})
+	}
+	tbl.Render()
+}
+
+// applyFixes asks for confirmation of each fix, makes the confirmed ones and
+// logs every change made or attempted. It returns the number of failed fixes.
+func applyFixes(clients *fixClients, fixes []fix, in io.Reader, out io.Writer, logger *log.Logger) int {
+	reader := bufio.NewReader(in)
+	failed := 0
+	for _, f := range fixes {
+		fmt.Fprintf(out, "%s on %s (%s)? [y/N]: ", f.Change, f.Resource, f.Rule)
+		answer, err := reader.ReadString('\n')
+		if err != nil && answer == "" {
+			// No more input: treat the remaining fixes as declined.
+			fmt.Fprintln(out)
+			return failed
+		}
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			continue
+		}
+
+		if err := fixers[f.Rule].apply(clients, f.Resource); err != nil {
+			logger.Printf("Fix failed: account=%s rule=%s resource=%s change=%q: %v", AccountID, f.Rule, f.Resource, f.Change, err)
+			failed++
+			continue
+		}
+		logger.Printf("Fix applied: account=%s rule=%s resource=%s change=%q", AccountID, f.Rule, f.Resource, f.Change)
+	}
+	return failed
+}
+
+func init() {
+	fixCmd.Flags().Bool("apply", false, "Make the changes, confirming each one (default is a dry run)")
+	fixCmd.Flags().Int32("retention-days", 365, "Retention in days set on log groups that never expire (a value CloudWatch Logs accepts, e.g. 30, 90, 365)")
+	fixCmd.Flags().String("log-file", "", "Also append the log of changes to this file")
+	rootCmd.AddCommand(fixCmd)
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+
+	"awsselfrev/internal/table"
+
+	"awsselfrev/internal/aws/api"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockECRFixClient struct {
+	mock.Mock
+}
+
+func (m *MockECRFixClient) PutImageScanningConfiguration(ctx context.Context, params *ecr.PutImageScanningConfigurationInput, optFns ...func(*ecr.Options)) (*ecr.PutImageScanningConfigurationOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	return &ecr.PutImageScanningConfigurationOutput{}, args.Error(0)
+}
+
+func (m *MockECRFixClient) PutImageTagMutability(ctx context.Context, params *ecr.PutImageTagMutabilityInput, optFns ...func(*ecr.Options)) (*ecr.PutImageTagMutabilityOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	return &ecr.PutImageTagMutabilityOutput{}, args.Error(0)
+}
+
+type MockS3FixClient struct {
+	MockS3Client
+}
+
+func (m *MockS3FixClient) PutPublicAccessBlock(ctx context.Context, params *s3.PutPublicAccessBlockInput, optFns ...func(*s3.Options)) (*s3.PutPublicAccessBlockOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	return &s3.PutPublicAccessBlockOutput{}, args.Error(0)
+}
+
+func TestFixersMatchRuleKeys(t *testing.T) {
+	for key := range fixers {
+		assert.Contains(t, ruleKeys, key)
+	}
+}
+
+func TestPlanFixes(t *testing.T) {
+	fixes := planFixes([]table.Row{
+		{RuleKey: "ecr-image-scanning", Status: "Fail", Resource: "app", Setting: "Disabled"},
+		{RuleKey: "ecr-tag-immutability", Status: "Pass", Resource: "app", Setting: "Immutable"},
+		{RuleKey: "ecr-lifecycle-policy", Status: "Fail", Resource: "app", Setting: "Missing"},
+		{Service: "ECR", Status: "-", Resource: "No repositories"},
+	})
+	assert.Equal(t, []fix{
+		{Rule: "ecr-image-scanning", Resource: "app", Setting: "Disabled", Change: "Enable scan on push"},
+	}, fixes)
+}
+
+func TestApplyFixes(t *testing.T) {
+	client := new(MockECRFixClient)
+	client.On("PutImageScanningConfiguration", mock.Anything, mock.MatchedBy(func(in *ecr.PutImageScanningConfigurationInput) bool {
+		return *in.RepositoryName == "app" && in.ImageScanningConfiguration.ScanOnPush
+	}), mock.Anything).Return(nil)
+	client.On("PutImageTagMutability", mock.Anything, mock.MatchedBy(func(in *ecr.PutImageTagMutabilityInput) bool {
+		return *in.RepositoryName == "web" && in.ImageTagMutability == types.ImageTagMutabilityImmutable
+	}), mock.Anything).Return(fmt.Errorf("access denied"))
+
+	fixes := []fix{
+		{Rule: "ecr-image-scanning", Resource: "app", Change: "Enable scan on push"},
+		{Rule: "ecr-image-scanning", Resource: "api", Change: "Enable scan on push"},
+		{Rule: "ecr-tag-immutability", Resource: "web", Change: "Make image tags immutable"},
+		{Rule: "ecr-tag-immutability", Resource: "batch", Change: "Make image tags immutable"},
+	}
+
+	// The last fix gets no answer and is not made.
+	var out, logs bytes.Buffer
+	failed := applyFixes(&fixClients{ecr: client}, fixes, strings.NewReader("y\nn\nyes\n"), &out, log.New(&logs, "", 0))
+
+	assert.Equal(t, 1, failed)
+	client.AssertNumberOfCalls(t, "PutImageScanningConfiguration", 1)
+	client.AssertNumberOfCalls(t, "PutImageTagMutability", 1)
+	assert.Contains(t, logs.String(), `Fix applied: account= rule=ecr-image-scanning resource=app change="Enable scan on push"`)
+	assert.Contains(t, logs.String(), `Fix failed: account= rule=ecr-tag-immutability resource=web change="Make image tags immutable": access denied`)
+	assert.NotContains(t, logs.String(), "resource=api")
+	assert.Contains(t, out.String(), "Make image tags immutable on batch (ecr-tag-immutability)? [y/N]: ")
+}
+
+func TestFixS3PublicAccessPartition(t *testing.T) {
+	location := new(MockS3FixClient)
+	location.On("GetBucketLocation", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketLocationOutput{LocationConstraint: s3types.BucketLocationConstraint("cn-northwest-1")}, nil)
+	ningxia := new(MockS3FixClient)
+	ningxia.On("PutPublicAccessBlock", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	Partition = "aws-cn"
+	defer func() { Partition = "aws" }()
+
+	// The region is looked up through the partition's endpoint, and the
+	// change is made in the bucket's region.
+	var regions []string
+	c := &fixClients{s3: func(region string) api.S3FixClient {
+		regions = append(regions, region)
+		if region == "cn-north-1" {
+			return location
+		}
+		return ningxia
+	}}
+	assert.NoError(t, fixers["s3-public-access"].apply(c, "assets"))
+	assert.Equal(t, []string{"cn-north-1", "cn-northwest-1"}, regions)
+	ningxia.AssertNumberOfCalls(t, "PutPublicAccessBlock", 1)
+}
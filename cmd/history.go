@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"awsselfrev/internal/history"
+	"awsselfrev/internal/table"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+// recordHistory appends each run's failed results to historyFile.
+var (
+	recordHistory bool
+	historyFile   string
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show how the failed checks changed over time",
+	Long: `The "history" command reads the runs recorded with --record-history and shows
+the number of failed checks per service for each month (or --period day or
+week). The last run of each command in a period counts for that period.
+
+With --findings, it lists each failed check instead: when it first appeared
+and when it was resolved. A finding is resolved by the next run of "all", of
+its service or of the command that reported it, that no longer reports it.
+Runs with --rules, --exclude-rules, --min-level or --resource-filter checked
+only part of their scope: their failed checks are listed, but they resolve
+none, and they do not replace the last full run in the counts.
+
+Runs are kept in --history-file, one JSON object per line, with the account,
+region and time of the run.`,
+	// history only reads the history file, so it does not need AWS credentials.
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	Run: func(cmd *cobra.Command, args []string) {
+		path, _ := cmd.Flags().GetString("history-file")
+		account, _ := cmd.Flags().GetString("account")
+		region, _ := cmd.Flags().GetString("region")
+		period, _ := cmd.Flags().GetString("period")
+		findings, _ := cmd.Flags().GetBool("findings")
+
+		runs, err := history.Load(path)
+		if err != nil {
+			log.Fatalf("Failed to read history: %v", err)
+		}
+		runs = history.Select(runs, account, region)
+		if len(runs) == 0 {
+			fmt.Printf("No runs recorded in %s. Record runs with --record-history.\n", path)
+			return
+		}
+
+		if findings {
+			renderLifetimes(history.Lifetimes(runs))
+			return
+		}
+		periods, err := history.Trend(runs, period)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		renderTrend(periods, period)
+	},
+}
+
+// appendHistory records the failed results of the run.
+func appendHistory(scope string, tbl *table.Table) {
+	run := history.Run{
+		Time:     time.Now().UTC(),
+		Account:  AccountID,
+		Region:   Region,
+		Scope:    scope,
+		Filters:  runFilterFlags,
+		Findings: []history.Finding{},
+	}
+	for _, row := range tbl.Rows() {
+		if row.Status != "Fail" {
+			continue
+		}
+		run.Findings = append(run.Findings, history.Finding{
+			Rule:     row.RuleKey,
+			Service:  row.Service,
+			Level:    row.Level,
+			Resource: row.Resource,
+			Setting:  row.Setting,
+		})
+	}
+	if err := history.Append(historyFile, run); err != nil {
+		log.Printf("Warning: Failed to record history in %s: %v", historyFile, err)
+	}
+}
+
+// runFilterFlags are the rule and resource filters of the run, empty when it
+// checks its whole scope. Recorded runs and notification baselines use them to
+// tell partial runs apart.
+var runFilterFlags []string
+
+// runFilters returns the filter flags of a run, e.g. "--rules=s3-encryption".
+func runFilters(include, exclude, minLevel string, resources []string) []string {
+	var filters []string
+	for _, f := range []struct{ flag, value string }{
+		{"rules", include},
+		{"exclude-rules", exclude},
+		{"min-level", minLevel},
+	} {
+		if f.value != "" {
+			filters = append(filters, "--"+f.flag+"="+f.value)
+		}
+	}
+	for _, r := range resources {
+		filters = append(filters, "--resource-filter="+r)
+	}
+	return filters
+}
+
+func renderTrend(periods []history.Period, period string) {
+	serviceSet := make(map[string]bool)
+	for _, p := range periods {
+		for service := range p.Counts {
+			serviceSet[service] = true
+		}
+	}
+	var services []string
+	for service := range serviceSet {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	layout := "2006-01-02"
+	if period == "month" {
+		layout = "2006-01"
+	}
+	tbl := tablewriter.NewWriter(os.Stdout)
+	tbl.SetAutoWrapText(false)
+	tbl.SetHeader(append(append([]string{"PERIOD"}, services...), "TOTAL"))
+	for _, p := range periods {
+		row := []string{p.Start.Format(layout)}
+		total := 0
+		for _, service := range services {
+			row = append(row, strconv.Itoa(p.Counts[service]))
+			total += p.Counts[service]
+		}
+		tbl.Append(append(row, strconv.Itoa(total)))
+	}
+	tbl.Render()
+}
+
+func renderLifetimes(lifetimes []history.Lifetime) {
+	sort.SliceStable(lifetimes, func(i, j int) bool {
+		a, b := lifetimes[i], lifetimes[j]
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+		if a.Rule != b.Rule {
+			return a.Rule < b.Rule
+		}
+		return a.Resource < b.Resource
+	})
+
+	const layout = "2006-01-02 15:04"
+	tbl := tablewriter.NewWriter(os.Stdout)
+	tbl.SetAutoWrapText(false)
+	tbl.SetHeader([]string{"SERVICE", "RULE", "RESOURCE", "ACCOUNT", "REGION", "FIRST SEEN", "RESOLVED"})
+	for _, l := range lifetimes {
+		resolved := "open"
+		if l.Resolved != nil {
+			resolved = l.Resolved.Format(layout)
+		}
+		tbl.Append([]string{l.Service, l.Rule, l.Resource, l.Account, l.Region, l.FirstSeen.Format(layout), resolved})
+	}
+	tbl.Render()
+}
+
+func init() {
+	historyCmd.Flags().String("account", "", "Only show runs of this account")
+	historyCmd.Flags().String("region", "", "Only show runs of this region")
+	historyCmd.Flags().String("period", "month", "Count failed checks per day, week or month")
+	historyCmd.Flags().Bool("findings", false, "List when each failed check first appeared and was resolved")
+	rootCmd.AddCommand(historyCmd)
+}
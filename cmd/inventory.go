@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+
+	"awsselfrev/internal/config"
+	"awsselfrev/internal/inventory"
+	"awsselfrev/internal/table"
+
+	"github.com/spf13/cobra"
+)
+
+var inventoryCmd = &cobra.Command{
+	Use:   "inventory",
+	Short: "Dump the resources the checks collect as JSON",
+	Long: `The "inventory" command runs the checks of every service and prints the
+resources they looked at as JSON instead of the result table: S3 buckets,
+RDS clusters and instances, VPCs, EBS volumes and snapshots, load balancers,
+CloudFront distributions, log groups, ECS clusters, services and task
+definitions, ECR repositories, Route53 hosted zones and WAF Web ACLs.
+
+Each resource has its type, name, ARN and the attributes the checks use, as
+returned by the AWS API. --resource-filter narrows the inventory the same way
+it narrows the checks.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := config.LoadConfig()
+		rules := config.LoadRules()
+		tbl := table.SetTable()
+
+		recordInventory = true
+		checkAllServices(cfg, tbl, rules)
+
+		out := os.Stdout
+		if path, _ := cmd.Flags().GetString("output"); path != "" {
+			f, err := os.Create(path)
+			if err != nil {
+				log.Fatalf("Failed to create %s: %v", path, err)
+			}
+			defer f.Close()
+			out = f
+		}
+		if err := writeInventory(out, collected); err != nil {
+			log.Fatalf("Failed to write inventory: %v", err)
+		}
+	},
+}
+
+type inventoryDocument struct {
+	Account   string               `json:"account"`
+	Resources []inventory.Resource `json:"resources"`
+}
+
+func writeInventory(out io.Writer, resources []inventory.Resource) error {
+	if resources == nil {
+		resources = []inventory.Resource{}
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(inventoryDocument{Account: AccountID, Resources: resources})
+}
+
+func init() {
+	inventoryCmd.Flags().StringP("output", "o", "", "Write the inventory to this file instead of standard output")
+	// Keep standard output for the JSON; the account banner goes to standard error.
+	inventoryCmd.SetOut(os.Stderr)
+	rootCmd.AddCommand(inventoryCmd)
+}
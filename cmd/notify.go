@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"awsselfrev/internal/notify"
+	"awsselfrev/internal/table"
+)
+
+// notifiers are the webhooks from --notify. With notifyBaseline, only the
+// failed checks missing from the baseline file are posted.
+var (
+	notifiers      []notify.Notifier
+	notifyBaseline string
+)
+
+// sendNotifications posts a digest of the failed results to each notifier.
+// The baseline is only updated when every notifier got the digest, so that
+// failed deliveries are retried on the next run.
+func sendNotifications(scope string, tbl *table.Table) {
+	var findings []notify.Finding
+	seen := make(map[string]bool)
+	for _, row := range tbl.Rows() {
+		if row.Status != "Fail" || seen[row.RuleKey+"/"+row.Resource] {
+			continue
+		}
+		seen[row.RuleKey+"/"+row.Resource] = true
+		findings = append(findings, notify.Finding{
+			Service:  row.Service,
+			Rule:     row.RuleKey,
+			Level:    row.Level,
+			Resource: row.Resource,
+			Setting:  row.Setting,
+			Issue:    row.Issue,
+		})
+	}
+
+	toSend := findings
+	var baseline []notify.Entry
+	if notifyBaseline != "" {
+		var err error
+		baseline, err = notify.LoadBaseline(notifyBaseline)
+		if err != nil {
+			log.Printf("Warning: Failed to read notification baseline: %v", err)
+			return
+		}
+		toSend = notify.New(findings, baseline, AccountID, Region)
+	}
+
+	delivered := true
+	if len(toSend) > 0 {
+		digest := notify.NewDigest(AccountID, Region, scope, notifyBaseline != "", toSend)
+		client := &http.Client{Timeout: 30 * time.Second}
+		for _, n := range notifiers {
+			if err := n.Send(client, digest); err != nil {
+				log.Printf("Warning: Failed to notify %s webhook: %v", n.Kind, err)
+				delivered = false
+			}
+		}
+	}
+
+	if notifyBaseline != "" && delivered {
+		// Filtered runs may have skipped findings of the baseline, so they
+		// keep them.
+		baseline = notify.UpdateBaseline(baseline, AccountID, Region, scope, len(runFilterFlags) > 0, findings)
+		if err := notify.SaveBaseline(notifyBaseline, baseline); err != nil {
+			log.Printf("Warning: Failed to write notification baseline: %v", err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"awsselfrev/internal/config"
+	"awsselfrev/internal/notify"
+	"awsselfrev/internal/table"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendNotificationsKeepsOtherBaselineEntries(t *testing.T) {
+	posts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts++
+	}))
+	defer srv.Close()
+
+	publicS3 := notify.Finding{Service: "S3", Rule: "s3-public-access", Level: "Alert", Resource: "assets", Setting: "Off: BlockPublicPolicy", Issue: "Block public access is all off"}
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	other := notify.Entry{Account: "123456789012", Region: "us-east-1", Scope: "S3", Finding: publicS3}
+	assert.NoError(t, notify.SaveBaseline(path, []notify.Entry{other}))
+
+	notifiers = []notify.Notifier{{Kind: notify.Webhook, URL: srv.URL}}
+	notifyBaseline = path
+	AccountID, Region = "123456789012", "eu-west-1"
+	defer func() {
+		notifiers, notifyBaseline = nil, ""
+		AccountID, Region = "", ""
+	}()
+
+	tbl := table.SetTable()
+	rule := config.Rule{Key: "s3-public-access", Service: "S3", Level: "Alert", Issue: "Block public access is all off"}
+	table.AddResult(tbl, rule, "Fail", "assets", "Off: BlockPublicPolicy")
+	sendNotifications("S3", tbl)
+
+	// The finding is new in this region, and the entry of the other region
+	// is kept next to it.
+	assert.Equal(t, 1, posts)
+	baseline, err := notify.LoadBaseline(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []notify.Entry{
+		other,
+		{Account: "123456789012", Region: "eu-west-1", Scope: "S3", Finding: publicS3},
+	}, baseline)
+
+	// A filtered run with nothing failed keeps the whole baseline.
+	runFilterFlags = []string{"--rules=s3-encryption"}
+	defer func() { runFilterFlags = nil }()
+	sendNotifications("S3", table.SetTable())
+	assert.Equal(t, 1, posts)
+	filtered, err := notify.LoadBaseline(path)
+	assert.NoError(t, err)
+	assert.Equal(t, baseline, filtered)
+}
@@ -14,7 +14,6 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/observabilityadmin"
 	"github.com/aws/aws-sdk-go-v2/service/observabilityadmin/types"
 	"github.com/aws/smithy-go"
-	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 )
 
@@ -32,7 +31,7 @@ var observabilityCmd = &cobra.Command{
 
 		checkObservabilityConfigurations(client, tbl, rules)
 
-		table.Render("Observability", tbl)
+		renderResults("Observability", tbl, rules)
 	},
 }
 
@@ -40,14 +39,17 @@ func init() {
 	rootCmd.AddCommand(observabilityCmd)
 }
 
-func checkObservabilityConfigurations(client api.ObservabilityAdminClient, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkObservabilityConfigurations(client api.ObservabilityAdminClient, tbl *table.Table, rules config.RulesConfig) {
+	if !needsService(rules, "ObservabilityAdmin") {
+		return
+	}
 	resp, err := client.GetTelemetryEnrichmentStatus(context.TODO(), &observabilityadmin.GetTelemetryEnrichmentStatusInput{})
 	rule := rules.Get("telemetry-resource-tags-enabled")
 	if err != nil {
 		var ae smithy.APIError
 		if errors.As(err, &ae) && strings.Contains(ae.ErrorCode(), "ResourceNotFoundException") {
 			// If not found, it means it's not enabled.
-			table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), "Account", "Disabled/Missing", rule.Issue})
+			table.AddResult(tbl, rule, "Fail", "Account", "Disabled/Missing")
 			return
 		}
 		log.Printf("Failed to get telemetry enrichment status: %v", err)
@@ -55,8 +57,8 @@ func checkObservabilityConfigurations(client api.ObservabilityAdminClient, tbl *
 	}
 
 	if resp.Status != types.TelemetryEnrichmentStatusRunning {
-		table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), "Account", string(resp.Status), rule.Issue})
+		table.AddResult(tbl, rule, "Fail", "Account", string(resp.Status))
 	} else {
-		table.AddRow(tbl, []string{rule.Service, "Pass", "-", "Account", string(resp.Status), rule.Issue})
+		table.AddResult(tbl, rule, "Pass", "Account", string(resp.Status))
 	}
 }
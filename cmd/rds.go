@@ -2,18 +2,18 @@ package cmd
 
 import (
 	"context"
-	"log"
 	"strconv"
 	"strings"
 
 	"awsselfrev/internal/aws/api"
 	"awsselfrev/internal/color"
 	"awsselfrev/internal/config"
+	"awsselfrev/internal/inventory"
 	"awsselfrev/internal/table"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/rds"
 	"github.com/aws/aws-sdk-go-v2/service/rds/types"
-	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 )
 
@@ -37,60 +37,72 @@ var rdsCmd = &cobra.Command{
 
 		checkRDSConfigurations(client, tbl, rules)
 
-		table.Render("RDS", tbl)
+		renderResults("RDS", tbl, rules)
 	},
 }
 
 // Map to cache parameter group values: GroupName -> Key -> Value
 var paramGroupCache = make(map[string]map[string]string)
 
-func checkRDSConfigurations(client api.RDSClient, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkRDSConfigurations(client api.RDSClient, tbl *table.Table, rules config.RulesConfig) {
+	if !needsService(rules, "RDS") {
+		return
+	}
 	resp, err := client.DescribeDBClusters(context.TODO(), &rds.DescribeDBClustersInput{})
 	if err != nil {
-		log.Fatalf("Failed to describe DB clusters: %v", err)
+		fatalf("Failed to describe DB clusters: %v", err)
 	}
 
+	// Instances of a selected cluster are selected too. The storage, deletion
+	// protection and backup settings of cluster members belong to the cluster,
+	// so they are only checked on standalone instances.
+	selectedClusters := make(map[string]bool)
+	clusterMembers := make(map[string]bool)
 	for _, cluster := range resp.DBClusters {
+		for _, member := range cluster.DBClusterMembers {
+			clusterMembers[aws.ToString(member.DBInstanceIdentifier)] = true
+		}
+		if !config.Resources.Match(aws.ToString(cluster.DBClusterIdentifier), aws.ToString(cluster.DBClusterArn), tagsOf(cluster.TagList, rdsTag)) {
+			continue
+		}
+		selectedClusters[aws.ToString(cluster.DBClusterIdentifier)] = true
+
 		checkStorageEncryption(cluster, tbl, rules)
 		checkDeletionProtection(cluster, tbl, rules)
 		checkClusterBackupEnabled(cluster, tbl, rules)
 		checkClusterDefaultParameterGroup(cluster, tbl, rules)
-		checkClusterLogConfigurations(client, cluster, tbl, rules)
+		if needs(rules, rdsLogRules...) {
+			checkClusterLogConfigurations(client, cluster, tbl, rules)
+		}
 		checkClusterMaintenanceWindow(cluster, tbl, rules)
-		checkDBInstances(client, cluster.DBClusterMembers, tbl, rules)
+		collectResource(tbl, rules, inventory.TypeRDSCluster, aws.ToString(cluster.DBClusterIdentifier), aws.ToString(cluster.DBClusterArn), cluster)
 	}
 
-	// Also check standalone instances if not covered by clusters (DBClusterMembers only covers cluster members).
-	// For simplicity, we assume checkDBInstances handles all if we list all (but ListClusters only returns clusters).
-	// We should also list all instances to catch standalone ones.
-	// However, the original code structure iterated clusters and then members.
-	// To be thorough, we should iterate DescribeDBInstances for everything, but need to avoid duplicates if we checked via cluster.
-	// For now, let's stick to the previous pattern but ensure we check instance-level logs for all instances found via Members,
-	// AND we should probably iterate all instances separately to catch non-Aurora RDS.
-	// Refactoring to iterate ALL instances once is better.
-
 	instancesResp, err := client.DescribeDBInstances(context.TODO(), &rds.DescribeDBInstancesInput{})
 	if err != nil {
-		log.Fatalf("Failed to describe DB instances: %v", err)
+		fatalf("Failed to describe DB instances: %v", err)
 	}
 
-	processedInstances := make(map[string]bool)
-	// Mark cluster members as processed if we want to avoid double checking,
-	// OR just iterate all instances here for instance-level checks and use cluster loop only for cluster-level checks.
-	// Let's iterate all instances here for instance-level checks.
 	for _, instance := range instancesResp.DBInstances {
-		if processedInstances[*instance.DBInstanceIdentifier] {
+		if !selectedClusters[aws.ToString(instance.DBClusterIdentifier)] &&
+			!config.Resources.Match(aws.ToString(instance.DBInstanceIdentifier), aws.ToString(instance.DBInstanceArn), tagsOf(instance.TagList, rdsTag)) {
 			continue
 		}
 
+		if aws.ToString(instance.DBClusterIdentifier) == "" && !clusterMembers[aws.ToString(instance.DBInstanceIdentifier)] {
+			checkInstanceStorageEncryption(instance, tbl, rules)
+			checkInstanceDeletionProtection(instance, tbl, rules)
+			checkInstanceBackupEnabled(instance, tbl, rules)
+		}
 		checkAutoMinorVersionUpgrade(instance, tbl, rules)
 		checkInstanceDefaultParameterGroup(instance, tbl, rules)
 		checkPublicAccessibility(instance, tbl, rules)
 		checkPerformanceInsights(instance, tbl, rules)
-		checkInstanceLogConfigurations(client, instance, tbl, rules)
+		if needs(rules, rdsLogRules...) {
+			checkInstanceLogConfigurations(client, instance, tbl, rules)
+		}
 		checkInstanceMaintenanceWindow(instance, tbl, rules)
-
-		processedInstances[*instance.DBInstanceIdentifier] = true
+		collectResource(tbl, rules, inventory.TypeRDSInstance, aws.ToString(instance.DBInstanceIdentifier), aws.ToString(instance.DBInstanceArn), instance)
 	}
 
 	if len(resp.DBClusters) == 0 && len(instancesResp.DBInstances) == 0 {
@@ -98,69 +110,101 @@ func checkRDSConfigurations(client api.RDSClient, tbl *tablewriter.Table, rules
 	}
 }
 
-func checkStorageEncryption(cluster types.DBCluster, tbl *tablewriter.Table, rules config.RulesConfig) {
+// rdsTag reads an RDS tag for tagsOf.
+func rdsTag(t types.Tag) (*string, *string) {
+	return t.Key, t.Value
+}
+
+func checkStorageEncryption(cluster types.DBCluster, tbl *table.Table, rules config.RulesConfig) {
 	rule := rules.Get("rds-storage-encryption")
 	if cluster.StorageEncrypted != nil && !*cluster.StorageEncrypted {
-		table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), *cluster.DBClusterIdentifier, "Disabled", rule.Issue})
+		table.AddResult(tbl, rule, "Fail", *cluster.DBClusterIdentifier, "Disabled")
 	} else {
-		table.AddRow(tbl, []string{rule.Service, "Pass", "-", *cluster.DBClusterIdentifier, "Enabled", rule.Issue})
+		table.AddResult(tbl, rule, "Pass", *cluster.DBClusterIdentifier, "Enabled")
 	}
 }
 
-func checkDeletionProtection(cluster types.DBCluster, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkDeletionProtection(cluster types.DBCluster, tbl *table.Table, rules config.RulesConfig) {
 	rule := rules.Get("rds-deletion-protection")
 	if cluster.DeletionProtection != nil && !*cluster.DeletionProtection {
-		table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), *cluster.DBClusterIdentifier, "Disabled", rule.Issue})
+		table.AddResult(tbl, rule, "Fail", *cluster.DBClusterIdentifier, "Disabled")
 	} else {
-		table.AddRow(tbl, []string{rule.Service, "Pass", "-", *cluster.DBClusterIdentifier, "Enabled", rule.Issue})
+		table.AddResult(tbl, rule, "Pass", *cluster.DBClusterIdentifier, "Enabled")
 	}
 }
 
-func checkClusterBackupEnabled(cluster types.DBCluster, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkClusterBackupEnabled(cluster types.DBCluster, tbl *table.Table, rules config.RulesConfig) {
 	rule := rules.Get("rds-backup-enabled")
 	if cluster.BackupRetentionPeriod != nil && *cluster.BackupRetentionPeriod == 0 {
-		table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), *cluster.DBClusterIdentifier, "0 days", rule.Issue})
+		table.AddResult(tbl, rule, "Fail", *cluster.DBClusterIdentifier, "0 days")
 	} else {
 		val := "Enabled"
 		if cluster.BackupRetentionPeriod != nil {
 			val = strconv.Itoa(int(*cluster.BackupRetentionPeriod)) + " days"
 		}
-		table.AddRow(tbl, []string{rule.Service, "Pass", "-", *cluster.DBClusterIdentifier, val, rule.Issue})
+		table.AddResult(tbl, rule, "Pass", *cluster.DBClusterIdentifier, val)
 	}
 }
 
-func checkClusterDefaultParameterGroup(cluster types.DBCluster, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkClusterDefaultParameterGroup(cluster types.DBCluster, tbl *table.Table, rules config.RulesConfig) {
 	rule := rules.Get("rds-default-parameter-group")
 	pg := "None"
 	if cluster.DBClusterParameterGroup != nil {
 		pg = *cluster.DBClusterParameterGroup
 	}
 	if cluster.DBClusterParameterGroup != nil && strings.HasPrefix(*cluster.DBClusterParameterGroup, "default.") {
-		table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), *cluster.DBClusterIdentifier, pg, rule.Issue})
+		table.AddResult(tbl, rule, "Fail", *cluster.DBClusterIdentifier, pg)
+	} else {
+		table.AddResult(tbl, rule, "Pass", *cluster.DBClusterIdentifier, pg)
+	}
+}
+
+func checkInstanceStorageEncryption(instance types.DBInstance, tbl *table.Table, rules config.RulesConfig) {
+	rule := rules.Get("rds-storage-encryption")
+	if instance.StorageEncrypted != nil && !*instance.StorageEncrypted {
+		table.AddResult(tbl, rule, "Fail", *instance.DBInstanceIdentifier, "Disabled")
+	} else {
+		table.AddResult(tbl, rule, "Pass", *instance.DBInstanceIdentifier, "Enabled")
+	}
+}
+
+func checkInstanceDeletionProtection(instance types.DBInstance, tbl *table.Table, rules config.RulesConfig) {
+	rule := rules.Get("rds-deletion-protection")
+	if instance.DeletionProtection != nil && !*instance.DeletionProtection {
+		table.AddResult(tbl, rule, "Fail", *instance.DBInstanceIdentifier, "Disabled")
 	} else {
-		table.AddRow(tbl, []string{rule.Service, "Pass", "-", *cluster.DBClusterIdentifier, pg, rule.Issue})
+		table.AddResult(tbl, rule, "Pass", *instance.DBInstanceIdentifier, "Enabled")
 	}
 }
 
-func checkDBInstances(client api.RDSClient, members []types.DBClusterMember, table *tablewriter.Table, rules config.RulesConfig) {
-	// fetching is now done in main loop to cover all instances
+func checkInstanceBackupEnabled(instance types.DBInstance, tbl *table.Table, rules config.RulesConfig) {
+	rule := rules.Get("rds-backup-enabled")
+	if instance.BackupRetentionPeriod != nil && *instance.BackupRetentionPeriod == 0 {
+		table.AddResult(tbl, rule, "Fail", *instance.DBInstanceIdentifier, "0 days")
+	} else {
+		val := "Enabled"
+		if instance.BackupRetentionPeriod != nil {
+			val = strconv.Itoa(int(*instance.BackupRetentionPeriod)) + " days"
+		}
+		table.AddResult(tbl, rule, "Pass", *instance.DBInstanceIdentifier, val)
+	}
 }
 
-func checkAutoMinorVersionUpgrade(instance types.DBInstance, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkAutoMinorVersionUpgrade(instance types.DBInstance, tbl *table.Table, rules config.RulesConfig) {
 	rule := rules.Get("rds-auto-minor-version-upgrade")
 	if instance.AutoMinorVersionUpgrade != nil && *instance.AutoMinorVersionUpgrade {
-		table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), *instance.DBInstanceIdentifier, "Enabled", rule.Issue})
+		table.AddResult(tbl, rule, "Fail", *instance.DBInstanceIdentifier, "Enabled")
 	} else {
-		table.AddRow(tbl, []string{rule.Service, "Pass", "-", *instance.DBInstanceIdentifier, "Disabled", rule.Issue})
+		table.AddResult(tbl, rule, "Pass", *instance.DBInstanceIdentifier, "Disabled")
 	}
 }
 
-func checkInstanceDefaultParameterGroup(instance types.DBInstance, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkInstanceDefaultParameterGroup(instance types.DBInstance, tbl *table.Table, rules config.RulesConfig) {
 	found := false
 	rule := rules.Get("rds-default-parameter-group")
 	for _, pg := range instance.DBParameterGroups {
 		if pg.DBParameterGroupName != nil && strings.HasPrefix(*pg.DBParameterGroupName, "default.") {
-			table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), *instance.DBInstanceIdentifier, *pg.DBParameterGroupName, rule.Issue})
+			table.AddResult(tbl, rule, "Fail", *instance.DBInstanceIdentifier, *pg.DBParameterGroupName)
 			found = true
 			break // Report once per instance
 		}
@@ -170,31 +214,34 @@ func checkInstanceDefaultParameterGroup(instance types.DBInstance, tbl *tablewri
 		if len(instance.DBParameterGroups) > 0 && instance.DBParameterGroups[0].DBParameterGroupName != nil {
 			pgName = *instance.DBParameterGroups[0].DBParameterGroupName
 		}
-		table.AddRow(tbl, []string{rule.Service, "Pass", "-", *instance.DBInstanceIdentifier, pgName, rule.Issue})
+		table.AddResult(tbl, rule, "Pass", *instance.DBInstanceIdentifier, pgName)
 	}
 }
 
-func checkPublicAccessibility(instance types.DBInstance, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkPublicAccessibility(instance types.DBInstance, tbl *table.Table, rules config.RulesConfig) {
 	rule := rules.Get("rds-public-access")
 	if instance.PubliclyAccessible != nil && *instance.PubliclyAccessible {
-		table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), *instance.DBInstanceIdentifier, "Public", rule.Issue})
+		table.AddResult(tbl, rule, "Fail", *instance.DBInstanceIdentifier, "Public")
 	} else {
-		table.AddRow(tbl, []string{rule.Service, "Pass", "-", *instance.DBInstanceIdentifier, "Private", rule.Issue})
+		table.AddResult(tbl, rule, "Pass", *instance.DBInstanceIdentifier, "Private")
 	}
 }
 
-func checkPerformanceInsights(instance types.DBInstance, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkPerformanceInsights(instance types.DBInstance, tbl *table.Table, rules config.RulesConfig) {
 	rule := rules.Get("rds-performance-insights")
 	if instance.PerformanceInsightsEnabled != nil && !*instance.PerformanceInsightsEnabled {
-		table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), *instance.DBInstanceIdentifier, "Disabled", rule.Issue})
+		table.AddResult(tbl, rule, "Fail", *instance.DBInstanceIdentifier, "Disabled")
 	} else {
-		table.AddRow(tbl, []string{rule.Service, "Pass", "-", *instance.DBInstanceIdentifier, "Enabled", rule.Issue})
+		table.AddResult(tbl, rule, "Pass", *instance.DBInstanceIdentifier, "Enabled")
 	}
 }
 
 // Log Checks
 
-func checkClusterLogConfigurations(client api.RDSClient, cluster types.DBCluster, tbl *tablewriter.Table, rules config.RulesConfig) {
+// rdsLogRules are the rules of checkLogs, which reads the parameter groups.
+var rdsLogRules = []string{"rds-general-log", "rds-slow-query-log", "rds-audit-log", "rds-error-log"}
+
+func checkClusterLogConfigurations(client api.RDSClient, cluster types.DBCluster, tbl *table.Table, rules config.RulesConfig) {
 	// Check Cluster logs (mostly for Aurora)
 	exports := cluster.EnabledCloudwatchLogsExports
 	pgName := ""
@@ -205,7 +252,7 @@ func checkClusterLogConfigurations(client api.RDSClient, cluster types.DBCluster
 	checkLogs(client, pgName, exports, *cluster.DBClusterIdentifier, tbl, rules, true)
 }
 
-func checkInstanceLogConfigurations(client api.RDSClient, instance types.DBInstance, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkInstanceLogConfigurations(client api.RDSClient, instance types.DBInstance, tbl *table.Table, rules config.RulesConfig) {
 	// Check Instance logs (for RDS and Aurora members)
 	exports := instance.EnabledCloudwatchLogsExports
 	pgName := ""
@@ -217,24 +264,24 @@ func checkInstanceLogConfigurations(client api.RDSClient, instance types.DBInsta
 	checkLogs(client, pgName, exports, *instance.DBInstanceIdentifier, tbl, rules, false)
 }
 
-func checkClusterMaintenanceWindow(cluster types.DBCluster, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkClusterMaintenanceWindow(cluster types.DBCluster, tbl *table.Table, rules config.RulesConfig) {
 	rule := rules.Get("rds-maintenance-window")
 	if cluster.PreferredMaintenanceWindow != nil {
 		if !isWindowValid(*cluster.PreferredMaintenanceWindow) {
-			table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), *cluster.DBClusterIdentifier, *cluster.PreferredMaintenanceWindow, rule.Issue})
+			table.AddResult(tbl, rule, "Fail", *cluster.DBClusterIdentifier, *cluster.PreferredMaintenanceWindow)
 		} else {
-			table.AddRow(tbl, []string{rule.Service, "Pass", "-", *cluster.DBClusterIdentifier, *cluster.PreferredMaintenanceWindow, rule.Issue})
+			table.AddResult(tbl, rule, "Pass", *cluster.DBClusterIdentifier, *cluster.PreferredMaintenanceWindow)
 		}
 	}
 }
 
-func checkInstanceMaintenanceWindow(instance types.DBInstance, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkInstanceMaintenanceWindow(instance types.DBInstance, tbl *table.Table, rules config.RulesConfig) {
 	rule := rules.Get("rds-maintenance-window")
 	if instance.PreferredMaintenanceWindow != nil {
 		if !isWindowValid(*instance.PreferredMaintenanceWindow) {
-			table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), *instance.DBInstanceIdentifier, *instance.PreferredMaintenanceWindow, rule.Issue})
+			table.AddResult(tbl, rule, "Fail", *instance.DBInstanceIdentifier, *instance.PreferredMaintenanceWindow)
 		} else {
-			table.AddRow(tbl, []string{rule.Service, "Pass", "-", *instance.DBInstanceIdentifier, *instance.PreferredMaintenanceWindow, rule.Issue})
+			table.AddResult(tbl, rule, "Pass", *instance.DBInstanceIdentifier, *instance.PreferredMaintenanceWindow)
 		}
 	}
 }
@@ -278,7 +325,7 @@ func isWindowValid(window string) bool {
 	return false
 }
 
-func checkLogs(client api.RDSClient, pgName string, exports []string, identifier string, tbl *tablewriter.Table, rules config.RulesConfig, isCluster bool) {
+func checkLogs(client api.RDSClient, pgName string, exports []string, identifier string, tbl *table.Table, rules config.RulesConfig, isCluster bool) {
 	// Helper to check slice contains
 	contains := func(slice []string, item string) bool {
 		for _, s := range slice {
@@ -295,18 +342,18 @@ func checkLogs(client api.RDSClient, pgName string, exports []string, identifier
 	// Req: Exported AND (general_log=1 OR general_log=ON)
 	ruleGen := rules.Get("rds-general-log")
 	if !contains(exports, "general") || (params["general_log"] != "1" && strings.ToUpper(params["general_log"]) != "ON") {
-		table.AddRow(tbl, []string{ruleGen.Service, "Fail", color.ColorizeLevel(ruleGen.Level), identifier, "Disabled", ruleGen.Issue})
+		table.AddResult(tbl, ruleGen, "Fail", identifier, "Disabled")
 	} else {
-		table.AddRow(tbl, []string{ruleGen.Service, "Pass", "-", identifier, "Enabled", ruleGen.Issue})
+		table.AddResult(tbl, ruleGen, "Pass", identifier, "Enabled")
 	}
 
 	// 2. Slow Query Log
 	// Req: Exported AND (slow_query_log=1 OR slow_query_log=ON)
 	ruleSlow := rules.Get("rds-slow-query-log")
 	if !contains(exports, "slowquery") || (params["slow_query_log"] != "1" && strings.ToUpper(params["slow_query_log"]) != "ON") {
-		table.AddRow(tbl, []string{ruleSlow.Service, "Fail", color.ColorizeLevel(ruleSlow.Level), identifier, "Disabled", ruleSlow.Issue})
+		table.AddResult(tbl, ruleSlow, "Fail", identifier, "Disabled")
 	} else {
-		table.AddRow(tbl, []string{ruleSlow.Service, "Pass", "-", identifier, "Enabled", ruleSlow.Issue})
+		table.AddResult(tbl, ruleSlow, "Pass", identifier, "Enabled")
 	}
 
 	// 3. Audit Log
@@ -326,9 +373,9 @@ func checkLogs(client api.RDSClient, pgName string, exports []string, identifier
 
 	ruleAudit := rules.Get("rds-audit-log")
 	if !contains(exports, "audit") || !auditEnabled {
-		table.AddRow(tbl, []string{ruleAudit.Service, "Fail", color.ColorizeLevel(ruleAudit.Level), identifier, "Disabled", ruleAudit.Issue})
+		table.AddResult(tbl, ruleAudit, "Fail", identifier, "Disabled")
 	} else {
-		table.AddRow(tbl, []string{ruleAudit.Service, "Pass", "-", identifier, "Enabled", ruleAudit.Issue})
+		table.AddResult(tbl, ruleAudit, "Pass", identifier, "Enabled")
 	}
 
 	// 4. Error Log
@@ -337,9 +384,9 @@ func checkLogs(client api.RDSClient, pgName string, exports []string, identifier
 	if !contains(exports, "error") && !contains(exports, "postgresql") && !contains(exports, "alert") { // Postgres uses 'postgresql', Oracle/MSSQL uses 'error'/'agent', MySql 'error'
 		// Loose check for any "error-like" log export presence if exact name varies,
 		// but 'error' is standard for MySQL. 'postgresql' for PG.
-		table.AddRow(tbl, []string{ruleErr.Service, "Fail", color.ColorizeLevel(ruleErr.Level), identifier, "Disabled", ruleErr.Issue})
+		table.AddResult(tbl, ruleErr, "Fail", identifier, "Disabled")
 	} else {
-		table.AddRow(tbl, []string{ruleErr.Service, "Pass", "-", identifier, "Enabled", ruleErr.Issue})
+		table.AddResult(tbl, ruleErr, "Pass", identifier, "Enabled")
 	}
 }
 
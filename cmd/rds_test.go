@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"awsselfrev/internal/config"
+	"awsselfrev/internal/table"
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRDSClient struct {
+	mock.Mock
+}
+
+func (m *MockRDSClient) DescribeDBClusters(ctx context.Context, params *rds.DescribeDBClustersInput, optFns ...func(*rds.Options)) (*rds.DescribeDBClustersOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	return args.Get(0).(*rds.DescribeDBClustersOutput), args.Error(1)
+}
+
+func (m *MockRDSClient) DescribeDBInstances(ctx context.Context, params *rds.DescribeDBInstancesInput, optFns ...func(*rds.Options)) (*rds.DescribeDBInstancesOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	return args.Get(0).(*rds.DescribeDBInstancesOutput), args.Error(1)
+}
+
+func (m *MockRDSClient) DescribeDBParameters(ctx context.Context, params *rds.DescribeDBParametersInput, optFns ...func(*rds.Options)) (*rds.DescribeDBParametersOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	return args.Get(0).(*rds.DescribeDBParametersOutput), args.Error(1)
+}
+
+func (m *MockRDSClient) DescribeDBClusterParameters(ctx context.Context, params *rds.DescribeDBClusterParametersInput, optFns ...func(*rds.Options)) (*rds.DescribeDBClusterParametersOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	return args.Get(0).(*rds.DescribeDBClusterParametersOutput), args.Error(1)
+}
+
+func rdsTestRules() config.RulesConfig {
+	rules := config.RulesConfig{Rules: map[string]config.Rule{}}
+	for _, key := range []string{
+		"rds-audit-log",
+		"rds-auto-minor-version-upgrade",
+		"rds-backup-enabled",
+		"rds-default-parameter-group",
+		"rds-deletion-protection",
+		"rds-error-log",
+		"rds-general-log",
+		"rds-maintenance-window",
+		"rds-performance-insights",
+		"rds-public-access",
+		"rds-slow-query-log",
+		"rds-storage-encryption",
+	} {
+		rules.Rules[key] = config.Rule{Service: "RDS", Level: "Warning"}
+	}
+	return rules
+}
+
+func TestCheckRDSConfigurationsStandaloneInstances(t *testing.T) {
+	client := new(MockRDSClient)
+	client.On("DescribeDBClusters", mock.Anything, mock.Anything, mock.Anything).Return(&rds.DescribeDBClustersOutput{
+		DBClusters: []types.DBCluster{
+			{
+				DBClusterIdentifier:   aws.String("aurora"),
+				StorageEncrypted:      aws.Bool(true),
+				DeletionProtection:    aws.Bool(true),
+				BackupRetentionPeriod: aws.Int32(7),
+				DBClusterMembers: []types.DBClusterMember{
+					{DBInstanceIdentifier: aws.String("aurora-1")},
+				},
+			},
+		},
+	}, nil)
+	client.On("DescribeDBInstances", mock.Anything, mock.Anything, mock.Anything).Return(&rds.DescribeDBInstancesOutput{
+		DBInstances: []types.DBInstance{
+			{
+				DBInstanceIdentifier:  aws.String("aurora-1"),
+				DBClusterIdentifier:   aws.String("aurora"),
+				StorageEncrypted:      aws.Bool(false),
+				BackupRetentionPeriod: aws.Int32(1),
+			},
+			{
+				DBInstanceIdentifier:  aws.String("mysql"),
+				StorageEncrypted:      aws.Bool(false),
+				DeletionProtection:    aws.Bool(false),
+				BackupRetentionPeriod: aws.Int32(0),
+			},
+			{
+				DBInstanceIdentifier:  aws.String("postgres"),
+				StorageEncrypted:      aws.Bool(true),
+				DeletionProtection:    aws.Bool(true),
+				BackupRetentionPeriod: aws.Int32(14),
+			},
+		},
+	}, nil)
+
+	tbl := table.SetTable()
+	checkRDSConfigurations(client, tbl, rdsTestRules())
+
+	results := make(map[string][]string)
+	for _, row := range tbl.Rows() {
+		switch row.RuleKey {
+		case "rds-storage-encryption", "rds-deletion-protection", "rds-backup-enabled":
+			results[row.Resource] = append(results[row.Resource], row.RuleKey+" "+row.Status+" "+row.Setting)
+		}
+	}
+
+	assert.Equal(t, map[string][]string{
+		"aurora": {
+			"rds-storage-encryption Pass Enabled",
+			"rds-deletion-protection Pass Enabled",
+			"rds-backup-enabled Pass 7 days",
+		},
+		"mysql": {
+			"rds-storage-encryption Fail Disabled",
+			"rds-deletion-protection Fail Disabled",
+			"rds-backup-enabled Fail 0 days",
+		},
+		"postgres": {
+			"rds-storage-encryption Pass Enabled",
+			"rds-deletion-protection Pass Enabled",
+			"rds-backup-enabled Pass 14 days",
+		},
+	}, results)
+}
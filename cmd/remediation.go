@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"awsselfrev/internal/config"
+	"awsselfrev/internal/remediation"
+	"awsselfrev/internal/table"
+)
+
+// emitRemediation is the --emit-remediation format, and remediationFile the
+// file it is written to.
+var (
+	emitRemediation string
+	remediationFile string
+)
+
+// writeRemediation writes the suggested change for each failed result to
+// remediationFile, once per rule and resource.
+func writeRemediation(tbl *table.Table, rules config.RulesConfig) {
+	var findings []remediation.Finding
+	written := make(map[string]bool)
+	for _, row := range tbl.Rows() {
+		if row.Status != "Fail" || row.RuleKey == "" {
+			continue
+		}
+		id := row.RuleKey + "/" + row.Resource
+		if written[id] {
+			continue
+		}
+		written[id] = true
+		findings = append(findings, remediation.Finding{Rule: rules.Get(row.RuleKey), Resource: row.Resource})
+	}
+
+	f, err := os.Create(remediationFile)
+	if err != nil {
+		log.Fatalf("Failed to create %s: %v", remediationFile, err)
+	}
+	defer f.Close()
+	if err := remediation.Write(f, emitRemediation, AccountID, findings); err != nil {
+		log.Fatalf("Failed to write %s: %v", remediationFile, err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d remediation(s) to %s\n", len(findings), remediationFile)
+}
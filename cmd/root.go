@@ -2,17 +2,30 @@ package cmd
 
 import (
 	"awsselfrev/internal/config"
+	"awsselfrev/internal/notify"
+	"awsselfrev/internal/remediation"
 	"awsselfrev/internal/table"
 	"context"
 	"fmt"
+	"log"
 	"os"
+	"regexp"
+	"strings"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/spf13/cobra"
 )
 
 var Version = "dev"
 var AccountID string
+var Region string
+
+// Partition is the partition of the account, e.g. "aws" or "aws-cn", taken
+// from the ARN of the caller identity. It is used to build resource ARNs.
+var Partition = "aws"
+
+var accountIDPattern = regexp.MustCompile(`^\d{12}$`)
 
 var rootCmd = &cobra.Command{
 	Use:   "awsselfrev",
@@ -26,17 +39,109 @@ S3, RDS, EC2, and VPC—and provides a consolidated report on their current stat
 		client := sts.NewFromConfig(cfg)
 		identity, err := client.GetCallerIdentity(context.TODO(), &sts.GetCallerIdentityInput{})
 		if err != nil {
-			fmt.Printf("Failed to get AWS identity: %v\n", err)
-			return
+			// Keep standard output for the results (e.g. the inventory JSON).
+			log.Printf("Warning: Failed to get AWS identity: %v", err)
+		} else {
+			fmt.Fprintf(cmd.OutOrStdout(), "Executing on AWS Account: %s\n", *identity.Account)
+			AccountID = *identity.Account
+			if p, _, ok := strings.Cut(strings.TrimPrefix(aws.ToString(identity.Arn), "arn:"), ":"); ok {
+				Partition = p
+			}
 		}
-		fmt.Printf("Executing on AWS Account: %s\n", *identity.Account)
-		AccountID = *identity.Account
+		Region = cfg.Region
 
-		failOnly, _ := cmd.Flags().GetBool("fail-only")
-		table.FailOnly = failOnly
+		applyFlags(cmd)
 	},
 }
 
+// applyFlags reads the persistent flags that control which rules and resources
+// are evaluated and how the results are shown. Commands that do not call AWS
+// (e.g. tf-plan) call it from their own PersistentPreRun.
+func applyFlags(cmd *cobra.Command) {
+	failOnly, _ := cmd.Flags().GetBool("fail-only")
+	table.FailOnly = failOnly
+
+	showRemediation, _ := cmd.Flags().GetBool("show-remediation")
+	table.ShowRemediation = showRemediation
+
+	groupBy, _ := cmd.Flags().GetString("group-by")
+	sortBy, _ := cmd.Flags().GetString("sort")
+	if err := table.SetOrder(groupBy, sortBy); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	include, _ := cmd.Flags().GetString("rules")
+	exclude, _ := cmd.Flags().GetString("exclude-rules")
+	minLevel, _ := cmd.Flags().GetString("min-level")
+	filter, err := config.ParseFilter(include, exclude, minLevel)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	config.RuleFilter = filter
+
+	resourceFilters, _ := cmd.Flags().GetStringArray("resource-filter")
+	resources, err := config.ParseResourceFilter(resourceFilters)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	config.Resources = resources
+
+	logBucketValues, _ := cmd.Flags().GetStringArray("log-bucket")
+	logBuckets, err := config.ParseBucketClassifier("--log-bucket", logBucketValues, config.DefaultLogBucketPattern)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	config.LogBuckets = logBuckets
+
+	criticalBucketValues, _ := cmd.Flags().GetStringArray("critical-bucket")
+	criticalBuckets, err := config.ParseBucketClassifier("--critical-bucket", criticalBucketValues, config.DefaultCriticalBucketSelector)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	config.CriticalBuckets = criticalBuckets
+
+	trusted, _ := cmd.Flags().GetString("trusted-accounts")
+	trustedAccounts = make(map[string]bool)
+	for _, account := range strings.Split(trusted, ",") {
+		account = strings.TrimSpace(account)
+		if account == "" {
+			continue
+		}
+		if !accountIDPattern.MatchString(account) {
+			log.Fatalf("invalid --trusted-accounts value %q (must be 12-digit account IDs)", account)
+		}
+		trustedAccounts[account] = true
+	}
+
+	policyPaths, _ = cmd.Flags().GetStringArray("policy")
+
+	recordHistory, _ = cmd.Flags().GetBool("record-history")
+	historyFile, _ = cmd.Flags().GetString("history-file")
+	runFilterFlags = runFilters(include, exclude, minLevel, resourceFilters)
+
+	notifyValues, _ := cmd.Flags().GetStringArray("notify")
+	notifiers = nil
+	for _, value := range notifyValues {
+		n, err := notify.ParseNotifier(value)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		notifiers = append(notifiers, n)
+	}
+	notifyBaseline, _ = cmd.Flags().GetString("notify-baseline")
+
+	emitRemediation, _ = cmd.Flags().GetString("emit-remediation")
+	if emitRemediation != "" {
+		if err := remediation.ValidateFormat(emitRemediation); err != nil {
+			log.Fatalf("%v", err)
+		}
+		remediationFile, _ = cmd.Flags().GetString("remediation-file")
+		if remediationFile == "" {
+			remediationFile = remediation.DefaultPath(emitRemediation)
+		}
+	}
+}
+
 func Execute() {
 	err := rootCmd.Execute()
 	if err != nil {
@@ -46,4 +151,21 @@ func Execute() {
 
 func init() {
 	rootCmd.PersistentFlags().BoolP("fail-only", "f", false, "Show only failed checks")
+	rootCmd.PersistentFlags().Bool("show-remediation", false, "Show remediation guidance for failed checks")
+	rootCmd.PersistentFlags().String("group-by", "", "Group rows by service, resource, rule, level or status")
+	rootCmd.PersistentFlags().String("sort", "", "Sort rows by comma-separated keys (service, resource, rule, level, status)")
+	rootCmd.PersistentFlags().String("rules", "", "Only evaluate these comma-separated rule keys")
+	rootCmd.PersistentFlags().String("exclude-rules", "", "Skip these comma-separated rule keys")
+	rootCmd.PersistentFlags().String("min-level", "", "Only evaluate rules at or above this level (Info, Warning, Alert)")
+	rootCmd.PersistentFlags().StringArray("resource-filter", nil, "Only check resources matching a name glob, re:<regex>, arn:<glob> or tag:Key[=Value] (repeatable)")
+	rootCmd.PersistentFlags().StringArray("log-bucket", nil, "Treat S3 buckets matching a name glob, re:<regex> or tag:Key[=Value] as log buckets (repeatable; default "+config.DefaultLogBucketPattern+")")
+	rootCmd.PersistentFlags().StringArray("critical-bucket", nil, "Require MFA delete, cross-region replication and event notifications on S3 buckets matching a name glob, re:<regex> or tag:Key[=Value] (repeatable; default "+config.DefaultCriticalBucketSelector+")")
+	rootCmd.PersistentFlags().String("trusted-accounts", "", "Comma-separated account IDs that bucket policies may grant access to")
+	rootCmd.PersistentFlags().StringArray("policy", nil, "Evaluate collected resources against Rego policies in this file or directory with opa (repeatable)")
+	rootCmd.PersistentFlags().Bool("record-history", false, "Append the failed checks of this run to the history file")
+	rootCmd.PersistentFlags().String("history-file", "history.jsonl", "History file written by --record-history and read by the history command")
+	rootCmd.PersistentFlags().StringArray("notify", nil, "Post a digest of failed checks to a webhook: [webhook=|slack=|teams=]<url> (repeatable)")
+	rootCmd.PersistentFlags().String("notify-baseline", "", "Only notify failed checks missing from this file, then update it")
+	rootCmd.PersistentFlags().String("emit-remediation", "", "Write a suggested change for each failed check as a terraform or awscli file")
+	rootCmd.PersistentFlags().String("remediation-file", "", "File written by --emit-remediation (default remediation.tf or remediation.sh)")
 }
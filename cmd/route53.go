@@ -2,15 +2,17 @@ package cmd
 
 import (
 	"context"
-	"log"
+	"strings"
 
 	"awsselfrev/internal/aws/api"
 	"awsselfrev/internal/color"
 	"awsselfrev/internal/config"
+	"awsselfrev/internal/inventory"
 	"awsselfrev/internal/table"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/route53"
-	"github.com/olekukonko/tablewriter"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
 	"github.com/spf13/cobra"
 )
 
@@ -28,7 +30,7 @@ var route53Cmd = &cobra.Command{
 
 		checkRoute53Configurations(client, tbl, rules)
 
-		table.Render("Route53", tbl)
+		renderResults("Route53", tbl, rules)
 	},
 }
 
@@ -36,11 +38,27 @@ func init() {
 	rootCmd.AddCommand(route53Cmd)
 }
 
-func checkRoute53Configurations(client api.Route53Client, tbl *tablewriter.Table, rules config.RulesConfig) {
+func hostedZoneTags(client api.Route53Client, zoneID string) func() map[string]string {
+	return lazyTags("hosted zone "+zoneID, func() (map[string]string, error) {
+		resp, err := client.ListTagsForResource(context.TODO(), &route53.ListTagsForResourceInput{
+			ResourceId:   aws.String(zoneID),
+			ResourceType: types.TagResourceTypeHostedzone,
+		})
+		if err != nil || resp.ResourceTagSet == nil {
+			return nil, err
+		}
+		return tagMap(resp.ResourceTagSet.Tags, func(t types.Tag) (*string, *string) { return t.Key, t.Value }), nil
+	})
+}
+
+func checkRoute53Configurations(client api.Route53Client, tbl *table.Table, rules config.RulesConfig) {
+	if !needsService(rules, "Route53") {
+		return
+	}
 	// List Hosted Zones
 	zones, err := client.ListHostedZones(context.TODO(), &route53.ListHostedZonesInput{})
 	if err != nil {
-		log.Fatalf("Failed to list hosted zones: %v", err)
+		fatalf("Failed to list hosted zones: %v", err)
 	}
 
 	if len(zones.HostedZones) == 0 {
@@ -49,6 +67,11 @@ func checkRoute53Configurations(client api.Route53Client, tbl *tablewriter.Table
 	}
 
 	for _, zone := range zones.HostedZones {
+		zoneID := strings.TrimPrefix(*zone.Id, "/hostedzone/")
+		if !config.Resources.Match(*zone.Name, "arn:"+Partition+":route53:::hostedzone/"+zoneID, hostedZoneTags(client, zoneID)) {
+			continue
+		}
+
 		// Public zones do not necessarily need query logging, but the requirement was "Route53 Query Logs enabled"
 		// Typically this applies to public zones or useful for auditing. The requirement didn't specify public/private.
 		// We will check if query logging config exists for the zone.
@@ -62,14 +85,15 @@ func checkRoute53Configurations(client api.Route53Client, tbl *tablewriter.Table
 			HostedZoneId: zone.Id,
 		})
 		if err != nil {
-			log.Fatalf("Failed to list query logging configs for zone %s: %v", *zone.Id, err)
+			fatalf("Failed to list query logging configs for zone %s: %v", *zone.Id, err)
 		}
 
 		rule := rules.Get("route53-query-logging")
 		if len(configs.QueryLoggingConfigs) == 0 {
-			table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), *zone.Name, "Disabled", rule.Issue})
+			table.AddResult(tbl, rule, "Fail", *zone.Name, "Disabled")
 		} else {
-			table.AddRow(tbl, []string{rule.Service, "Pass", "-", *zone.Name, "Enabled", rule.Issue})
+			table.AddResult(tbl, rule, "Pass", *zone.Name, "Enabled")
 		}
+		collectResource(tbl, rules, inventory.TypeHostedZone, *zone.Name, "arn:"+Partition+":route53:::hostedzone/"+zoneID, zone)
 	}
 }
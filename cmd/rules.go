@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"awsselfrev/internal/color"
+	"awsselfrev/internal/config"
+	"awsselfrev/internal/custom"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+// ruleKeys lists every rule key the checks look up with rules.Get.
+// Keep it in sync when adding a check; TestRuleKeysMatchChecks enforces this.
+var ruleKeys = []string{
+	"alb-access-logging",
+	"alb-connection-logging",
+	"alb-deletion-protection",
+	"cloudfront-logging-enabled",
+	"cloudwatch-log-group-encryption",
+	"cloudwatch-retention",
+	"ec2-ebs-default-encryption",
+	"ec2-snapshot-encryption",
+	"ec2-volume-encryption",
+	"ecr-image-scanning",
+	"ecr-lifecycle-policy",
+	"ecr-tag-immutability",
+	"ecs-container-insights",
+	"ecs-cpu-architecture",
+	"ecs-exec-logging",
+	"ecs-propagate-tags",
+	"ecs-sensitive-environment-variables",
+	"ecs-service-circuit-breaker",
+	"elb-target-health",
+	"rds-audit-log",
+	"rds-auto-minor-version-upgrade",
+	"rds-backup-enabled",
+	"rds-default-parameter-group",
+	"rds-deletion-protection",
+	"rds-error-log",
+	"rds-general-log",
+	"rds-maintenance-window",
+	"rds-performance-insights",
+	"rds-public-access",
+	"rds-slow-query-log",
+	"rds-storage-encryption",
+	"route53-query-logging",
+	"s3-access-point-policy",
+	"s3-access-point-vpc",
+	"s3-acl-grants",
+	"s3-acl-public",
+	"s3-bucket-key",
+	"s3-encryption",
+	"s3-event-notifications",
+	"s3-kms-customer-managed",
+	"s3-kms-key",
+	"s3-kms-key-rotation",
+	"s3-lifecycle",
+	"s3-mfa-delete",
+	"s3-mrap-public-access",
+	"s3-noncurrent-version-expiration",
+	"s3-object-lock",
+	"s3-object-ownership",
+	"s3-policy-cross-account",
+	"s3-policy-public",
+	"s3-public-access",
+	"s3-replication",
+	"s3-secure-transport",
+	"s3-server-access-logging",
+	"s3-sse-kms-encryption",
+	"s3-storage-lens-enabled",
+	"s3-versioning",
+	"telemetry-resource-tags-enabled",
+	"vpc-dns-hostname",
+	"vpc-dns-support",
+	"vpc-flow-logs",
+	"vpc-flow-logs-custom-format",
+	"vpc-name-tag",
+	"wafv2-logging-enabled",
+}
+
+var rulesCmd = &cobra.Command{
+	Use:   "rules",
+	Short: "List and validate the rule catalog",
+	Long: `The "rules" command lists every rule in rules.yaml with its service, level,
+issue and whether it is enabled.
+
+It also validates the rules file against the checks: keys the checks need but
+the file lacks are reported as errors (the command exits with status 1), and
+keys no check refers to are reported as warnings. Custom rules (rules with a
+"resource" field) are checked for a known resource type and valid expressions.`,
+	// rules only reads rules.yaml, so it does not need AWS credentials.
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	Run: func(cmd *cobra.Command, args []string) {
+		rules := config.LoadRules()
+
+		renderRules(rules)
+
+		missing, unused := rules.Validate(ruleKeys)
+		for _, key := range unused {
+			fmt.Printf("Warning: rule %q is not used by any check\n", key)
+		}
+		for _, key := range missing {
+			fmt.Printf("Error: rule %q is used by a check but missing from rules.yaml\n", key)
+		}
+		invalid := validateCustomRules(rules)
+		if len(missing) > 0 || invalid {
+			os.Exit(1)
+		}
+	},
+}
+
+// validateCustomRules prints an error for every custom rule that cannot be
+// evaluated and reports whether there was any.
+func validateCustomRules(rules config.RulesConfig) bool {
+	keys := make([]string, 0, len(rules.Rules))
+	for key, rule := range rules.Rules {
+		if rule.IsCustom() {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	invalid := false
+	for _, key := range keys {
+		if err := custom.Validate(rules.Rules[key]); err != nil {
+			fmt.Printf("Error: custom rule %q: %v\n", key, err)
+			invalid = true
+		}
+	}
+	return invalid
+}
+
+func renderRules(rules config.RulesConfig) {
+	keys := make([]string, 0, len(rules.Rules))
+	for key := range rules.Rules {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := rules.Rules[keys[i]], rules.Rules[keys[j]]
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+		return keys[i] < keys[j]
+	})
+
+	tbl := tablewriter.NewWriter(os.Stdout)
+	tbl.SetAutoWrapText(false)
+	tbl.SetHeader([]string{"KEY", "SERVICE", "LEVEL", "ISSUE", "ENABLED"})
+	for _, key := range keys {
+		rule := rules.Rules[key]
+		tbl.Append([]string{key, rule.Service, color.ColorizeLevel(rule.Level), rule.Issue, strconv.FormatBool(rule.IsEnabled())})
+	}
+	tbl.Render()
+}
+
+func init() {
+	rootCmd.AddCommand(rulesCmd)
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"awsselfrev/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRuleKeysMatchChecks(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	assert.NoError(t, err)
+
+	re := regexp.MustCompile(`rules\.Get\("([^"]+)"\)`)
+	found := make(map[string]bool)
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		assert.NoError(t, err)
+		for _, m := range re.FindAllStringSubmatch(string(data), -1) {
+			found[m[1]] = true
+		}
+	}
+
+	var referenced []string
+	for key := range found {
+		referenced = append(referenced, key)
+	}
+	sort.Strings(referenced)
+
+	expected := append([]string(nil), ruleKeys...)
+	sort.Strings(expected)
+	assert.Equal(t, expected, referenced)
+}
+
+func TestRulesFileCoversChecks(t *testing.T) {
+	data, err := os.ReadFile("../rules.yaml")
+	assert.NoError(t, err)
+
+	var rules config.RulesConfig
+	assert.NoError(t, yaml.Unmarshal(data, &rules))
+
+	missing, _ := rules.Validate(ruleKeys)
+	assert.Empty(t, missing)
+}
@@ -1,15 +1,29 @@
 package cmd
 
 import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+
 	"awsselfrev/internal/aws/api"
+	kmsInternal "awsselfrev/internal/aws/service/kms"
 	s3Internal "awsselfrev/internal/aws/service/s3"
 	"awsselfrev/internal/color"
 	"awsselfrev/internal/config"
+	"awsselfrev/internal/inventory"
 	"awsselfrev/internal/table"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/aws-sdk-go-v2/service/s3control"
-	"github.com/olekukonko/tablewriter"
+	s3controltypes "github.com/aws/aws-sdk-go-v2/service/s3control/types"
 	"github.com/spf13/cobra"
 )
 
@@ -19,69 +33,659 @@ var s3Cmd = &cobra.Command{
 	Long: `The "s3" command allows you to check various configurations of your S3 buckets.
 
 It retrieves information about your S3 buckets and checks for encryption, public access block settings,
-and lifecycle rules for buckets with 'log' in their names. The results are displayed in a table format.`,
+and lifecycle rules for log buckets. Log buckets are those matching --log-bucket (by default, names with
+"log", "logs" or "logging" as a word) and those that S3 server access logs, load balancer logs,
+CloudFront logs or VPC flow logs are delivered to. Bucket policies and ACLs are checked for
+public access, access from accounts outside --trusted-accounts and a deny of insecure transport.
+The KMS key of SSE-KMS buckets must exist, be enabled, customer managed and rotated, and the
+buckets must use S3 Bucket Keys.
+Access points must be restricted to a VPC, and access point and Multi-Region Access Point
+policies must not allow everyone or every S3 action.
+At least one S3 Storage Lens configuration must have advanced metrics, cover every region and
+export its metrics to a bucket.
+Each bucket is checked through the S3 endpoint of its own region, whatever region is configured.
+The results are displayed in a table format.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		cfg := config.LoadConfig()
 		rules := config.LoadRules()
 		tbl := table.SetTable()
-		client := s3.NewFromConfig(cfg)
-		controlClient := s3control.NewFromConfig(cfg)
 		_, _, _ = color.SetLevelColor() // Colors are now handling in table rendering or we just pass strings.
 
-		checkS3Configurations(client, controlClient, tbl, rules)
+		checkS3Configurations(newS3Clients(cfg), newKMSKeys(cfg), newS3ControlClients(cfg), findLogTargets(newLogSourceClients(cfg), rules), tbl, rules)
 
-		table.Render("S3", tbl)
+		renderResults("S3", tbl, rules)
 	},
 }
 
-func checkS3Configurations(client api.S3Client, controlClient api.S3ControlClient, tbl *tablewriter.Table, rules config.RulesConfig) {
-	checkS3StorageLens(controlClient, tbl, rules)
-	buckets := s3Internal.ListBuckets(client)
+// newS3Clients returns S3 clients of cfg for each region buckets are in.
+func newS3Clients(cfg aws.Config) *s3Internal.RegionalClients {
+	return s3Internal.NewRegionalClients(func(region string) api.S3Client {
+		return s3.NewFromConfig(cfg, func(o *s3.Options) {
+			if region != "" {
+				o.Region = region
+			}
+		})
+	})
+}
+
+// newS3ControlClients returns S3 Control clients of cfg for each region.
+func newS3ControlClients(cfg aws.Config) *s3Internal.ControlClients {
+	return s3Internal.NewControlClients(func(region string) api.S3ControlClient {
+		return s3control.NewFromConfig(cfg, func(o *s3control.Options) {
+			if region != "" {
+				o.Region = region
+			}
+		})
+	})
+}
+
+// newKMSKeys returns a lookup of the KMS keys buckets are encrypted with.
+func newKMSKeys(cfg aws.Config) *kmsInternal.Keys {
+	return kmsInternal.NewKeys(func(region string) api.KMSClient {
+		return kms.NewFromConfig(cfg, func(o *kms.Options) {
+			if region != "" {
+				o.Region = region
+			}
+		})
+	})
+}
+
+// checkS3Configurations checks every bucket, each with a client of the
+// bucket's region. targets are the buckets other services deliver logs to
+// (see findLogTargets); the targets of server access logging are added here.
+// Storage Lens and the access points of the buckets' regions are checked last.
+func checkS3Configurations(clients *s3Internal.RegionalClients, keys *kmsInternal.Keys, controls *s3Internal.ControlClients, targets s3Internal.LogTargets, tbl *table.Table, rules config.RulesConfig) {
+	if !needsService(rules, "S3") {
+		return
+	}
+	var accountBlock s3Internal.PublicAccessBlock
+	if needs(rules, s3PublicAccessRules...) {
+		accountBlock = s3Internal.GetAccountPublicAccessBlock(controls.For(""), AccountID)
+	}
+	buckets := s3Internal.ListBuckets(clients.For(""))
 	if len(buckets) == 0 {
+		checkS3StorageLens(controls, []string{Region}, tbl, rules)
 		table.AddRow(tbl, []string{"S3", "-", "-", "No buckets", "-", "-"})
 		return
 	}
+
+	// Every bucket's logging is read first: a bucket filtered out by
+	// --resource-filter can still make another one a log bucket.
+	logTargets := make(s3Internal.LogTargets)
+	for bucket, source := range targets {
+		logTargets.Add(bucket, source)
+	}
+	regions := make(map[string]string)
+	accessLogging := make(map[string]bool)
+	classify := needs(rules, s3LogBucketRules...)
 	for _, bucket := range buckets {
-		checkBucketConfigurations(client, bucket, tbl, rules)
+		region, err := s3Internal.GetBucketRegion(clients.Location(Partition), bucket)
+		if err != nil {
+			// Requests sent to another region than the bucket's fail, so the
+			// bucket is not checked.
+			log.Printf("Warning: Failed to get the region of bucket %s: %v", bucket, err)
+			continue
+		}
+		regions[bucket] = region
+		if !classify {
+			continue
+		}
+		enabled, target := s3Internal.GetServerAccessLogging(clients.For(region), bucket)
+		accessLogging[bucket] = enabled
+		logTargets.Add(target, "S3 server access logs of "+bucket)
 	}
+
+	for _, bucket := range buckets {
+		region, ok := regions[bucket]
+		if !ok {
+			// Without the region, the tags cannot be read either.
+			if config.Resources.Match(bucket, "arn:"+Partition+":s3:::"+bucket, nil) {
+				table.AddRow(tbl, []string{"S3", "-", "-", bucket, "Region unknown", "-"})
+			}
+			continue
+		}
+		client := clients.For(region)
+		var bucketTags map[string]string
+		tags := func() map[string]string {
+			if bucketTags == nil {
+				bucketTags = s3Internal.GetBucketTags(client, bucket)
+			}
+			return bucketTags
+		}
+		if !config.Resources.Match(bucket, "arn:"+Partition+":s3:::"+bucket, tags) {
+			continue
+		}
+		b := s3Bucket{
+			Name:                bucket,
+			Region:              region,
+			ServerAccessLogging: accessLogging[bucket],
+		}
+		if classify {
+			b.LogBucket = s3Internal.IsLogBucket(bucket, tags, logTargets)
+		}
+		if needs(rules, "s3-mfa-delete", "s3-replication", "s3-event-notifications") {
+			b.Critical = config.CriticalBuckets.Match(bucket, tags)
+		}
+		doc := checkBucketConfigurations(clients, keys, b, accountBlock, tbl, rules)
+		collectResource(tbl, rules, inventory.TypeS3Bucket, bucket, "arn:"+Partition+":s3:::"+bucket, doc)
+	}
+
+	// Storage Lens configurations and access points are looked up in the
+	// configured region and the regions of the buckets.
+	seen := map[string]bool{Region: true}
+	bucketRegions := []string{Region}
+	for _, region := range regions {
+		if region != "" && !seen[region] {
+			seen[region] = true
+			bucketRegions = append(bucketRegions, region)
+		}
+	}
+	sort.Strings(bucketRegions)
+	checkS3StorageLens(controls, bucketRegions, tbl, rules)
+	checkS3AccessPoints(controls, bucketRegions, accountBlock, tbl, rules)
 }
 
-func checkBucketConfigurations(client api.S3Client, bucket string, tbl *tablewriter.Table, rules config.RulesConfig) {
-	ruleEnc := rules.Get("s3-encryption")
-	if !s3Internal.IsBucketEncrypted(client, bucket) {
-		table.AddRow(tbl, []string{ruleEnc.Service, "Fail", color.ColorizeLevel(ruleEnc.Level), bucket, "Disabled", ruleEnc.Issue})
+// s3PublicAccessRules are the rules that read the Block Public Access
+// settings.
+var s3PublicAccessRules = []string{"s3-public-access", "s3-policy-public", "s3-acl-public", "s3-access-point-policy", "s3-mrap-public-access"}
+
+// s3LogBucketRules are the rules whose result depends on whether a bucket is a
+// log bucket. Without them, log destinations are not looked up.
+var s3LogBucketRules = []string{"s3-lifecycle", "s3-object-lock", "s3-sse-kms-encryption", "s3-server-access-logging"}
+
+// s3PolicyRules are the rules that read the bucket policy.
+var s3PolicyRules = []string{"s3-policy-public", "s3-policy-cross-account", "s3-secure-transport"}
+
+// checkS3AccessPoints checks the access points of the regions and the
+// Multi-Region Access Points. accountBlock is the account-level Block Public
+// Access configuration, which applies to access points too.
+func checkS3AccessPoints(controls *s3Internal.ControlClients, regions []string, accountBlock s3Internal.PublicAccessBlock, tbl *table.Table, rules config.RulesConfig) {
+	if !needs(rules, "s3-access-point-vpc", "s3-access-point-policy", "s3-mrap-public-access") {
+		return
+	}
+	checkPolicies := needs(rules, "s3-access-point-policy")
+	ruleVPC := rules.Get("s3-access-point-vpc")
+	for _, region := range regions {
+		client := controls.For(region)
+		for _, ap := range s3Internal.ListAccessPoints(client, AccountID) {
+			name := aws.ToString(ap.Name)
+			arn := aws.ToString(ap.AccessPointArn)
+			if !config.Resources.Match(name, arn, nil) {
+				continue
+			}
+			if ap.NetworkOrigin == s3controltypes.NetworkOriginVpc && ap.VpcConfiguration != nil {
+				table.AddResult(tbl, ruleVPC, "Pass", name, "VPC: "+aws.ToString(ap.VpcConfiguration.VpcId))
+			} else {
+				table.AddResult(tbl, ruleVPC, "Fail", name, "Internet")
+			}
+			if checkPolicies {
+				block := s3Internal.GetAccessPointPublicAccessBlock(client, AccountID, name).Merge(accountBlock)
+				if policy, ok := s3Internal.GetAccessPointPolicy(client, AccountID, name); ok {
+					checkAccessPointPolicy(policy, block, name, tbl, rules)
+				}
+			}
+			collectResource(tbl, rules, inventory.TypeS3AccessPoint, name, arn, ap)
+		}
+	}
+
+	ruleMRAP := rules.Get("s3-mrap-public-access")
+	client, ok := controls.MultiRegion(Partition)
+	if !ok {
+		if ruleMRAP.IsEnabled() {
+			table.AddRow(tbl, []string{"S3", "-", "-", "Multi-Region Access Points", "Not available in " + Partition, "-"})
+		}
+		return
+	}
+	for _, mrap := range s3Internal.ListMultiRegionAccessPoints(client, AccountID) {
+		name := aws.ToString(mrap.Name)
+		arn := "arn:" + Partition + ":s3::" + AccountID + ":accesspoint/" + aws.ToString(mrap.Alias)
+		if !config.Resources.Match(name, arn, nil) {
+			continue
+		}
+		// The settings are fixed when the Multi-Region Access Point is created.
+		block := s3Internal.ControlPublicAccessBlock(mrap.PublicAccessBlock).Merge(accountBlock)
+		if off := block.Off(); len(off) > 0 {
+			table.AddResult(tbl, ruleMRAP, "Fail", name, "Off: "+strings.Join(off, ", "))
+		} else {
+			table.AddResult(tbl, ruleMRAP, "Pass", name, "Enabled")
+		}
+		if checkPolicies {
+			if policy, ok := s3Internal.GetMultiRegionAccessPointPolicy(client, AccountID, name); ok {
+				checkAccessPointPolicy(policy, block, name, tbl, rules)
+			}
+		}
+		collectResource(tbl, rules, inventory.TypeS3MultiRegionAccessPoint, name, arn, mrap)
+	}
+}
+
+// checkAccessPointPolicy checks an access point policy for statements open to
+// everyone and statements granting every S3 action. policy is nil when the
+// access point has none. With RestrictPublicBuckets on in block, public
+// statements do not let anyone outside the account in.
+func checkAccessPointPolicy(policy *s3Internal.Policy, block s3Internal.PublicAccessBlock, resource string, tbl *table.Table, rules config.RulesConfig) {
+	rule := rules.Get("s3-access-point-policy")
+	if policy == nil {
+		table.AddResult(tbl, rule, "Pass", resource, "No policy")
+		return
+	}
+	var problems []string
+	if public := policy.PublicStatements(); len(public) > 0 && !block.RestrictPublicBuckets {
+		problems = append(problems, "Public: "+strings.Join(public, ", "))
+	}
+	if wildcard := policy.WildcardActionStatements(); len(wildcard) > 0 {
+		problems = append(problems, "All actions: "+strings.Join(wildcard, ", "))
+	}
+	if len(problems) > 0 {
+		table.AddResult(tbl, rule, "Fail", resource, strings.Join(problems, "; "))
 	} else {
-		table.AddRow(tbl, []string{ruleEnc.Service, "Pass", "-", bucket, "Enabled", ruleEnc.Issue})
+		table.AddResult(tbl, rule, "Pass", resource, "No wildcards")
+	}
+}
+
+// s3Bucket is what is known about a bucket before its checks run.
+type s3Bucket struct {
+	Name string
+	// Region is the region of the bucket; "" uses the client of the
+	// configured region.
+	Region string
+	// LogBucket is set for buckets that store logs. Lifecycle and Object Lock
+	// are only required for them, and SSE-KMS and server access logging only
+	// for the others.
+	LogBucket bool
+	// Critical is set for the buckets that must have MFA delete,
+	// cross-region replication and event notifications (--critical-bucket).
+	Critical            bool
+	ServerAccessLogging bool
+}
+
+// checkBucketConfigurations checks the bucket and returns its settings as the
+// document collected for custom rules, policies and the inventory.
+// accountBlock is the account-level Block Public Access configuration.
+func checkBucketConfigurations(clients *s3Internal.RegionalClients, keys *kmsInternal.Keys, b s3Bucket, accountBlock s3Internal.PublicAccessBlock, tbl *table.Table, rules config.RulesConfig) map[string]interface{} {
+	bucket := b.Name
+	client := clients.For(b.Region)
+	doc := make(map[string]interface{})
+	if needs(rules, "s3-encryption") {
+		encrypted := s3Internal.IsBucketEncrypted(client, bucket)
+		ruleEnc := rules.Get("s3-encryption")
+		if !encrypted {
+			table.AddResult(tbl, ruleEnc, "Fail", bucket, "Disabled")
+		} else {
+			table.AddResult(tbl, ruleEnc, "Pass", bucket, "Enabled")
+		}
+		doc["Encrypted"] = encrypted
+	}
+	var effectiveBlock s3Internal.PublicAccessBlock
+	if needs(rules, "s3-public-access", "s3-policy-public", "s3-acl-public") {
+		bucketBlock := s3Internal.GetBucketPublicAccessBlock(client, bucket)
+		doc["PublicAccessBlock"] = checkPublicAccessBlock(bucketBlock, accountBlock, bucket, tbl, rules)
+		effectiveBlock = bucketBlock.Merge(accountBlock)
+		// The effective settings, combining the bucket and account levels.
+		doc["PublicAccessBlockConfiguration"] = effectiveBlock
+	}
+	if needs(rules, s3PolicyRules...) {
+		if policy, ok := s3Internal.GetBucketPolicy(client, bucket); !ok {
+			// A policy that cannot be parsed cannot be shown to be safe.
+			for _, key := range s3PolicyRules {
+				table.AddResult(tbl, rules.Get(key), "Fail", bucket, "Cannot parse policy")
+			}
+		} else {
+			checkBucketPolicy(policy, effectiveBlock, bucket, tbl, rules)
+			doc["HasPolicy"] = policy != nil
+			if policy != nil {
+				doc["PublicPolicyStatements"] = policy.PublicStatements()
+				doc["PolicyAccounts"] = policy.Accounts()
+				doc["DeniesInsecureTransport"] = policy.DeniesInsecureTransport()
+			}
+		}
+	}
+	if needs(rules, "s3-object-ownership") {
+		ownership := s3Internal.GetObjectOwnership(client, bucket)
+		checkObjectOwnership(ownership, bucket, tbl, rules)
+		doc["ObjectOwnership"] = ownership
+	}
+	if needs(rules, "s3-acl-public", "s3-acl-grants") {
+		acl := s3Internal.GetBucketACL(client, bucket)
+		checkBucketACL(acl, effectiveBlock, bucket, tbl, rules)
+		doc["PublicACLGrants"] = acl.PublicGrants()
+		doc["ACLGrants"] = acl.ExtraGrants()
+	}
+	if needs(rules, "s3-versioning", "s3-noncurrent-version-expiration", "s3-mfa-delete") {
+		checkBucketVersioning(clients, b, doc, tbl, rules)
+	}
+	if needs(rules, "s3-replication") {
+		checkBucketReplication(clients, b, doc, tbl, rules)
 	}
-	rulePub := rules.Get("s3-public-access")
-	if !s3Internal.IsBlockPublicAccessEnabled(client, bucket) {
-		table.AddRow(tbl, []string{rulePub.Service, "Fail", color.ColorizeLevel(rulePub.Level), bucket, "Disabled", rulePub.Issue})
+	if needs(rules, "s3-event-notifications") {
+		checkBucketNotifications(clients, b, doc, tbl, rules)
+	}
+	var encryption s3Internal.DefaultEncryption
+	if needs(rules, "s3-sse-kms-encryption", "s3-bucket-key", "s3-kms-key", "s3-kms-customer-managed", "s3-kms-key-rotation") {
+		encryption = s3Internal.GetBucketDefaultEncryption(client, bucket)
+		checkBucketKMSKey(keys, b, encryption, doc, tbl, rules)
+	}
+	// Checks that do not apply to the bucket pass.
+	lifecycle, objectLock, sseKMS, accessLogging := true, true, true, true
+	if b.LogBucket {
+		if needs(rules, "s3-lifecycle") {
+			lifecycle = s3Internal.IsLifecycleConfigured(client, bucket)
+		}
+		if needs(rules, "s3-object-lock") {
+			objectLock = s3Internal.IsObjectLockEnabled(client, bucket)
+		}
 	} else {
-		table.AddRow(tbl, []string{rulePub.Service, "Pass", "-", bucket, "Enabled", rulePub.Issue})
+		sseKMS = encryption.KMS()
+		accessLogging = b.ServerAccessLogging
 	}
 	ruleLife := rules.Get("s3-lifecycle")
-	if !s3Internal.IsLifeCycleRuleConfiguredLogBucket(client, bucket) {
-		table.AddRow(tbl, []string{ruleLife.Service, "Fail", color.ColorizeLevel(ruleLife.Level), bucket, "Disabled", ruleLife.Issue})
+	if !lifecycle {
+		table.AddResult(tbl, ruleLife, "Fail", bucket, "Disabled")
 	} else {
-		table.AddRow(tbl, []string{ruleLife.Service, "Pass", "-", bucket, "Enabled", ruleLife.Issue})
+		table.AddResult(tbl, ruleLife, "Pass", bucket, "Enabled")
 	}
 	ruleLock := rules.Get("s3-object-lock")
-	if !s3Internal.IsObjectLockEnabled(client, bucket) {
-		table.AddRow(tbl, []string{ruleLock.Service, "Fail", color.ColorizeLevel(ruleLock.Level), bucket, "Disabled", ruleLock.Issue})
+	if !objectLock {
+		table.AddResult(tbl, ruleLock, "Fail", bucket, "Disabled")
 	} else {
-		table.AddRow(tbl, []string{ruleLock.Service, "Pass", "-", bucket, "Enabled", ruleLock.Issue})
+		table.AddResult(tbl, ruleLock, "Pass", bucket, "Enabled")
 	}
 	ruleKms := rules.Get("s3-sse-kms-encryption")
-	if !s3Internal.IsBucketEncryptedWithKMS(client, bucket) {
-		table.AddRow(tbl, []string{ruleKms.Service, "Fail", color.ColorizeLevel(ruleKms.Level), bucket, "Disabled", ruleKms.Issue})
+	if !sseKMS {
+		table.AddResult(tbl, ruleKms, "Fail", bucket, "Disabled")
 	} else {
-		table.AddRow(tbl, []string{ruleKms.Service, "Pass", "-", bucket, "Enabled", ruleKms.Issue})
+		table.AddResult(tbl, ruleKms, "Pass", bucket, "Enabled")
 	}
 	ruleLog := rules.Get("s3-server-access-logging")
-	if !s3Internal.IsServerAccessLoggingEnabled(client, bucket) {
-		table.AddRow(tbl, []string{ruleLog.Service, "Fail", color.ColorizeLevel(ruleLog.Level), bucket, "Disabled", ruleLog.Issue})
+	if !accessLogging {
+		table.AddResult(tbl, ruleLog, "Fail", bucket, "Disabled")
+	} else {
+		table.AddResult(tbl, ruleLog, "Pass", bucket, "Enabled")
+	}
+
+	doc["Name"] = bucket
+	doc["Region"] = b.Region
+	doc["LogBucket"] = b.LogBucket
+	doc["Critical"] = b.Critical
+	doc["LogBucketLifecycle"] = lifecycle
+	doc["LogBucketObjectLock"] = objectLock
+	doc["SSEKMS"] = sseKMS
+	doc["ServerAccessLogging"] = accessLogging
+	return doc
+}
+
+// checkPublicAccessBlock evaluates the four Block Public Access settings, each
+// on when either the bucket or the account turns it on, and reports whether
+// all are on. The SETTING column names the settings that are off.
+func checkPublicAccessBlock(bucketBlock, accountBlock s3Internal.PublicAccessBlock, resource string, tbl *table.Table, rules config.RulesConfig) bool {
+	rule := rules.Get("s3-public-access")
+	off := bucketBlock.Merge(accountBlock).Off()
+	switch {
+	case len(off) > 0:
+		table.AddResult(tbl, rule, "Fail", resource, "Off: "+strings.Join(off, ", "))
+	case len(bucketBlock.Off()) > 0:
+		table.AddResult(tbl, rule, "Pass", resource, "Enabled (account)")
+	default:
+		table.AddResult(tbl, rule, "Pass", resource, "Enabled")
+	}
+	return len(off) == 0
+}
+
+// checkBucketVersioning checks versioning and the expiration of noncurrent
+// versions and, on critical buckets, MFA delete. The settings are added to
+// doc.
+func checkBucketVersioning(clients *s3Internal.RegionalClients, b s3Bucket, doc map[string]interface{}, tbl *table.Table, rules config.RulesConfig) {
+	client := clients.For(b.Region)
+	versioning := s3Internal.GetBucketVersioning(client, b.Name)
+	versioned := versioning.Status == "Enabled"
+	doc["Versioning"] = versioning.Status
+	doc["MFADelete"] = versioning.MFADelete
+
+	ruleVer := rules.Get("s3-versioning")
+	switch versioning.Status {
+	case "Enabled":
+		table.AddResult(tbl, ruleVer, "Pass", b.Name, "Enabled")
+	case "Suspended":
+		table.AddResult(tbl, ruleVer, "Fail", b.Name, "Suspended")
+	default:
+		table.AddResult(tbl, ruleVer, "Fail", b.Name, "Disabled")
+	}
+
+	ruleNoncurrent := rules.Get("s3-noncurrent-version-expiration")
+	if !versioned {
+		table.AddResult(tbl, ruleNoncurrent, "Pass", b.Name, "Not versioned")
+	} else if expires := s3Internal.HasNoncurrentVersionExpiration(client, b.Name); expires {
+		doc["NoncurrentVersionExpiration"] = true
+		table.AddResult(tbl, ruleNoncurrent, "Pass", b.Name, "Enabled")
+	} else {
+		doc["NoncurrentVersionExpiration"] = false
+		table.AddResult(tbl, ruleNoncurrent, "Fail", b.Name, "Disabled")
+	}
+
+	ruleMFA := rules.Get("s3-mfa-delete")
+	switch {
+	case !b.Critical:
+		table.AddResult(tbl, ruleMFA, "Pass", b.Name, "Not critical")
+	case !versioned:
+		table.AddResult(tbl, ruleMFA, "Pass", b.Name, "Not versioned")
+	case versioning.MFADelete:
+		table.AddResult(tbl, ruleMFA, "Pass", b.Name, "Enabled")
+	default:
+		table.AddResult(tbl, ruleMFA, "Fail", b.Name, "Disabled")
+	}
+}
+
+// checkBucketReplication checks that critical buckets are replicated to
+// another region. The destinations are added to doc.
+func checkBucketReplication(clients *s3Internal.RegionalClients, b s3Bucket, doc map[string]interface{}, tbl *table.Table, rules config.RulesConfig) {
+	ruleRepl := rules.Get("s3-replication")
+	if !b.Critical {
+		table.AddResult(tbl, ruleRepl, "Pass", b.Name, "Not critical")
+		return
+	}
+	destinations := s3Internal.GetReplicationDestinations(clients.For(b.Region), b.Name)
+	doc["ReplicationDestinations"] = destinations
+	if len(destinations) == 0 {
+		table.AddResult(tbl, ruleRepl, "Fail", b.Name, "Disabled")
+		return
+	}
+	// Destinations whose region cannot be read (e.g. in another account) are
+	// taken to be in another region.
+	var crossRegion, sameRegion []string
+	for _, dest := range destinations {
+		region, err := s3Internal.GetBucketRegion(clients.Location(Partition), dest)
+		switch {
+		case err != nil || b.Region == "":
+			crossRegion = append(crossRegion, dest+" (region unknown)")
+		case region != b.Region:
+			crossRegion = append(crossRegion, dest+" ("+region+")")
+		default:
+			sameRegion = append(sameRegion, dest)
+		}
+	}
+	if len(crossRegion) > 0 {
+		table.AddResult(tbl, ruleRepl, "Pass", b.Name, "Cross-region: "+strings.Join(crossRegion, ", "))
+	} else {
+		table.AddResult(tbl, ruleRepl, "Fail", b.Name, "Same region: "+strings.Join(sameRegion, ", "))
+	}
+}
+
+// checkBucketNotifications checks that critical buckets send event
+// notifications, so that changes to their objects can be acted on. The kinds
+// of destination are added to doc.
+func checkBucketNotifications(clients *s3Internal.RegionalClients, b s3Bucket, doc map[string]interface{}, tbl *table.Table, rules config.RulesConfig) {
+	ruleNotify := rules.Get("s3-event-notifications")
+	if !b.Critical {
+		table.AddResult(tbl, ruleNotify, "Pass", b.Name, "Not critical")
+		return
+	}
+	destinations := s3Internal.GetNotificationDestinations(clients.For(b.Region), b.Name)
+	doc["NotificationDestinations"] = destinations
+	if len(destinations) == 0 {
+		table.AddResult(tbl, ruleNotify, "Fail", b.Name, "Disabled")
+		return
+	}
+	table.AddResult(tbl, ruleNotify, "Pass", b.Name, strings.Join(destinations, ", "))
+}
+
+// checkBucketKMSKey checks the KMS key of an SSE-KMS bucket: that it exists
+// and is enabled, is customer managed and is rotated. The checks pass for
+// buckets encrypted otherwise. The settings are added to doc.
+func checkBucketKMSKey(keys *kmsInternal.Keys, b s3Bucket, encryption s3Internal.DefaultEncryption, doc map[string]interface{}, tbl *table.Table, rules config.RulesConfig) {
+	ruleKey := rules.Get("s3-kms-key")
+	ruleManaged := rules.Get("s3-kms-customer-managed")
+	ruleRotation := rules.Get("s3-kms-key-rotation")
+	checkBucketKey(encryption, b.Name, tbl, rules)
+	if !encryption.KMS() {
+		table.AddResult(tbl, ruleKey, "Pass", b.Name, "Not SSE-KMS")
+		table.AddResult(tbl, ruleManaged, "Pass", b.Name, "Not SSE-KMS")
+		table.AddResult(tbl, ruleRotation, "Pass", b.Name, "Not SSE-KMS")
+		return
+	}
+	doc["BucketKeyEnabled"] = encryption.BucketKeyEnabled
+
+	keyID := encryption.KMSKeyID
+	if keyID == "" {
+		keyID = "alias/aws/s3"
+	}
+	if !needs(rules, "s3-kms-key", "s3-kms-customer-managed", "s3-kms-key-rotation") {
+		return
+	}
+	key, ok := keys.Describe(b.Region, keyID)
+	if !ok {
+		// Without the key, none of its settings can be shown to be safe.
+		for _, rule := range []config.Rule{ruleKey, ruleManaged, ruleRotation} {
+			table.AddResult(tbl, rule, "Fail", b.Name, "Cannot describe "+keyID)
+		}
+		return
+	}
+	if !key.Exists() {
+		doc["KMSKeyState"] = "NotFound"
+		table.AddResult(tbl, ruleKey, "Fail", b.Name, "Not found: "+keyID)
+		table.AddResult(tbl, ruleManaged, "Pass", b.Name, "Key not found")
+		table.AddResult(tbl, ruleRotation, "Pass", b.Name, "Key not found")
+		return
+	}
+	doc["KMSKey"] = key.ARN
+	doc["KMSKeyState"] = key.State
+	doc["KMSKeyCustomerManaged"] = key.CustomerManaged
+	doc["KMSKeyRotation"] = key.RotationEnabled
+
+	if key.State == "Enabled" {
+		table.AddResult(tbl, ruleKey, "Pass", b.Name, "Enabled")
+	} else {
+		table.AddResult(tbl, ruleKey, "Fail", b.Name, key.State+": "+key.ARN)
+	}
+	switch {
+	case !key.CustomerManaged:
+		table.AddResult(tbl, ruleManaged, "Fail", b.Name, "AWS managed")
+		table.AddResult(tbl, ruleRotation, "Pass", b.Name, "AWS managed")
+	case key.RotationEnabled:
+		table.AddResult(tbl, ruleManaged, "Pass", b.Name, "Customer managed")
+		table.AddResult(tbl, ruleRotation, "Pass", b.Name, "Enabled")
+	case key.State == "PendingDeletion":
+		// The rotation status cannot be read; s3-kms-key reports the key.
+		table.AddResult(tbl, ruleManaged, "Pass", b.Name, "Customer managed")
+		table.AddResult(tbl, ruleRotation, "Pass", b.Name, "Pending deletion")
+	default:
+		table.AddResult(tbl, ruleManaged, "Pass", b.Name, "Customer managed")
+		table.AddResult(tbl, ruleRotation, "Fail", b.Name, "Disabled")
+	}
+}
+
+// checkBucketKey checks that SSE-KMS buckets use S3 Bucket Keys, which cut
+// the requests S3 makes to KMS and their cost.
+func checkBucketKey(encryption s3Internal.DefaultEncryption, resource string, tbl *table.Table, rules config.RulesConfig) {
+	rule := rules.Get("s3-bucket-key")
+	switch {
+	case !encryption.KMS():
+		table.AddResult(tbl, rule, "Pass", resource, "Not SSE-KMS")
+	case encryption.BucketKeyEnabled:
+		table.AddResult(tbl, rule, "Pass", resource, "Enabled")
+	default:
+		table.AddResult(tbl, rule, "Fail", resource, "Disabled")
+	}
+}
+
+// trustedAccounts are the accounts, besides the scanned one, that bucket
+// policies may grant access to (--trusted-accounts).
+var trustedAccounts map[string]bool
+
+// checkBucketPolicy checks a bucket policy for statements open to everyone,
+// grants to untrusted accounts and a deny of requests without TLS. policy is
+// nil when the bucket has none. block is the effective Block Public Access
+// configuration: with RestrictPublicBuckets on, public statements only let
+// AWS services and the bucket owner's account in.
+func checkBucketPolicy(policy *s3Internal.Policy, block s3Internal.PublicAccessBlock, resource string, tbl *table.Table, rules config.RulesConfig) {
+	rulePublic := rules.Get("s3-policy-public")
+	ruleCross := rules.Get("s3-policy-cross-account")
+	ruleTLS := rules.Get("s3-secure-transport")
+	if policy == nil {
+		table.AddResult(tbl, rulePublic, "Pass", resource, "No policy")
+		table.AddResult(tbl, ruleCross, "Pass", resource, "No policy")
+		table.AddResult(tbl, ruleTLS, "Fail", resource, "No policy")
+		return
+	}
+
+	public := policy.PublicStatements()
+	switch {
+	case len(public) == 0:
+		table.AddResult(tbl, rulePublic, "Pass", resource, "Not public")
+	case block.RestrictPublicBuckets:
+		table.AddResult(tbl, rulePublic, "Pass", resource, "Restricted: "+strings.Join(public, ", "))
+	default:
+		table.AddResult(tbl, rulePublic, "Fail", resource, "Public: "+strings.Join(public, ", "))
+	}
+
+	var untrusted []string
+	for _, account := range policy.Accounts() {
+		if account != AccountID && !trustedAccounts[account] {
+			untrusted = append(untrusted, account)
+		}
+	}
+	if len(untrusted) > 0 {
+		table.AddResult(tbl, ruleCross, "Fail", resource, "Untrusted: "+strings.Join(untrusted, ", "))
+	} else {
+		table.AddResult(tbl, ruleCross, "Pass", resource, "None")
+	}
+
+	if policy.DeniesInsecureTransport() {
+		table.AddResult(tbl, ruleTLS, "Pass", resource, "Denied")
+	} else {
+		table.AddResult(tbl, ruleTLS, "Fail", resource, "Not denied")
+	}
+}
+
+// checkBucketACL checks the ACL grants to AllUsers and AuthenticatedUsers,
+// which S3 ignores with IgnorePublicAcls on, and any grant besides the
+// owner's full control.
+func checkBucketACL(acl s3Internal.ACL, block s3Internal.PublicAccessBlock, resource string, tbl *table.Table, rules config.RulesConfig) {
+	rule := rules.Get("s3-acl-public")
+	public := acl.PublicGrants()
+	switch {
+	case len(public) == 0:
+		table.AddResult(tbl, rule, "Pass", resource, "Private")
+	case block.IgnorePublicAcls:
+		table.AddResult(tbl, rule, "Pass", resource, "Ignored: "+strings.Join(public, ", "))
+	default:
+		table.AddResult(tbl, rule, "Fail", resource, strings.Join(public, ", "))
+	}
+
+	ruleGrants := rules.Get("s3-acl-grants")
+	if extra := acl.ExtraGrants(); len(extra) > 0 {
+		table.AddResult(tbl, ruleGrants, "Fail", resource, strings.Join(extra, ", "))
+	} else {
+		table.AddResult(tbl, ruleGrants, "Pass", resource, "Owner only")
+	}
+}
+
+// checkObjectOwnership checks that ACLs are disabled, i.e. Object Ownership
+// is BucketOwnerEnforced.
+func checkObjectOwnership(ownership string, resource string, tbl *table.Table, rules config.RulesConfig) {
+	rule := rules.Get("s3-object-ownership")
+	if ownership == string(s3types.ObjectOwnershipBucketOwnerEnforced) {
+		table.AddResult(tbl, rule, "Pass", resource, ownership)
 	} else {
-		table.AddRow(tbl, []string{ruleLog.Service, "Pass", "-", bucket, "Enabled", ruleLog.Issue})
+		table.AddResult(tbl, rule, "Fail", resource, ownership)
 	}
 }
 
@@ -89,11 +693,133 @@ func init() {
 	rootCmd.AddCommand(s3Cmd)
 }
 
-func checkS3StorageLens(client api.S3ControlClient, tbl *tablewriter.Table, rules config.RulesConfig) {
-	rule := rules.Get("s3-storage-lens-enabled")
-	if !s3Internal.IsStorageLensEnabled(client, AccountID) {
-		table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), "-", "Disabled", rule.Issue})
+// logSourceClients are the clients of the services whose log buckets
+// findLogTargets looks up.
+type logSourceClients struct {
+	elb        api.ELBv2Client
+	cloudfront api.CloudFrontClient
+	ec2        api.EC2Client
+}
+
+func newLogSourceClients(cfg aws.Config) logSourceClients {
+	return logSourceClients{
+		elb:        elasticloadbalancingv2.NewFromConfig(cfg),
+		cloudfront: cloudfront.NewFromConfig(cfg),
+		ec2:        ec2.NewFromConfig(cfg),
+	}
+}
+
+// findLogTargets returns the buckets that load balancer access and connection
+// logs, CloudFront standard logs and VPC flow logs are delivered to. A source
+// that cannot be read is skipped with a warning; its buckets can still be
+// classified by name or tags.
+func findLogTargets(c logSourceClients, rules config.RulesConfig) s3Internal.LogTargets {
+	targets := make(s3Internal.LogTargets)
+	if !needsService(rules, "S3") || !needs(rules, s3LogBucketRules...) {
+		return targets
+	}
+
+	lbs, err := c.elb.DescribeLoadBalancers(context.TODO(), &elasticloadbalancingv2.DescribeLoadBalancersInput{})
+	if err != nil {
+		log.Printf("Warning: Failed to describe load balancers to find log buckets: %v", err)
 	} else {
-		table.AddRow(tbl, []string{rule.Service, "Pass", "-", "-", "Enabled", rule.Issue})
+		for _, lb := range lbs.LoadBalancers {
+			attrs, err := c.elb.DescribeLoadBalancerAttributes(context.TODO(), &elasticloadbalancingv2.DescribeLoadBalancerAttributesInput{
+				LoadBalancerArn: lb.LoadBalancerArn,
+			})
+			if err != nil {
+				log.Printf("Warning: Failed to describe attributes for ELB %s: %v", aws.ToString(lb.LoadBalancerName), err)
+				continue
+			}
+			values := make(map[string]string)
+			for _, attr := range attrs.Attributes {
+				values[aws.ToString(attr.Key)] = aws.ToString(attr.Value)
+			}
+			for _, kind := range []string{"access_logs", "connection_logs"} {
+				if values[kind+".s3.enabled"] == "true" {
+					targets.Add(values[kind+".s3.bucket"], "ELB "+strings.Replace(kind, "_", " ", 1)+" of "+aws.ToString(lb.LoadBalancerName))
+				}
+			}
+		}
+	}
+
+	dists, err := c.cloudfront.ListDistributions(context.TODO(), &cloudfront.ListDistributionsInput{})
+	if err != nil {
+		log.Printf("Warning: Failed to list CloudFront distributions to find log buckets: %v", err)
+	} else if dists.DistributionList != nil {
+		for _, dist := range dists.DistributionList.Items {
+			resp, err := c.cloudfront.GetDistributionConfig(context.TODO(), &cloudfront.GetDistributionConfigInput{Id: dist.Id})
+			if err != nil {
+				log.Printf("Warning: Failed to get config for distribution %s: %v", aws.ToString(dist.Id), err)
+				continue
+			}
+			logging := resp.DistributionConfig.Logging
+			if logging != nil && aws.ToBool(logging.Enabled) {
+				// The bucket is given as its domain name, e.g. "my-logs.s3.amazonaws.com".
+				domain := aws.ToString(logging.Bucket)
+				if i := strings.LastIndex(domain, ".s3."); i >= 0 {
+					domain = domain[:i]
+				}
+				targets.Add(domain, "CloudFront logs of "+aws.ToString(dist.Id))
+			}
+		}
+	}
+
+	flowLogs, err := c.ec2.DescribeFlowLogs(context.TODO(), &ec2.DescribeFlowLogsInput{
+		Filter: []ec2types.Filter{{Name: aws.String("log-destination-type"), Values: []string{"s3"}}},
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to describe flow logs to find log buckets: %v", err)
+	} else {
+		for _, fl := range flowLogs.FlowLogs {
+			// The destination is the bucket ARN with an optional prefix,
+			// e.g. arn:aws:s3:::my-logs/vpc/.
+			_, bucket, _ := strings.Cut(aws.ToString(fl.LogDestination), ":::")
+			bucket, _, _ = strings.Cut(bucket, "/")
+			targets.Add(bucket, "VPC flow logs of "+aws.ToString(fl.ResourceId))
+		}
+	}
+	return targets
+}
+
+// checkS3StorageLens looks for a Storage Lens configuration with advanced
+// metrics that covers every region and exports the metrics to a bucket. The
+// configurations are listed in their home region: the regions given and the
+// default region of the partition (us-east-1 in aws), the home of the default
+// dashboard. RESOURCE is the configuration that passes, or the one closest to
+// passing.
+func checkS3StorageLens(controls *s3Internal.ControlClients, regions []string, tbl *table.Table, rules config.RulesConfig) {
+	if !needs(rules, "s3-storage-lens-enabled") {
+		return
+	}
+	rule := rules.Get("s3-storage-lens-enabled")
+	home := s3Internal.PartitionRegion(Partition)
+	if home == "" {
+		home = Region
+	}
+	homeRegions := []string{home}
+	for _, region := range regions {
+		if region != home {
+			homeRegions = append(homeRegions, region)
+		}
+	}
+	var closest string
+	var closestMissing []string
+	for _, region := range homeRegions {
+		for _, c := range s3Internal.ListStorageLensConfigurations(controls.For(region), AccountID) {
+			missing := c.Missing()
+			if len(missing) == 0 {
+				table.AddResult(tbl, rule, "Pass", c.ID, "Advanced metrics, all regions, export to "+c.ExportBucket)
+				return
+			}
+			if closest == "" || len(missing) < len(closestMissing) {
+				closest, closestMissing = c.ID, missing
+			}
+		}
+	}
+	if closest == "" {
+		table.AddResult(tbl, rule, "Fail", "-", "No configuration")
+		return
 	}
+	table.AddResult(tbl, rule, "Fail", closest, strings.Join(closestMissing, ", "))
 }
@@ -1,17 +1,27 @@
 package cmd
 
 import (
+	"awsselfrev/internal/aws/api"
+	kmsInternal "awsselfrev/internal/aws/service/kms"
+	s3Internal "awsselfrev/internal/aws/service/s3"
 	"awsselfrev/internal/config"
 	"awsselfrev/internal/table"
 	"fmt"
+	"net/http"
+	"strings"
 	"testing"
 
 	"context"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/aws-sdk-go-v2/service/s3control"
+	s3controltypes "github.com/aws/aws-sdk-go-v2/service/s3control/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -65,6 +75,14 @@ func (m *MockS3Client) GetBucketLogging(ctx context.Context, params *s3.GetBucke
 	return args.Get(0).(*s3.GetBucketLoggingOutput), args.Error(1)
 }
 
+func (m *MockS3Client) GetBucketTagging(ctx context.Context, params *s3.GetBucketTaggingInput, optFns ...func(*s3.Options)) (*s3.GetBucketTaggingOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*s3.GetBucketTaggingOutput), args.Error(1)
+}
+
 type MockS3ControlClient struct {
 	mock.Mock
 }
@@ -74,6 +92,108 @@ func (m *MockS3ControlClient) ListStorageLensConfigurations(ctx context.Context,
 	return args.Get(0).(*s3control.ListStorageLensConfigurationsOutput), args.Error(1)
 }
 
+func (m *MockS3ControlClient) GetStorageLensConfiguration(ctx context.Context, params *s3control.GetStorageLensConfigurationInput, optFns ...func(*s3control.Options)) (*s3control.GetStorageLensConfigurationOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	return args.Get(0).(*s3control.GetStorageLensConfigurationOutput), args.Error(1)
+}
+
+func (m *MockS3ControlClient) GetPublicAccessBlock(ctx context.Context, params *s3control.GetPublicAccessBlockInput, optFns ...func(*s3control.Options)) (*s3control.GetPublicAccessBlockOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*s3control.GetPublicAccessBlockOutput), args.Error(1)
+}
+
+func (m *MockS3ControlClient) ListAccessPoints(ctx context.Context, params *s3control.ListAccessPointsInput, optFns ...func(*s3control.Options)) (*s3control.ListAccessPointsOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	return args.Get(0).(*s3control.ListAccessPointsOutput), args.Error(1)
+}
+
+func (m *MockS3ControlClient) GetAccessPoint(ctx context.Context, params *s3control.GetAccessPointInput, optFns ...func(*s3control.Options)) (*s3control.GetAccessPointOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	return args.Get(0).(*s3control.GetAccessPointOutput), args.Error(1)
+}
+
+func (m *MockS3ControlClient) GetAccessPointPolicy(ctx context.Context, params *s3control.GetAccessPointPolicyInput, optFns ...func(*s3control.Options)) (*s3control.GetAccessPointPolicyOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	return args.Get(0).(*s3control.GetAccessPointPolicyOutput), args.Error(1)
+}
+
+func (m *MockS3ControlClient) ListMultiRegionAccessPoints(ctx context.Context, params *s3control.ListMultiRegionAccessPointsInput, optFns ...func(*s3control.Options)) (*s3control.ListMultiRegionAccessPointsOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	return args.Get(0).(*s3control.ListMultiRegionAccessPointsOutput), args.Error(1)
+}
+
+func (m *MockS3ControlClient) GetMultiRegionAccessPointPolicy(ctx context.Context, params *s3control.GetMultiRegionAccessPointPolicyInput, optFns ...func(*s3control.Options)) (*s3control.GetMultiRegionAccessPointPolicyOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	return args.Get(0).(*s3control.GetMultiRegionAccessPointPolicyOutput), args.Error(1)
+}
+
+func (m *MockS3Client) GetBucketPolicy(ctx context.Context, params *s3.GetBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.GetBucketPolicyOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*s3.GetBucketPolicyOutput), args.Error(1)
+}
+
+func (m *MockS3Client) GetBucketAcl(ctx context.Context, params *s3.GetBucketAclInput, optFns ...func(*s3.Options)) (*s3.GetBucketAclOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*s3.GetBucketAclOutput), args.Error(1)
+}
+
+func (m *MockS3Client) GetBucketOwnershipControls(ctx context.Context, params *s3.GetBucketOwnershipControlsInput, optFns ...func(*s3.Options)) (*s3.GetBucketOwnershipControlsOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*s3.GetBucketOwnershipControlsOutput), args.Error(1)
+}
+
+func (m *MockS3Client) GetBucketVersioning(ctx context.Context, params *s3.GetBucketVersioningInput, optFns ...func(*s3.Options)) (*s3.GetBucketVersioningOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*s3.GetBucketVersioningOutput), args.Error(1)
+}
+
+func (m *MockS3Client) GetBucketReplication(ctx context.Context, params *s3.GetBucketReplicationInput, optFns ...func(*s3.Options)) (*s3.GetBucketReplicationOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*s3.GetBucketReplicationOutput), args.Error(1)
+}
+
+func (m *MockS3Client) GetBucketNotificationConfiguration(ctx context.Context, params *s3.GetBucketNotificationConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetBucketNotificationConfigurationOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*s3.GetBucketNotificationConfigurationOutput), args.Error(1)
+}
+
+func (m *MockS3Client) GetBucketLocation(ctx context.Context, params *s3.GetBucketLocationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLocationOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*s3.GetBucketLocationOutput), args.Error(1)
+}
+
+func (m *MockS3Client) HeadBucket(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*s3.HeadBucketOutput), args.Error(1)
+}
+
 type MockHTTPStatusError struct {
 	StatusCode int
 }
@@ -81,6 +201,68 @@ type MockHTTPStatusError struct {
 func (m MockHTTPStatusError) Error() string       { return fmt.Sprintf("mock status code %d", m.StatusCode) }
 func (m MockHTTPStatusError) HTTPStatusCode() int { return m.StatusCode }
 
+func s3TestRules() config.RulesConfig {
+	return config.RulesConfig{
+		Rules: map[string]config.Rule{
+			"s3-encryption":                    {Service: "S3", Level: "Alert", Issue: "Bucket encryption is not set"},
+			"s3-public-access":                 {Service: "S3", Level: "Alert", Issue: "Block public access is all off"},
+			"s3-lifecycle":                     {Service: "S3", Level: "Warning", Issue: "Lifecycle policy is not set"},
+			"s3-object-lock":                   {Service: "S3", Level: "Warning", Issue: "Object Lock is not enabled"},
+			"s3-sse-kms-encryption":            {Service: "S3", Level: "Warning", Issue: "SSE-KMS encryption is not set"},
+			"s3-server-access-logging":         {Service: "S3", Level: "Warning", Issue: "Server access logging is not enabled"},
+			"s3-storage-lens-enabled":          {Service: "S3", Level: "Warning", Issue: "No S3 Storage Lens configuration with advanced metrics, all regions and an export"},
+			"s3-policy-public":                 {Service: "S3", Level: "Alert", Issue: "Bucket policy allows public access"},
+			"s3-policy-cross-account":          {Service: "S3", Level: "Warning", Issue: "Bucket policy grants access to untrusted accounts"},
+			"s3-secure-transport":              {Service: "S3", Level: "Warning", Issue: "Bucket policy does not deny insecure transport"},
+			"s3-acl-public":                    {Service: "S3", Level: "Alert", Issue: "Bucket ACL grants public access"},
+			"s3-versioning":                    {Service: "S3", Level: "Warning", Issue: "Versioning is not enabled"},
+			"s3-noncurrent-version-expiration": {Service: "S3", Level: "Warning", Issue: "Noncurrent versions never expire"},
+			"s3-mfa-delete":                    {Service: "S3", Level: "Warning", Issue: "MFA delete is not enabled on a critical bucket"},
+			"s3-replication":                   {Service: "S3", Level: "Warning", Issue: "Critical bucket is not replicated to another region"},
+			"s3-event-notifications":           {Service: "S3", Level: "Warning", Issue: "Critical bucket sends no event notifications"},
+			"s3-object-ownership":              {Service: "S3", Level: "Warning", Issue: "ACLs are not disabled"},
+			"s3-acl-grants":                    {Service: "S3", Level: "Warning", Issue: "Bucket ACL has grants besides the owner"},
+			"s3-kms-key":                       {Service: "S3", Level: "Alert", Issue: "The SSE-KMS key of the bucket is missing, disabled or pending deletion"},
+			"s3-kms-customer-managed":          {Service: "S3", Level: "Warning", Issue: "The bucket is encrypted with the AWS managed key (aws/s3)"},
+			"s3-kms-key-rotation":              {Service: "S3", Level: "Warning", Issue: "Automatic rotation of the bucket's KMS key is not enabled"},
+			"s3-bucket-key":                    {Service: "S3", Level: "Warning", Issue: "S3 Bucket Keys are not enabled on an SSE-KMS bucket"},
+			"s3-access-point-vpc":              {Service: "S3", Level: "Warning", Issue: "Access point accepts requests from the internet"},
+			"s3-access-point-policy":           {Service: "S3", Level: "Alert", Issue: "Access point policy allows everyone or every S3 action"},
+			"s3-mrap-public-access":            {Service: "S3", Level: "Alert", Issue: "Multi-Region Access Point does not block all public access"},
+		},
+	}
+}
+
+type MockKMSClient struct {
+	mock.Mock
+}
+
+func (m *MockKMSClient) DescribeKey(ctx context.Context, params *kms.DescribeKeyInput, optFns ...func(*kms.Options)) (*kms.DescribeKeyOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	return args.Get(0).(*kms.DescribeKeyOutput), args.Error(1)
+}
+
+func (m *MockKMSClient) GetKeyRotationStatus(ctx context.Context, params *kms.GetKeyRotationStatusInput, optFns ...func(*kms.Options)) (*kms.GetKeyRotationStatusOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	return args.Get(0).(*kms.GetKeyRotationStatusOutput), args.Error(1)
+}
+
+// noKMSKeys returns Keys for tests whose buckets are not SSE-KMS: a lookup
+// fails on the unexpected call.
+func noKMSKeys() *kmsInternal.Keys {
+	return kmsInternal.NewKeys(func(string) api.KMSClient { return new(MockKMSClient) })
+}
+
+// singleControlRegion returns ControlClients that use client for every region.
+func singleControlRegion(client *MockS3ControlClient) *s3Internal.ControlClients {
+	return s3Internal.NewControlClients(func(string) api.S3ControlClient { return client })
+}
+
+// singleRegion returns RegionalClients that use client for every region.
+func singleRegion(client *MockS3Client) *s3Internal.RegionalClients {
+	return s3Internal.NewRegionalClients(func(string) api.S3Client { return client })
+}
+
 func TestCheckBucketConfigurations(t *testing.T) {
 	client := new(MockS3Client)
 	controlClient := new(MockS3ControlClient)
@@ -94,35 +276,1021 @@ func TestCheckBucketConfigurations(t *testing.T) {
 	err404 := MockHTTPStatusError{StatusCode: 404}
 
 	client.On("ListBuckets", mock.Anything, mock.Anything, mock.Anything).Return(&s3.ListBucketsOutput{Buckets: buckets}, nil)
+	client.On("GetBucketLocation", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketLocationOutput{}, nil)
 	client.On("GetBucketEncryption", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketEncryptionOutput)(nil), err404)
 	client.On("GetPublicAccessBlock", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetPublicAccessBlockOutput)(nil), err404)
 	client.On("GetBucketLifecycleConfiguration", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketLifecycleConfigurationOutput)(nil), err404)
 	client.On("GetObjectLockConfiguration", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetObjectLockConfigurationOutput)(nil), err404)
 	client.On("GetBucketLogging", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketLoggingOutput)(nil), err404)
+	client.On("GetBucketPolicy", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketPolicyOutput)(nil), err404)
+	client.On("GetBucketAcl", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketAclOutput{}, nil)
+	client.On("GetBucketVersioning", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketVersioningOutput{}, nil)
+	client.On("GetBucketOwnershipControls", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketOwnershipControlsOutput)(nil), err404)
+	client.On("GetBucketTagging", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketTaggingOutput)(nil), err404)
 	controlClient.On("ListStorageLensConfigurations", mock.Anything, mock.Anything, mock.Anything).Return(&s3control.ListStorageLensConfigurationsOutput{}, nil)
 
 	// テーブルのセットアップ
 	tbl := table.SetTable()
 	// ルールのセットアップ
+	rules := s3TestRules()
+
+	// テスト対象の関数を呼び出し
+	checkS3Configurations(singleRegion(client), noKMSKeys(), singleControlRegion(controlClient), nil, tbl, rules)
+
+	// テーブルの内容を検証
+	// Storage Lens: 1 check
+	// test-log-bucket: Encryption, Public, Lifecycle, ObjectLock, SSE-KMS, AccessLogs,
+	//   PolicyPublic, PolicyCrossAccount, SecureTransport, ACL,
+	//   Versioning, NoncurrentExpiration, MFADelete, Replication,
+	//   EventNotifications, ObjectOwnership, ACLGrants, BucketKey, KMSKey,
+	//   KMSCustomerManaged, KMSKeyRotation (21 checks)
+	// test-bucket: the same 21 checks
+	// Total rows = 1 + 21 + 21 = 43
+	assert.Equal(t, 43, tbl.NumLines())
+}
+
+func TestCheckS3ConfigurationsResourceFilter(t *testing.T) {
+	client := new(MockS3Client)
+	controlClient := new(MockS3ControlClient)
+	buckets := []types.Bucket{
+		{Name: aws.String("prod-payments")},
+		{Name: aws.String("prod-catalog")},
+		{Name: aws.String("dev-payments")},
+	}
+	err404 := MockHTTPStatusError{StatusCode: 404}
+
+	client.On("ListBuckets", mock.Anything, mock.Anything, mock.Anything).Return(&s3.ListBucketsOutput{Buckets: buckets}, nil)
+	client.On("GetBucketLocation", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketLocationOutput{}, nil)
+	client.On("GetBucketTagging", mock.Anything, mock.MatchedBy(func(p *s3.GetBucketTaggingInput) bool {
+		return *p.Bucket == "prod-payments"
+	}), mock.Anything).Return(&s3.GetBucketTaggingOutput{TagSet: []types.Tag{{Key: aws.String("Team"), Value: aws.String("payments")}}}, nil)
+	client.On("GetBucketTagging", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketTaggingOutput)(nil), err404)
+	client.On("GetBucketEncryption", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketEncryptionOutput)(nil), err404)
+	client.On("GetPublicAccessBlock", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetPublicAccessBlockOutput)(nil), err404)
+	client.On("GetBucketLifecycleConfiguration", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketLifecycleConfigurationOutput)(nil), err404)
+	client.On("GetObjectLockConfiguration", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetObjectLockConfigurationOutput)(nil), err404)
+	client.On("GetBucketLogging", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketLoggingOutput)(nil), err404)
+	client.On("GetBucketPolicy", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketPolicyOutput)(nil), err404)
+	client.On("GetBucketAcl", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketAclOutput{}, nil)
+	client.On("GetBucketVersioning", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketVersioningOutput{}, nil)
+	client.On("GetBucketOwnershipControls", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketOwnershipControlsOutput)(nil), err404)
+	controlClient.On("ListStorageLensConfigurations", mock.Anything, mock.Anything, mock.Anything).Return(&s3control.ListStorageLensConfigurationsOutput{}, nil)
+
+	filter, err := config.ParseResourceFilter([]string{"prod-*", "tag:Team=payments"})
+	assert.NoError(t, err)
+	config.Resources = filter
+	defer func() { config.Resources = config.ResourceFilter{} }()
+
+	tbl := table.SetTable()
+	rules := s3TestRules()
+
+	checkS3Configurations(singleRegion(client), noKMSKeys(), singleControlRegion(controlClient), nil, tbl, rules)
+
+	// Storage Lens (account level) + 21 checks for prod-payments only
+	assert.Equal(t, 22, tbl.NumLines())
+	// dev-payments is rejected by name before its tags are fetched
+	client.AssertNumberOfCalls(t, "GetBucketTagging", 2)
+}
+
+func TestCheckS3ConfigurationsInventory(t *testing.T) {
+	client := new(MockS3Client)
+	controlClient := new(MockS3ControlClient)
+	err404 := MockHTTPStatusError{StatusCode: 404}
+
+	client.On("ListBuckets", mock.Anything, mock.Anything, mock.Anything).Return(&s3.ListBucketsOutput{Buckets: []types.Bucket{{Name: aws.String("test-bucket")}}}, nil)
+	client.On("GetBucketLocation", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketLocationOutput{}, nil)
+	client.On("GetBucketEncryption", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketEncryptionOutput{
+		ServerSideEncryptionConfiguration: &types.ServerSideEncryptionConfiguration{
+			Rules: []types.ServerSideEncryptionRule{{
+				ApplyServerSideEncryptionByDefault: &types.ServerSideEncryptionByDefault{SSEAlgorithm: types.ServerSideEncryptionAes256},
+			}},
+		},
+	}, nil)
+	client.On("GetPublicAccessBlock", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetPublicAccessBlockOutput)(nil), err404)
+	client.On("GetBucketLogging", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketLoggingOutput)(nil), err404)
+	client.On("GetBucketPolicy", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketPolicyOutput)(nil), err404)
+	client.On("GetBucketAcl", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketAclOutput{}, nil)
+	client.On("GetBucketVersioning", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketVersioningOutput{}, nil)
+	client.On("GetBucketOwnershipControls", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketOwnershipControlsOutput)(nil), err404)
+	client.On("GetBucketTagging", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketTaggingOutput)(nil), err404)
+	controlClient.On("ListStorageLensConfigurations", mock.Anything, mock.Anything, mock.Anything).Return(&s3control.ListStorageLensConfigurationsOutput{}, nil)
+
+	resetCollected()
+	recordInventory = true
+	defer func() {
+		recordInventory = false
+		resetCollected()
+	}()
+
+	tbl := table.SetTable()
+	rules := s3TestRules()
+
+	checkS3Configurations(singleRegion(client), noKMSKeys(), singleControlRegion(controlClient), nil, tbl, rules)
+
+	assert.Len(t, collected, 1)
+	bucket := collected[0]
+	assert.Equal(t, "s3-bucket", bucket.Type)
+	assert.Equal(t, "arn:aws:s3:::test-bucket", bucket.ARN)
+	assert.Equal(t, true, bucket.Attributes["Encrypted"])
+	assert.Equal(t, false, bucket.Attributes["SSEKMS"])
+	assert.Equal(t, false, bucket.Attributes["PublicAccessBlock"])
+}
+
+func TestCheckS3ConfigurationsBucketRegions(t *testing.T) {
+	err404 := MockHTTPStatusError{StatusCode: 404}
+	bucketChecks := func(client *MockS3Client) {
+		client.On("GetBucketEncryption", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketEncryptionOutput)(nil), err404)
+		client.On("GetPublicAccessBlock", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetPublicAccessBlockOutput)(nil), err404)
+		client.On("GetBucketLogging", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketLoggingOutput)(nil), err404)
+		client.On("GetBucketPolicy", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketPolicyOutput)(nil), err404)
+		client.On("GetBucketAcl", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketAclOutput{}, nil)
+		client.On("GetBucketVersioning", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketVersioningOutput{}, nil)
+		client.On("GetBucketOwnershipControls", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketOwnershipControlsOutput)(nil), err404)
+		client.On("GetBucketTagging", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketTaggingOutput)(nil), err404)
+	}
+	home := new(MockS3Client)
+	home.On("ListBuckets", mock.Anything, mock.Anything, mock.Anything).Return(&s3.ListBucketsOutput{Buckets: []types.Bucket{
+		{Name: aws.String("tokyo-bucket")},
+		{Name: aws.String("frankfurt-bucket")},
+	}}, nil)
+	bucketChecks(home)
+	location := new(MockS3Client)
+	location.On("GetBucketLocation", mock.Anything, mock.MatchedBy(func(p *s3.GetBucketLocationInput) bool {
+		return *p.Bucket == "frankfurt-bucket"
+	}), mock.Anything).Return(&s3.GetBucketLocationOutput{LocationConstraint: types.BucketLocationConstraintEuCentral1}, nil)
+	location.On("GetBucketLocation", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketLocationOutput{LocationConstraint: types.BucketLocationConstraintApNortheast1}, nil)
+	frankfurt := new(MockS3Client)
+	bucketChecks(frankfurt)
+	controlClient := new(MockS3ControlClient)
+	controlClient.On("ListStorageLensConfigurations", mock.Anything, mock.Anything, mock.Anything).Return(&s3control.ListStorageLensConfigurationsOutput{}, nil)
+
+	clients := s3Internal.NewRegionalClients(func(region string) api.S3Client {
+		switch region {
+		case "us-east-1":
+			return location
+		case "eu-central-1":
+			return frankfurt
+		}
+		return home
+	})
+	checkS3Configurations(clients, noKMSKeys(), singleControlRegion(controlClient), nil, table.SetTable(), s3TestRules())
+
+	// Each bucket is read with a client of its own region.
+	encryptionOf := func(bucket string) interface{} {
+		return mock.MatchedBy(func(p *s3.GetBucketEncryptionInput) bool { return *p.Bucket == bucket })
+	}
+	home.AssertCalled(t, "GetBucketEncryption", mock.Anything, encryptionOf("tokyo-bucket"), mock.Anything)
+	home.AssertNotCalled(t, "GetBucketEncryption", mock.Anything, encryptionOf("frankfurt-bucket"), mock.Anything)
+	frankfurt.AssertCalled(t, "GetBucketEncryption", mock.Anything, encryptionOf("frankfurt-bucket"), mock.Anything)
+	frankfurt.AssertNotCalled(t, "GetBucketEncryption", mock.Anything, encryptionOf("tokyo-bucket"), mock.Anything)
+}
+
+func TestCheckS3PublicAccessBlock(t *testing.T) {
+	client := new(MockS3Client)
+	controlClient := new(MockS3ControlClient)
+	err404 := MockHTTPStatusError{StatusCode: 404}
+
+	client.On("ListBuckets", mock.Anything, mock.Anything, mock.Anything).Return(&s3.ListBucketsOutput{Buckets: []types.Bucket{
+		{Name: aws.String("all-off-bucket")},
+		{Name: aws.String("partial-bucket")},
+		{Name: aws.String("missing-bucket")},
+	}}, nil)
+	client.On("GetBucketLocation", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketLocationOutput{}, nil)
+	client.On("GetPublicAccessBlock", mock.Anything, mock.MatchedBy(func(p *s3.GetPublicAccessBlockInput) bool {
+		return *p.Bucket == "all-off-bucket"
+	}), mock.Anything).Return(&s3.GetPublicAccessBlockOutput{PublicAccessBlockConfiguration: &types.PublicAccessBlockConfiguration{
+		BlockPublicAcls: aws.Bool(false), IgnorePublicAcls: aws.Bool(false), BlockPublicPolicy: aws.Bool(false), RestrictPublicBuckets: aws.Bool(false),
+	}}, nil)
+	client.On("GetPublicAccessBlock", mock.Anything, mock.MatchedBy(func(p *s3.GetPublicAccessBlockInput) bool {
+		return *p.Bucket == "partial-bucket"
+	}), mock.Anything).Return(&s3.GetPublicAccessBlockOutput{PublicAccessBlockConfiguration: &types.PublicAccessBlockConfiguration{
+		BlockPublicAcls: aws.Bool(true), IgnorePublicAcls: aws.Bool(true), BlockPublicPolicy: aws.Bool(false), RestrictPublicBuckets: aws.Bool(false),
+	}}, nil)
+	client.On("GetPublicAccessBlock", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetPublicAccessBlockOutput)(nil), err404)
+	controlClient.On("ListStorageLensConfigurations", mock.Anything, mock.Anything, mock.Anything).Return(&s3control.ListStorageLensConfigurationsOutput{}, nil)
+	// The account blocks public policies for every bucket.
+	controlClient.On("GetPublicAccessBlock", mock.Anything, mock.Anything, mock.Anything).Return(&s3control.GetPublicAccessBlockOutput{
+		PublicAccessBlockConfiguration: &s3controltypes.PublicAccessBlockConfiguration{
+			BlockPublicPolicy: aws.Bool(true), RestrictPublicBuckets: aws.Bool(true),
+		},
+	}, nil)
+
+	AccountID = "123456789012"
+	defer func() { AccountID = "" }()
+
+	tbl := table.SetTable()
 	rules := config.RulesConfig{
 		Rules: map[string]config.Rule{
-			"s3-encryption":            {Service: "S3", Level: "Alert", Issue: "Bucket encryption is not set"},
-			"s3-public-access":         {Service: "S3", Level: "Alert", Issue: "Block public access is all off"},
-			"s3-lifecycle":             {Service: "S3", Level: "Warning", Issue: "Lifecycle policy is not set"},
-			"s3-object-lock":           {Service: "S3", Level: "Warning", Issue: "Object Lock is not enabled"},
-			"s3-sse-kms-encryption":    {Service: "S3", Level: "Warning", Issue: "SSE-KMS encryption is not set"},
-			"s3-server-access-logging": {Service: "S3", Level: "Warning", Issue: "Server access logging is not enabled"},
-			"s3-storage-lens-enabled":  {Service: "S3", Level: "Warning", Issue: "S3 Storage Lens is not enabled"},
+			"s3-public-access": {Service: "S3", Level: "Alert", Issue: "Block public access is not fully enabled"},
 		},
 	}
+	for _, bucket := range []string{"all-off-bucket", "partial-bucket", "missing-bucket"} {
+		accountBlock := s3Internal.GetAccountPublicAccessBlock(controlClient, AccountID)
+		checkPublicAccessBlock(s3Internal.GetBucketPublicAccessBlock(client, bucket), accountBlock, bucket, tbl, rules)
+	}
 
-	// テスト対象の関数を呼び出し
-	checkS3Configurations(client, controlClient, tbl, rules)
+	var settings []string
+	for _, row := range tbl.Rows() {
+		settings = append(settings, row.Status+" "+row.Setting)
+	}
+	assert.Equal(t, []string{
+		"Fail Off: BlockPublicAcls, IgnorePublicAcls",
+		"Pass Enabled (account)",
+		"Fail Off: BlockPublicAcls, IgnorePublicAcls",
+	}, settings)
+}
 
-	// テーブルの内容を検証
-	// Storage Lens: 1 check
-	// test-log-bucket: Encryption, Public, Lifecycle, ObjectLock, SSE-KMS, AccessLogs (6 checks)
-	// test-bucket: Encryption, Public, Lifecycle, ObjectLock, SSE-KMS, AccessLogs (6 checks)
-	// Total rows = 1 + 6 + 6 = 13
-	assert.Equal(t, 13, tbl.NumLines())
+func TestCheckBucketPolicyAndACL(t *testing.T) {
+	client := new(MockS3Client)
+	err404 := MockHTTPStatusError{StatusCode: 404}
+	policy := `{
+		"Version": "2012-10-17",
+		"Statement": [
+			{"Sid": "PublicRead", "Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::open-bucket/*"},
+			{"Sid": "VpceOnly", "Effect": "Allow", "Principal": {"AWS": "*"}, "Action": "s3:*", "Resource": "arn:aws:s3:::open-bucket/*",
+			 "Condition": {"StringEquals": {"aws:SourceVpce": "vpce-1234"}}},
+			{"Sid": "NotAccount", "Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::open-bucket/*",
+			 "Condition": {"StringNotEquals": {"aws:SourceAccount": "111122223333"}}},
+			{"Sid": "VpceIfExists", "Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::open-bucket/*",
+			 "Condition": {"StringEqualsIfExists": {"aws:SourceVpce": "vpce-1234"}}},
+			{"Sid": "AnyVpc", "Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::open-bucket/*",
+			 "Condition": {"Null": {"aws:SourceVpc": "false"}}},
+			{"Sid": "AnyIp", "Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::open-bucket/*",
+			 "Condition": {"IpAddress": {"aws:SourceIp": ["10.0.0.0/8", "0.0.0.0/0"]}}},
+			{"Sid": "AnyOrg", "Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::open-bucket/*",
+			 "Condition": {"ForAnyValue:StringLike": {"aws:PrincipalOrgPaths": "o-1234/*"}}},
+			{"Effect": "Allow", "Principal": {"AWS": ["arn:aws:iam::111122223333:role/reader", "444455556666", "arn:aws:iam::123456789012:root"]}, "Action": "s3:GetObject", "Resource": "arn:aws:s3:::open-bucket/*"},
+			{"Effect": "Deny", "Principal": "*", "Action": "s3:*", "Resource": "arn:aws:s3:::open-bucket/*",
+			 "Condition": {"Bool": {"aws:SecureTransport": false}}}
+		]
+	}`
+	client.On("GetBucketPolicy", mock.Anything, mock.MatchedBy(func(p *s3.GetBucketPolicyInput) bool {
+		return *p.Bucket == "open-bucket"
+	}), mock.Anything).Return(&s3.GetBucketPolicyOutput{Policy: aws.String(policy)}, nil)
+	client.On("GetBucketPolicy", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketPolicyOutput)(nil), err404)
+	client.On("GetBucketAcl", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketAclOutput{Owner: &types.Owner{ID: aws.String("owner")}, Grants: []types.Grant{
+		{Grantee: &types.Grantee{Type: types.TypeCanonicalUser, ID: aws.String("owner")}, Permission: types.PermissionFullControl},
+		{Grantee: &types.Grantee{Type: types.TypeGroup, URI: aws.String("http://acs.amazonaws.com/groups/global/AllUsers")}, Permission: types.PermissionRead},
+	}}, nil)
+
+	AccountID = "123456789012"
+	trustedAccounts = map[string]bool{"444455556666": true}
+	defer func() {
+		AccountID = ""
+		trustedAccounts = nil
+	}()
+
+	settings := func(block s3Internal.PublicAccessBlock) []string {
+		tbl := table.SetTable()
+		for _, bucket := range []string{"open-bucket", "no-policy-bucket"} {
+			p, ok := s3Internal.GetBucketPolicy(client, bucket)
+			assert.True(t, ok)
+			checkBucketPolicy(p, block, bucket, tbl, s3TestRules())
+			checkBucketACL(s3Internal.GetBucketACL(client, bucket), block, bucket, tbl, s3TestRules())
+		}
+		var out []string
+		for _, row := range tbl.Rows() {
+			out = append(out, row.RuleKey+" "+row.Status+" "+row.Setting)
+		}
+		return out
+	}
+
+	assert.Equal(t, []string{
+		// Negated, IfExists, Null, ForAnyValue: and wildcard-valued
+		// conditions do not restrict who can use the statement.
+		"s3-policy-public Fail Public: PublicRead, NotAccount, VpceIfExists, AnyVpc, AnyIp, AnyOrg",
+		"s3-policy-cross-account Fail Untrusted: 111122223333",
+		"s3-secure-transport Pass Denied",
+		"s3-acl-public Fail AllUsers: READ",
+		"s3-acl-grants Fail AllUsers: READ",
+		"s3-policy-public Pass No policy",
+		"s3-policy-cross-account Pass No policy",
+		"s3-secure-transport Fail No policy",
+		"s3-acl-public Fail AllUsers: READ",
+		"s3-acl-grants Fail AllUsers: READ",
+	}, settings(s3Internal.PublicAccessBlock{}))
+
+	// Block Public Access makes the public statements and grants ineffective.
+	assert.Equal(t, []string{
+		"s3-policy-public Pass Restricted: PublicRead, NotAccount, VpceIfExists, AnyVpc, AnyIp, AnyOrg",
+		"s3-policy-cross-account Fail Untrusted: 111122223333",
+		"s3-secure-transport Pass Denied",
+		"s3-acl-public Pass Ignored: AllUsers: READ",
+		"s3-acl-grants Fail AllUsers: READ",
+		"s3-policy-public Pass No policy",
+		"s3-policy-cross-account Pass No policy",
+		"s3-secure-transport Fail No policy",
+		"s3-acl-public Pass Ignored: AllUsers: READ",
+		"s3-acl-grants Fail AllUsers: READ",
+	}, settings(s3Internal.AllPublicAccessBlocked))
+}
+
+func TestCheckS3LogBucketClassification(t *testing.T) {
+	client := new(MockS3Client)
+	controlClient := new(MockS3ControlClient)
+	err404 := MockHTTPStatusError{StatusCode: 404}
+
+	client.On("ListBuckets", mock.Anything, mock.Anything, mock.Anything).Return(&s3.ListBucketsOutput{Buckets: []types.Bucket{
+		{Name: aws.String("catalog-assets")},
+		{Name: aws.String("audit-trail")},
+		{Name: aws.String("alb-sink")},
+		{Name: aws.String("app-logs")},
+	}}, nil)
+	client.On("GetBucketLocation", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketLocationOutput{}, nil)
+	// catalog-assets delivers its access logs to audit-trail.
+	client.On("GetBucketLogging", mock.Anything, mock.MatchedBy(func(p *s3.GetBucketLoggingInput) bool {
+		return *p.Bucket == "catalog-assets"
+	}), mock.Anything).Return(&s3.GetBucketLoggingOutput{LoggingEnabled: &types.LoggingEnabled{TargetBucket: aws.String("audit-trail")}}, nil)
+	client.On("GetBucketLogging", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketLoggingOutput{}, nil)
+	client.On("GetBucketEncryption", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketEncryptionOutput)(nil), err404)
+	client.On("GetPublicAccessBlock", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetPublicAccessBlockOutput)(nil), err404)
+	client.On("GetBucketLifecycleConfiguration", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketLifecycleConfigurationOutput)(nil), err404)
+	client.On("GetObjectLockConfiguration", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetObjectLockConfigurationOutput)(nil), err404)
+	client.On("GetBucketPolicy", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketPolicyOutput)(nil), err404)
+	client.On("GetBucketAcl", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketAclOutput{}, nil)
+	client.On("GetBucketVersioning", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketVersioningOutput{}, nil)
+	client.On("GetBucketOwnershipControls", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketOwnershipControlsOutput)(nil), err404)
+	client.On("GetBucketTagging", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketTaggingOutput)(nil), err404)
+	controlClient.On("ListStorageLensConfigurations", mock.Anything, mock.Anything, mock.Anything).Return(&s3control.ListStorageLensConfigurationsOutput{}, nil)
+
+	tbl := table.SetTable()
+	targets := s3Internal.LogTargets{"alb-sink": "ELB access logs of my-alb"}
+	checkS3Configurations(singleRegion(client), noKMSKeys(), singleControlRegion(controlClient), targets, tbl, s3TestRules())
+
+	objectLock := make(map[string]string)
+	accessLogging := make(map[string]string)
+	for _, row := range tbl.Rows() {
+		switch row.RuleKey {
+		case "s3-object-lock":
+			objectLock[row.Resource] = row.Status
+		case "s3-server-access-logging":
+			accessLogging[row.Resource] = row.Status
+		}
+	}
+	// Object Lock is only required for log buckets, access logging for the others.
+	assert.Equal(t, map[string]string{"catalog-assets": "Pass", "audit-trail": "Fail", "alb-sink": "Fail", "app-logs": "Fail"}, objectLock)
+	assert.Equal(t, map[string]string{"catalog-assets": "Pass", "audit-trail": "Pass", "alb-sink": "Pass", "app-logs": "Pass"}, accessLogging)
+	// Bucket logging is read once per bucket.
+	client.AssertNumberOfCalls(t, "GetBucketLogging", 4)
+}
+
+func TestCheckS3ConfigurationsSkipsDisabledRules(t *testing.T) {
+	client := new(MockS3Client)
+	controlClient := new(MockS3ControlClient)
+	client.On("ListBuckets", mock.Anything, mock.Anything, mock.Anything).Return(&s3.ListBucketsOutput{Buckets: []types.Bucket{
+		{Name: aws.String("app-logs")},
+		{Name: aws.String("assets")},
+	}}, nil)
+	client.On("GetBucketLocation", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketLocationOutput{}, nil)
+	client.On("GetBucketEncryption", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketEncryptionOutput{}, nil)
+
+	rules := s3TestRules()
+	filter, err := config.ParseFilter("s3-encryption", "", "")
+	assert.NoError(t, err)
+	assert.NoError(t, filter.Apply(&rules))
+
+	// Only the calls of s3-encryption are mocked: any other call fails the test.
+	tbl := table.SetTable()
+	checkS3Configurations(singleRegion(client), noKMSKeys(), singleControlRegion(controlClient), nil, tbl, rules)
+
+	var results []string
+	for _, row := range tbl.Rows() {
+		results = append(results, row.Resource+" "+row.RuleKey+" "+row.Status)
+	}
+	assert.Equal(t, []string{"app-logs s3-encryption Pass", "assets s3-encryption Pass"}, results)
+	client.AssertNumberOfCalls(t, "GetBucketEncryption", 2)
+}
+
+func TestCheckS3ConfigurationsARNPartition(t *testing.T) {
+	client := new(MockS3Client)
+	client.On("ListBuckets", mock.Anything, mock.Anything, mock.Anything).Return(&s3.ListBucketsOutput{Buckets: []types.Bucket{
+		{Name: aws.String("prod-assets")},
+		{Name: aws.String("dev-assets")},
+	}}, nil)
+	client.On("GetBucketLocation", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketLocationOutput{}, nil)
+	client.On("GetBucketEncryption", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketEncryptionOutput{}, nil)
+
+	rules := s3TestRules()
+	filter, err := config.ParseFilter("s3-encryption", "", "")
+	assert.NoError(t, err)
+	assert.NoError(t, filter.Apply(&rules))
+	resources, err := config.ParseResourceFilter([]string{"arn:aws-cn:s3:::prod-*"})
+	assert.NoError(t, err)
+	config.Resources = resources
+	Partition = "aws-cn"
+	defer func() {
+		config.Resources = config.ResourceFilter{}
+		Partition = "aws"
+	}()
+
+	tbl := table.SetTable()
+	checkS3Configurations(singleRegion(client), noKMSKeys(), singleControlRegion(new(MockS3ControlClient)), nil, tbl, rules)
+
+	var resourcesChecked []string
+	for _, row := range tbl.Rows() {
+		resourcesChecked = append(resourcesChecked, row.Resource)
+	}
+	assert.Equal(t, []string{"prod-assets"}, resourcesChecked)
+}
+
+func TestCheckBucketVersioning(t *testing.T) {
+	client := new(MockS3Client)
+	err404 := MockHTTPStatusError{StatusCode: 404}
+	bucketIs := func(name string) interface{} {
+		return mock.MatchedBy(func(p interface{}) bool {
+			switch in := p.(type) {
+			case *s3.GetBucketVersioningInput:
+				return *in.Bucket == name
+			case *s3.GetBucketLifecycleConfigurationInput:
+				return *in.Bucket == name
+			case *s3.GetBucketReplicationInput:
+				return *in.Bucket == name
+			case *s3.GetBucketLocationInput:
+				return *in.Bucket == name
+			}
+			return false
+		})
+	}
+
+	client.On("GetBucketVersioning", mock.Anything, bucketIs("payments"), mock.Anything).Return(&s3.GetBucketVersioningOutput{Status: types.BucketVersioningStatusEnabled}, nil)
+	client.On("GetBucketVersioning", mock.Anything, bucketIs("ledger"), mock.Anything).Return(&s3.GetBucketVersioningOutput{
+		Status: types.BucketVersioningStatusEnabled, MFADelete: types.MFADeleteStatusEnabled,
+	}, nil)
+	client.On("GetBucketVersioning", mock.Anything, bucketIs("scratch"), mock.Anything).Return(&s3.GetBucketVersioningOutput{Status: types.BucketVersioningStatusSuspended}, nil)
+	client.On("GetBucketLifecycleConfiguration", mock.Anything, bucketIs("ledger"), mock.Anything).Return(&s3.GetBucketLifecycleConfigurationOutput{Rules: []types.LifecycleRule{{
+		Status:                      types.ExpirationStatusEnabled,
+		NoncurrentVersionExpiration: &types.NoncurrentVersionExpiration{NoncurrentDays: aws.Int32(90)},
+	}}}, nil)
+	client.On("GetBucketLifecycleConfiguration", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketLifecycleConfigurationOutput)(nil), err404)
+	replicateTo := func(dest string) *s3.GetBucketReplicationOutput {
+		return &s3.GetBucketReplicationOutput{ReplicationConfiguration: &types.ReplicationConfiguration{Rules: []types.ReplicationRule{{
+			Status:      types.ReplicationRuleStatusEnabled,
+			Destination: &types.Destination{Bucket: aws.String("arn:aws:s3:::" + dest)},
+		}}}}
+	}
+	client.On("GetBucketReplication", mock.Anything, bucketIs("payments"), mock.Anything).Return(replicateTo("payments-replica"), nil)
+	client.On("GetBucketReplication", mock.Anything, bucketIs("ledger"), mock.Anything).Return(replicateTo("ledger-dr"), nil)
+	client.On("GetBucketLocation", mock.Anything, bucketIs("ledger-dr"), mock.Anything).Return(&s3.GetBucketLocationOutput{LocationConstraint: types.BucketLocationConstraintApNortheast3}, nil)
+	client.On("GetBucketLocation", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketLocationOutput{LocationConstraint: types.BucketLocationConstraintApNortheast1}, nil)
+
+	tbl := table.SetTable()
+	for _, b := range []s3Bucket{
+		{Name: "payments", Region: "ap-northeast-1", Critical: true},
+		{Name: "ledger", Region: "ap-northeast-1", Critical: true},
+		{Name: "scratch", Region: "ap-northeast-1"},
+	} {
+		checkBucketVersioning(singleRegion(client), b, make(map[string]interface{}), tbl, s3TestRules())
+		checkBucketReplication(singleRegion(client), b, make(map[string]interface{}), tbl, s3TestRules())
+	}
+
+	var settings []string
+	for _, row := range tbl.Rows() {
+		settings = append(settings, row.Resource+" "+row.RuleKey+" "+row.Status+" "+row.Setting)
+	}
+	assert.Equal(t, []string{
+		"payments s3-versioning Pass Enabled",
+		"payments s3-noncurrent-version-expiration Fail Disabled",
+		"payments s3-mfa-delete Fail Disabled",
+		"payments s3-replication Fail Same region: payments-replica",
+		"ledger s3-versioning Pass Enabled",
+		"ledger s3-noncurrent-version-expiration Pass Enabled",
+		"ledger s3-mfa-delete Pass Enabled",
+		"ledger s3-replication Pass Cross-region: ledger-dr (ap-northeast-3)",
+		"scratch s3-versioning Fail Suspended",
+		"scratch s3-noncurrent-version-expiration Pass Not versioned",
+		"scratch s3-mfa-delete Pass Not critical",
+		"scratch s3-replication Pass Not critical",
+	}, settings)
+}
+
+func TestCheckBucketNotifications(t *testing.T) {
+	bucketIs := func(name string) interface{} {
+		return mock.MatchedBy(func(p *s3.GetBucketNotificationConfigurationInput) bool { return *p.Bucket == name })
+	}
+	client := new(MockS3Client)
+	client.On("GetBucketNotificationConfiguration", mock.Anything, bucketIs("payments"), mock.Anything).Return(&s3.GetBucketNotificationConfigurationOutput{
+		QueueConfigurations:      []types.QueueConfiguration{{QueueArn: aws.String("arn:aws:sqs:ap-northeast-1:123456789012:payments-events")}},
+		EventBridgeConfiguration: &types.EventBridgeConfiguration{},
+	}, nil)
+	client.On("GetBucketNotificationConfiguration", mock.Anything, bucketIs("ledger"), mock.Anything).Return(&s3.GetBucketNotificationConfigurationOutput{}, nil)
+
+	tbl := table.SetTable()
+	docs := make(map[string]map[string]interface{})
+	for _, b := range []s3Bucket{
+		{Name: "payments", Region: "ap-northeast-1", Critical: true},
+		{Name: "ledger", Region: "ap-northeast-1", Critical: true},
+		{Name: "scratch", Region: "ap-northeast-1"},
+	} {
+		docs[b.Name] = make(map[string]interface{})
+		checkBucketNotifications(singleRegion(client), b, docs[b.Name], tbl, s3TestRules())
+	}
+
+	var settings []string
+	for _, row := range tbl.Rows() {
+		settings = append(settings, row.Resource+" "+row.Status+" "+row.Setting)
+	}
+	assert.Equal(t, []string{
+		"payments Pass SQS, EventBridge",
+		"ledger Fail Disabled",
+		"scratch Pass Not critical",
+	}, settings)
+	assert.Equal(t, []string{"SQS", "EventBridge"}, docs["payments"]["NotificationDestinations"])
+	// Buckets that are not critical are not looked up.
+	client.AssertNumberOfCalls(t, "GetBucketNotificationConfiguration", 2)
+}
+
+func TestCheckObjectOwnershipAndACLGrants(t *testing.T) {
+	client := new(MockS3Client)
+	err404 := MockHTTPStatusError{StatusCode: 404}
+	client.On("GetBucketOwnershipControls", mock.Anything, mock.MatchedBy(func(p *s3.GetBucketOwnershipControlsInput) bool {
+		return *p.Bucket == "new-bucket"
+	}), mock.Anything).Return(&s3.GetBucketOwnershipControlsOutput{OwnershipControls: &types.OwnershipControls{
+		Rules: []types.OwnershipControlsRule{{ObjectOwnership: types.ObjectOwnershipBucketOwnerEnforced}},
+	}}, nil)
+	// Buckets created before ACLs were disabled by default have no ownership controls.
+	client.On("GetBucketOwnershipControls", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketOwnershipControlsOutput)(nil), err404)
+	client.On("GetBucketAcl", mock.Anything, mock.MatchedBy(func(p *s3.GetBucketAclInput) bool {
+		return *p.Bucket == "new-bucket"
+	}), mock.Anything).Return(&s3.GetBucketAclOutput{Owner: &types.Owner{ID: aws.String("owner")}, Grants: []types.Grant{
+		{Grantee: &types.Grantee{Type: types.TypeCanonicalUser, ID: aws.String("owner")}, Permission: types.PermissionFullControl},
+	}}, nil)
+	client.On("GetBucketAcl", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketAclOutput{Owner: &types.Owner{ID: aws.String("owner")}, Grants: []types.Grant{
+		{Grantee: &types.Grantee{Type: types.TypeCanonicalUser, ID: aws.String("owner")}, Permission: types.PermissionFullControl},
+		{Grantee: &types.Grantee{Type: types.TypeGroup, URI: aws.String("http://acs.amazonaws.com/groups/s3/LogDelivery")}, Permission: types.PermissionWrite},
+		{Grantee: &types.Grantee{Type: types.TypeCanonicalUser, ID: aws.String("79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be")}, Permission: types.PermissionRead},
+	}}, nil)
+
+	tbl := table.SetTable()
+	for _, bucket := range []string{"new-bucket", "legacy-bucket"} {
+		checkObjectOwnership(s3Internal.GetObjectOwnership(client, bucket), bucket, tbl, s3TestRules())
+		checkBucketACL(s3Internal.GetBucketACL(client, bucket), s3Internal.PublicAccessBlock{}, bucket, tbl, s3TestRules())
+	}
+
+	var settings []string
+	for _, row := range tbl.Rows() {
+		settings = append(settings, row.Resource+" "+row.RuleKey+" "+row.Status+" "+row.Setting)
+	}
+	assert.Equal(t, []string{
+		"new-bucket s3-object-ownership Pass BucketOwnerEnforced",
+		"new-bucket s3-acl-public Pass Private",
+		"new-bucket s3-acl-grants Pass Owner only",
+		"legacy-bucket s3-object-ownership Fail ObjectWriter",
+		"legacy-bucket s3-acl-public Pass Private",
+		"legacy-bucket s3-acl-grants Fail LogDelivery: WRITE, id=79a59df900b9...: READ",
+	}, settings)
+}
+
+func TestCheckBucketKMSKey(t *testing.T) {
+	client := new(MockKMSClient)
+	keyIs := func(id string) interface{} {
+		return mock.MatchedBy(func(p interface{}) bool {
+			switch in := p.(type) {
+			case *kms.DescribeKeyInput:
+				return *in.KeyId == id
+			case *kms.GetKeyRotationStatusInput:
+				return *in.KeyId == id
+			}
+			return false
+		})
+	}
+	customerKey := func(arn string, state kmstypes.KeyState) *kms.DescribeKeyOutput {
+		return &kms.DescribeKeyOutput{KeyMetadata: &kmstypes.KeyMetadata{
+			Arn: aws.String(arn), KeyState: state, KeyManager: kmstypes.KeyManagerTypeCustomer,
+			KeySpec: kmstypes.KeySpecSymmetricDefault, Origin: kmstypes.OriginTypeAwsKms,
+		}}
+	}
+	rotatedKey := "arn:aws:kms:ap-northeast-1:123456789012:key/rotated"
+	staleKey := "arn:aws:kms:ap-northeast-1:123456789012:key/stale"
+	deletedKey := "arn:aws:kms:ap-northeast-1:123456789012:key/deleted"
+	client.On("DescribeKey", mock.Anything, keyIs(rotatedKey), mock.Anything).Return(customerKey(rotatedKey, kmstypes.KeyStateEnabled), nil)
+	client.On("DescribeKey", mock.Anything, keyIs(staleKey), mock.Anything).Return(customerKey(staleKey, kmstypes.KeyStateEnabled), nil)
+	client.On("DescribeKey", mock.Anything, keyIs(deletedKey), mock.Anything).Return(customerKey(deletedKey, kmstypes.KeyStatePendingDeletion), nil)
+	client.On("DescribeKey", mock.Anything, keyIs("alias/aws/s3"), mock.Anything).Return(&kms.DescribeKeyOutput{KeyMetadata: &kmstypes.KeyMetadata{
+		Arn: aws.String("arn:aws:kms:ap-northeast-1:123456789012:key/aws-s3"), KeyState: kmstypes.KeyStateEnabled, KeyManager: kmstypes.KeyManagerTypeAws,
+	}}, nil)
+	client.On("DescribeKey", mock.Anything, keyIs("alias/gone"), mock.Anything).Return((*kms.DescribeKeyOutput)(nil), &kmstypes.NotFoundException{})
+	client.On("DescribeKey", mock.Anything, keyIs("alias/denied"), mock.Anything).Return((*kms.DescribeKeyOutput)(nil), fmt.Errorf("AccessDeniedException"))
+	client.On("GetKeyRotationStatus", mock.Anything, keyIs(rotatedKey), mock.Anything).Return(&kms.GetKeyRotationStatusOutput{KeyRotationEnabled: true}, nil)
+	client.On("GetKeyRotationStatus", mock.Anything, keyIs(staleKey), mock.Anything).Return(&kms.GetKeyRotationStatusOutput{}, nil)
+
+	var regions []string
+	keys := kmsInternal.NewKeys(func(region string) api.KMSClient {
+		regions = append(regions, region)
+		return client
+	})
+	tbl := table.SetTable()
+	for _, c := range []struct {
+		bucket     string
+		encryption s3Internal.DefaultEncryption
+	}{
+		{"sse-s3", s3Internal.DefaultEncryption{Algorithm: "AES256"}},
+		{"rotated", s3Internal.DefaultEncryption{Algorithm: "aws:kms", KMSKeyID: rotatedKey, BucketKeyEnabled: true}},
+		{"rotated-again", s3Internal.DefaultEncryption{Algorithm: "aws:kms", KMSKeyID: rotatedKey, BucketKeyEnabled: true}},
+		{"stale", s3Internal.DefaultEncryption{Algorithm: "aws:kms", KMSKeyID: staleKey}},
+		{"deleted", s3Internal.DefaultEncryption{Algorithm: "aws:kms", KMSKeyID: deletedKey, BucketKeyEnabled: true}},
+		{"aws-managed", s3Internal.DefaultEncryption{Algorithm: "aws:kms", BucketKeyEnabled: true}},
+		{"gone", s3Internal.DefaultEncryption{Algorithm: "aws:kms:dsse", KMSKeyID: "alias/gone"}},
+		{"denied", s3Internal.DefaultEncryption{Algorithm: "aws:kms", KMSKeyID: "alias/denied", BucketKeyEnabled: true}},
+	} {
+		b := s3Bucket{Name: c.bucket, Region: "ap-northeast-1"}
+		checkBucketKMSKey(keys, b, c.encryption, make(map[string]interface{}), tbl, s3TestRules())
+	}
+
+	var settings []string
+	for _, row := range tbl.Rows() {
+		if row.Status == "Fail" {
+			settings = append(settings, row.Resource+" "+row.RuleKey+" "+row.Setting)
+		}
+	}
+	assert.Equal(t, []string{
+		"stale s3-bucket-key Disabled",
+		"stale s3-kms-key-rotation Disabled",
+		"deleted s3-kms-key PendingDeletion: " + deletedKey,
+		"aws-managed s3-kms-customer-managed AWS managed",
+		"gone s3-bucket-key Disabled",
+		"gone s3-kms-key Not found: alias/gone",
+		// A key that cannot be described fails its rules instead of leaving
+		// them out.
+		"denied s3-kms-key Cannot describe alias/denied",
+		"denied s3-kms-customer-managed Cannot describe alias/denied",
+		"denied s3-kms-key-rotation Cannot describe alias/denied",
+	}, settings)
+	assert.Equal(t, 32, tbl.NumLines())
+	// A key shared by buckets is described once, and there is one client per region.
+	client.AssertNumberOfCalls(t, "DescribeKey", 6)
+	client.AssertNotCalled(t, "GetKeyRotationStatus", mock.Anything, keyIs(deletedKey), mock.Anything)
+	assert.Equal(t, []string{"ap-northeast-1"}, regions)
+}
+
+func TestCheckS3AccessPoints(t *testing.T) {
+	err404 := MockHTTPStatusError{StatusCode: 404}
+	tokyo := new(MockS3ControlClient)
+	tokyo.On("ListAccessPoints", mock.Anything, mock.Anything, mock.Anything).Return(&s3control.ListAccessPointsOutput{AccessPointList: []s3controltypes.AccessPoint{
+		{Name: aws.String("analytics-vpc"), NetworkOrigin: s3controltypes.NetworkOriginVpc, VpcConfiguration: &s3controltypes.VpcConfiguration{VpcId: aws.String("vpc-1234")}},
+		{Name: aws.String("partner-share"), NetworkOrigin: s3controltypes.NetworkOriginInternet},
+	}}, nil)
+	tokyo.On("GetAccessPoint", mock.Anything, mock.Anything, mock.Anything).Return(&s3control.GetAccessPointOutput{}, nil)
+	tokyo.On("GetAccessPointPolicy", mock.Anything, mock.MatchedBy(func(p *s3control.GetAccessPointPolicyInput) bool {
+		return *p.Name == "partner-share"
+	}), mock.Anything).Return(&s3control.GetAccessPointPolicyOutput{Policy: aws.String(`{
+		"Statement": [
+			{"Sid": "Anyone", "Effect": "Allow", "Principal": "*", "Action": "s3:GetObject"},
+			{"Sid": "Partner", "Effect": "Allow", "Principal": {"AWS": "444455556666"}, "Action": "s3:*"}
+		]
+	}`)}, nil)
+	tokyo.On("GetAccessPointPolicy", mock.Anything, mock.Anything, mock.Anything).Return((*s3control.GetAccessPointPolicyOutput)(nil), err404)
+	oregon := new(MockS3ControlClient)
+	oregon.On("ListMultiRegionAccessPoints", mock.Anything, mock.Anything, mock.Anything).Return(&s3control.ListMultiRegionAccessPointsOutput{AccessPoints: []s3controltypes.MultiRegionAccessPointReport{
+		{Name: aws.String("global-assets"), Alias: aws.String("mfzwi23gnjvgw.mrap"), PublicAccessBlock: &s3controltypes.PublicAccessBlockConfiguration{
+			BlockPublicAcls: aws.Bool(true), IgnorePublicAcls: aws.Bool(true), BlockPublicPolicy: aws.Bool(false), RestrictPublicBuckets: aws.Bool(false),
+		}},
+	}}, nil)
+	oregon.On("GetMultiRegionAccessPointPolicy", mock.Anything, mock.Anything, mock.Anything).Return(&s3control.GetMultiRegionAccessPointPolicyOutput{
+		Policy: &s3controltypes.MultiRegionAccessPointPolicyDocument{Established: &s3controltypes.EstablishedMultiRegionAccessPointPolicy{
+			Policy: aws.String(`{"Statement": {"Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::123456789012:role/app"}, "Action": "s3:GetObject"}}`),
+		}},
+	}, nil)
+
+	AccountID = "123456789012"
+	defer func() { AccountID = "" }()
+	controls := s3Internal.NewControlClients(func(region string) api.S3ControlClient {
+		if region == "us-west-2" {
+			return oregon
+		}
+		return tokyo
+	})
+	tbl := table.SetTable()
+	checkS3AccessPoints(controls, []string{"ap-northeast-1"}, s3Internal.PublicAccessBlock{}, tbl, s3TestRules())
+
+	var settings []string
+	for _, row := range tbl.Rows() {
+		settings = append(settings, row.Resource+" "+row.RuleKey+" "+row.Status+" "+row.Setting)
+	}
+	assert.Equal(t, []string{
+		"analytics-vpc s3-access-point-vpc Pass VPC: vpc-1234",
+		"analytics-vpc s3-access-point-policy Pass No policy",
+		"partner-share s3-access-point-vpc Fail Internet",
+		"partner-share s3-access-point-policy Fail Public: Anyone; All actions: Partner",
+		"global-assets s3-mrap-public-access Fail Off: BlockPublicPolicy, RestrictPublicBuckets",
+		"global-assets s3-access-point-policy Pass No wildcards",
+	}, settings)
+}
+
+func TestCheckS3AccessPointsWithoutPolicyRule(t *testing.T) {
+	err404 := MockHTTPStatusError{StatusCode: 404}
+	tokyo := new(MockS3ControlClient)
+	tokyo.On("ListAccessPoints", mock.Anything, mock.Anything, mock.Anything).Return(&s3control.ListAccessPointsOutput{AccessPointList: []s3controltypes.AccessPoint{
+		{Name: aws.String("partner-share"), NetworkOrigin: s3controltypes.NetworkOriginInternet},
+	}}, nil)
+	tokyo.On("GetAccessPoint", mock.Anything, mock.Anything, mock.Anything).Return(&s3control.GetAccessPointOutput{}, nil)
+	tokyo.On("GetAccessPointPolicy", mock.Anything, mock.Anything, mock.Anything).Return((*s3control.GetAccessPointPolicyOutput)(nil), err404)
+	oregon := new(MockS3ControlClient)
+	oregon.On("ListMultiRegionAccessPoints", mock.Anything, mock.Anything, mock.Anything).Return(&s3control.ListMultiRegionAccessPointsOutput{AccessPoints: []s3controltypes.MultiRegionAccessPointReport{
+		{Name: aws.String("global-assets"), Alias: aws.String("mfzwi23gnjvgw.mrap"), PublicAccessBlock: &s3controltypes.PublicAccessBlockConfiguration{
+			BlockPublicAcls: aws.Bool(true), IgnorePublicAcls: aws.Bool(true), BlockPublicPolicy: aws.Bool(true), RestrictPublicBuckets: aws.Bool(true),
+		}},
+	}}, nil)
+	oregon.On("GetMultiRegionAccessPointPolicy", mock.Anything, mock.Anything, mock.Anything).Return((*s3control.GetMultiRegionAccessPointPolicyOutput)(nil), err404)
+
+	AccountID = "123456789012"
+	resetCollected()
+	recordInventory = true
+	defer func() {
+		AccountID = ""
+		recordInventory = false
+		resetCollected()
+	}()
+	controls := s3Internal.NewControlClients(func(region string) api.S3ControlClient {
+		if region == "us-west-2" {
+			return oregon
+		}
+		return tokyo
+	})
+	rules := s3TestRules()
+	filter, err := config.ParseFilter("", "s3-access-point-policy", "")
+	assert.NoError(t, err)
+	assert.NoError(t, filter.Apply(&rules))
+	tbl := table.SetTable()
+	checkS3AccessPoints(controls, []string{"ap-northeast-1"}, s3Internal.PublicAccessBlock{}, tbl, rules)
+
+	var settings []string
+	for _, row := range tbl.Rows() {
+		settings = append(settings, row.Resource+" "+row.RuleKey+" "+row.Status)
+	}
+	assert.Equal(t, []string{
+		"partner-share s3-access-point-vpc Fail",
+		"global-assets s3-mrap-public-access Pass",
+	}, settings)
+	// Both access points still reach the inventory, without rows of the
+	// excluded rule.
+	var resources []string
+	for _, res := range collected {
+		resources = append(resources, res.Type+" "+res.Name)
+	}
+	assert.Equal(t, []string{"s3-access-point partner-share", "s3-multi-region-access-point global-assets"}, resources)
+}
+
+func TestCheckS3AccessPointsPartition(t *testing.T) {
+	// us-west-2 manages no Multi-Region Access Points in aws-cn; a call to it
+	// fails the test.
+	oregon := new(MockS3ControlClient)
+	ningxia := new(MockS3ControlClient)
+	ningxia.On("ListAccessPoints", mock.Anything, mock.Anything, mock.Anything).Return(&s3control.ListAccessPointsOutput{AccessPointList: []s3controltypes.AccessPoint{
+		{Name: aws.String("analytics-vpc"), NetworkOrigin: s3controltypes.NetworkOriginVpc, VpcConfiguration: &s3controltypes.VpcConfiguration{VpcId: aws.String("vpc-1234")}},
+	}}, nil)
+
+	AccountID = "123456789012"
+	Partition = "aws-cn"
+	defer func() {
+		AccountID = ""
+		Partition = "aws"
+	}()
+	controls := s3Internal.NewControlClients(func(region string) api.S3ControlClient {
+		if region == "us-west-2" {
+			return oregon
+		}
+		return ningxia
+	})
+	rules := s3TestRules()
+	filter, err := config.ParseFilter("", "s3-access-point-policy", "")
+	assert.NoError(t, err)
+	assert.NoError(t, filter.Apply(&rules))
+	tbl := table.SetTable()
+	checkS3AccessPoints(controls, []string{"cn-northwest-1"}, s3Internal.PublicAccessBlock{}, tbl, rules)
+
+	var settings []string
+	for _, row := range tbl.Rows() {
+		settings = append(settings, row.Resource+" "+row.Status+" "+row.Setting)
+	}
+	assert.Equal(t, []string{
+		"analytics-vpc Pass VPC: vpc-1234",
+		"Multi-Region Access Points - Not available in aws-cn",
+	}, settings)
+}
+
+func TestCheckS3StorageLens(t *testing.T) {
+	entry := func(id string) s3controltypes.ListStorageLensConfigurationEntry {
+		return s3controltypes.ListStorageLensConfigurationEntry{Id: aws.String(id), IsEnabled: true}
+	}
+	configIs := func(id string) interface{} {
+		return mock.MatchedBy(func(p *s3control.GetStorageLensConfigurationInput) bool { return *p.ConfigId == id })
+	}
+	activity := &s3controltypes.AccountLevel{ActivityMetrics: &s3controltypes.ActivityMetrics{IsEnabled: true}}
+	export := &s3controltypes.StorageLensDataExport{S3BucketDestination: &s3controltypes.S3BucketDestination{Arn: aws.String("arn:aws:s3:::lens-export")}}
+
+	virginia := new(MockS3ControlClient)
+	virginia.On("ListStorageLensConfigurations", mock.Anything, mock.Anything, mock.Anything).Return(&s3control.ListStorageLensConfigurationsOutput{
+		StorageLensConfigurationList: []s3controltypes.ListStorageLensConfigurationEntry{entry("default-account-dashboard")},
+	}, nil)
+	virginia.On("GetStorageLensConfiguration", mock.Anything, mock.Anything, mock.Anything).Return(&s3control.GetStorageLensConfigurationOutput{
+		StorageLensConfiguration: &s3controltypes.StorageLensConfiguration{IsEnabled: true, AccountLevel: &s3controltypes.AccountLevel{}},
+	}, nil)
+	tokyo := new(MockS3ControlClient)
+	tokyo.On("ListStorageLensConfigurations", mock.Anything, mock.Anything, mock.Anything).Return(&s3control.ListStorageLensConfigurationsOutput{
+		StorageLensConfigurationList: []s3controltypes.ListStorageLensConfigurationEntry{entry("tokyo-only"), entry("org-wide")},
+	}, nil)
+	tokyo.On("GetStorageLensConfiguration", mock.Anything, configIs("tokyo-only"), mock.Anything).Return(&s3control.GetStorageLensConfigurationOutput{
+		StorageLensConfiguration: &s3controltypes.StorageLensConfiguration{IsEnabled: true, AccountLevel: activity, DataExport: export,
+			Include: &s3controltypes.Include{Regions: []string{"ap-northeast-1"}}},
+	}, nil)
+	tokyo.On("GetStorageLensConfiguration", mock.Anything, configIs("org-wide"), mock.Anything).Return(&s3control.GetStorageLensConfigurationOutput{
+		StorageLensConfiguration: &s3controltypes.StorageLensConfiguration{IsEnabled: true, AccountLevel: activity, DataExport: export},
+	}, nil)
+	controls := s3Internal.NewControlClients(func(region string) api.S3ControlClient {
+		if region == "us-east-1" {
+			return virginia
+		}
+		return tokyo
+	})
+	settings := func(regions []string) []string {
+		tbl := table.SetTable()
+		checkS3StorageLens(controls, regions, tbl, s3TestRules())
+		var out []string
+		for _, row := range tbl.Rows() {
+			out = append(out, row.Resource+" "+row.Status+" "+row.Setting)
+		}
+		return out
+	}
+
+	// The default dashboard has free metrics only and no export.
+	assert.Equal(t, []string{"default-account-dashboard Fail no advanced metrics, no export"}, settings(nil))
+	assert.Equal(t, []string{"org-wide Pass Advanced metrics, all regions, export to arn:aws:s3:::lens-export"}, settings([]string{"ap-northeast-1"}))
+}
+
+func TestCheckS3StorageLensPartition(t *testing.T) {
+	// The default dashboard of aws-cn lives in cn-north-1; a call to
+	// us-east-1 fails the test.
+	virginia := new(MockS3ControlClient)
+	beijing := new(MockS3ControlClient)
+	beijing.On("ListStorageLensConfigurations", mock.Anything, mock.Anything, mock.Anything).Return(&s3control.ListStorageLensConfigurationsOutput{
+		StorageLensConfigurationList: []s3controltypes.ListStorageLensConfigurationEntry{{Id: aws.String("default-account-dashboard"), IsEnabled: true}},
+	}, nil)
+	beijing.On("GetStorageLensConfiguration", mock.Anything, mock.Anything, mock.Anything).Return(&s3control.GetStorageLensConfigurationOutput{
+		StorageLensConfiguration: &s3controltypes.StorageLensConfiguration{IsEnabled: true, AccountLevel: &s3controltypes.AccountLevel{}},
+	}, nil)
+	controls := s3Internal.NewControlClients(func(region string) api.S3ControlClient {
+		if region == "cn-north-1" {
+			return beijing
+		}
+		return virginia
+	})
+
+	Partition = "aws-cn"
+	defer func() { Partition = "aws" }()
+	tbl := table.SetTable()
+	checkS3StorageLens(controls, []string{"cn-north-1"}, tbl, s3TestRules())
+
+	var settings []string
+	for _, row := range tbl.Rows() {
+		settings = append(settings, row.Resource+" "+row.Status+" "+row.Setting)
+	}
+	assert.Equal(t, []string{"default-account-dashboard Fail no advanced metrics, no export"}, settings)
+	// The home region is listed once, though it also holds buckets.
+	beijing.AssertNumberOfCalls(t, "ListStorageLensConfigurations", 1)
+}
+
+func TestCheckS3ConfigurationsUnparsablePolicy(t *testing.T) {
+	client := new(MockS3Client)
+	controlClient := new(MockS3ControlClient)
+	err404 := MockHTTPStatusError{StatusCode: 404}
+	client.On("ListBuckets", mock.Anything, mock.Anything, mock.Anything).Return(&s3.ListBucketsOutput{Buckets: []types.Bucket{
+		{Name: aws.String("assets")},
+	}}, nil)
+	client.On("GetBucketLocation", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketLocationOutput{}, nil)
+	client.On("GetPublicAccessBlock", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetPublicAccessBlockOutput)(nil), err404)
+	client.On("GetBucketPolicy", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketPolicyOutput{Policy: aws.String(`{"Statement": "not a statement"}`)}, nil)
+	controlClient.On("GetPublicAccessBlock", mock.Anything, mock.Anything, mock.Anything).Return((*s3control.GetPublicAccessBlockOutput)(nil), err404)
+
+	rules := s3TestRules()
+	filter, err := config.ParseFilter(strings.Join(s3PolicyRules, ","), "", "")
+	assert.NoError(t, err)
+	assert.NoError(t, filter.Apply(&rules))
+
+	tbl := table.SetTable()
+	checkS3Configurations(singleRegion(client), noKMSKeys(), singleControlRegion(controlClient), nil, tbl, rules)
+
+	var results []string
+	for _, row := range tbl.Rows() {
+		results = append(results, row.RuleKey+" "+row.Status+" "+row.Setting)
+	}
+	// A policy that cannot be parsed fails instead of leaving the rules out.
+	assert.Equal(t, []string{
+		"s3-policy-public Fail Cannot parse policy",
+		"s3-policy-cross-account Fail Cannot parse policy",
+		"s3-secure-transport Fail Cannot parse policy",
+	}, results)
+}
+
+func TestCheckS3ConfigurationsBucketRegionFallback(t *testing.T) {
+	denied := MockHTTPStatusError{StatusCode: 403}
+	bucketIs := func(bucket string) interface{} {
+		return mock.MatchedBy(func(p interface{}) bool {
+			switch in := p.(type) {
+			case *s3.GetBucketLocationInput:
+				return *in.Bucket == bucket
+			case *s3.HeadBucketInput:
+				return *in.Bucket == bucket
+			}
+			return false
+		})
+	}
+	// A 301 from the us-east-1 endpoint names the bucket's region.
+	redirect := &awshttp.ResponseError{ResponseError: &smithyhttp.ResponseError{
+		Response: &smithyhttp.Response{Response: &http.Response{
+			StatusCode: 301,
+			Header:     http.Header{"X-Amz-Bucket-Region": []string{"eu-west-2"}},
+		}},
+		Err: fmt.Errorf("moved permanently"),
+	}}
+
+	location := new(MockS3Client)
+	location.On("GetBucketLocation", mock.Anything, bucketIs("plain"), mock.Anything).Return(&s3.GetBucketLocationOutput{LocationConstraint: types.BucketLocationConstraintApNortheast1}, nil)
+	location.On("GetBucketLocation", mock.Anything, mock.Anything, mock.Anything).Return((*s3.GetBucketLocationOutput)(nil), denied)
+	location.On("HeadBucket", mock.Anything, bucketIs("head"), mock.Anything).Return(&s3.HeadBucketOutput{BucketRegion: aws.String("ap-northeast-1")}, nil)
+	location.On("HeadBucket", mock.Anything, bucketIs("redirected"), mock.Anything).Return((*s3.HeadBucketOutput)(nil), redirect)
+	location.On("HeadBucket", mock.Anything, bucketIs("unknown"), mock.Anything).Return((*s3.HeadBucketOutput)(nil), denied)
+
+	home := new(MockS3Client)
+	home.On("ListBuckets", mock.Anything, mock.Anything, mock.Anything).Return(&s3.ListBucketsOutput{Buckets: []types.Bucket{
+		{Name: aws.String("plain")},
+		{Name: aws.String("head")},
+		{Name: aws.String("redirected")},
+		{Name: aws.String("unknown")},
+	}}, nil)
+	home.On("GetBucketEncryption", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketEncryptionOutput{}, nil)
+	london := new(MockS3Client)
+	london.On("GetBucketEncryption", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketEncryptionOutput{}, nil)
+	clients := s3Internal.NewRegionalClients(func(region string) api.S3Client {
+		switch region {
+		case "us-east-1":
+			return location
+		case "eu-west-2":
+			return london
+		}
+		return home
+	})
+
+	rules := s3TestRules()
+	filter, err := config.ParseFilter("s3-encryption", "", "")
+	assert.NoError(t, err)
+	assert.NoError(t, filter.Apply(&rules))
+
+	tbl := table.SetTable()
+	checkS3Configurations(clients, noKMSKeys(), singleControlRegion(new(MockS3ControlClient)), nil, tbl, rules)
+
+	var results []string
+	for _, row := range tbl.Rows() {
+		results = append(results, row.Resource+" "+row.RuleKey+" "+row.Status+" "+row.Setting)
+	}
+	// A bucket whose region cannot be read is listed instead of stopping the
+	// checks of the others.
+	assert.Equal(t, []string{
+		"plain s3-encryption Pass Enabled",
+		"head s3-encryption Pass Enabled",
+		"redirected s3-encryption Pass Enabled",
+		"unknown  - Region unknown",
+	}, results)
+	london.AssertCalled(t, "GetBucketEncryption", mock.Anything, mock.MatchedBy(func(p *s3.GetBucketEncryptionInput) bool {
+		return *p.Bucket == "redirected"
+	}), mock.Anything)
+	location.AssertNotCalled(t, "HeadBucket", mock.Anything, bucketIs("plain"), mock.Anything)
+}
+
+func TestCheckS3ConfigurationsLocationPartition(t *testing.T) {
+	// The us-east-1 endpoint does not answer for buckets of aws-cn; a call to
+	// it fails the test.
+	global := new(MockS3Client)
+	location := new(MockS3Client)
+	location.On("GetBucketLocation", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketLocationOutput{LocationConstraint: types.BucketLocationConstraint("cn-northwest-1")}, nil)
+	home := new(MockS3Client)
+	home.On("ListBuckets", mock.Anything, mock.Anything, mock.Anything).Return(&s3.ListBucketsOutput{Buckets: []types.Bucket{
+		{Name: aws.String("assets")},
+	}}, nil)
+	home.On("GetBucketEncryption", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetBucketEncryptionOutput{}, nil)
+	clients := s3Internal.NewRegionalClients(func(region string) api.S3Client {
+		switch region {
+		case "us-east-1":
+			return global
+		case "cn-north-1":
+			return location
+		}
+		return home
+	})
+
+	rules := s3TestRules()
+	filter, err := config.ParseFilter("s3-encryption", "", "")
+	assert.NoError(t, err)
+	assert.NoError(t, filter.Apply(&rules))
+	Partition = "aws-cn"
+	defer func() { Partition = "aws" }()
+
+	tbl := table.SetTable()
+	checkS3Configurations(clients, noKMSKeys(), singleControlRegion(new(MockS3ControlClient)), nil, tbl, rules)
+
+	var results []string
+	for _, row := range tbl.Rows() {
+		results = append(results, row.Resource+" "+row.RuleKey+" "+row.Status)
+	}
+	assert.Equal(t, []string{"assets s3-encryption Pass"}, results)
+	location.AssertNumberOfCalls(t, "GetBucketLocation", 1)
 }
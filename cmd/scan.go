@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	s3Internal "awsselfrev/internal/aws/service/s3"
+	"awsselfrev/internal/config"
+	"awsselfrev/internal/history"
+	"awsselfrev/internal/table"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// fatalf stops the checks on an unexpected error. The CLI commands exit; a
+// scan run by serve recovers and reports the error instead.
+var fatalf = log.Fatalf
+
+// scanAbort is the panic value fatalf uses inside a scan.
+type scanAbort struct {
+	err error
+}
+
+// scanResult is the outcome of one run of all checks.
+type scanResult struct {
+	Time     time.Time
+	Duration time.Duration
+	Rows     []table.Row
+	// Levels maps each rule key to the rule's level, also for passed results.
+	Levels map[string]string
+	Err    error
+}
+
+// scan runs the checks of every service once, the way "all" does, and
+// returns the results instead of rendering them. A check that would stop the
+// CLI, or panics, makes the scan fail with its error.
+func scan(cfg aws.Config, rules config.RulesConfig) (result scanResult) {
+	result.Time = time.Now().UTC()
+	defer func() {
+		result.Duration = time.Since(result.Time)
+		if r := recover(); r != nil {
+			result.Rows = nil
+			if abort, ok := r.(scanAbort); ok {
+				result.Err = abort.err
+			} else {
+				result.Err = fmt.Errorf("check failed: %v", r)
+			}
+		}
+	}()
+
+	abort := func(format string, v ...interface{}) {
+		panic(scanAbort{err: fmt.Errorf(format, v...)})
+	}
+	fatalf, s3Internal.Fatalf = abort, abort
+	defer func() { fatalf, s3Internal.Fatalf = log.Fatalf, log.Fatalf }()
+
+	// Start each scan from a clean state.
+	resetCollected()
+	paramGroupCache = make(map[string]map[string]string)
+
+	tbl := table.SetTable()
+	checkAllServices(cfg, tbl, rules)
+	finishResults(history.AllServices, tbl, rules)
+
+	result.Rows = tbl.Rows()
+	result.Levels = make(map[string]string)
+	for _, row := range result.Rows {
+		if row.RuleKey != "" {
+			result.Levels[row.RuleKey] = rules.Get(row.RuleKey).Level
+		}
+	}
+	return result
+}
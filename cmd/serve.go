@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"awsselfrev/internal/config"
+	"awsselfrev/internal/table"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run the checks on a schedule and serve the results over HTTP",
+	Long: `The "serve" command runs the checks of every service, like "all", every
+--interval and serves the results of the last scan:
+
+- /findings  the results as JSON; ?status=Fail returns only failed checks
+- /metrics   Prometheus metrics, e.g. awsselfrev_findings{service,rule,level,status}
+- /healthz   "ok" while the server is running
+
+A scan that fails (e.g. an AWS API error) is logged and reported by the
+awsselfrev_last_scan_success metric; the results of the last successful scan
+are kept. rules.yaml and the flags are read once at start.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := config.LoadConfig()
+		rules := config.LoadRules()
+		addr, _ := cmd.Flags().GetString("addr")
+		interval, _ := cmd.Flags().GetDuration("interval")
+		if interval <= 0 {
+			log.Fatalf("--interval must be positive")
+		}
+
+		// Passed checks are needed for the metrics.
+		table.FailOnly = false
+
+		s := &server{}
+		go s.run(cfg, rules, interval)
+
+		log.Printf("Serving on %s, scanning every %s", addr, interval)
+		if err := http.ListenAndServe(addr, s.handler()); err != nil {
+			log.Fatalf("Failed to serve: %v", err)
+		}
+	},
+}
+
+// server holds the results of the scans.
+type server struct {
+	mu       sync.RWMutex
+	last     scanResult // last successful scan
+	lastScan scanResult // last scan, successful or not
+	scans    int
+}
+
+func (s *server) run(cfg aws.Config, rules config.RulesConfig, interval time.Duration) {
+	for {
+		result := scan(cfg, rules)
+		if result.Err != nil {
+			log.Printf("Warning: Scan failed: %v", result.Err)
+		} else {
+			log.Printf("Scan finished in %s with %d results", result.Duration.Round(time.Millisecond), len(result.Rows))
+		}
+
+		s.mu.Lock()
+		s.lastScan = result
+		if result.Err == nil {
+			s.last = result
+		}
+		s.scans++
+		s.mu.Unlock()
+
+		time.Sleep(interval)
+	}
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/findings", s.handleFindings)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	return mux
+}
+
+type findingsDocument struct {
+	Account  string        `json:"account"`
+	Region   string        `json:"region"`
+	Time     *time.Time    `json:"time"`
+	Findings []findingJSON `json:"findings"`
+}
+
+type findingJSON struct {
+	Service  string `json:"service"`
+	Rule     string `json:"rule"`
+	Level    string `json:"level"`
+	Status   string `json:"status"`
+	Resource string `json:"resource"`
+	Setting  string `json:"setting"`
+	Issue    string `json:"issue"`
+}
+
+func (s *server) handleFindings(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	last := s.last
+	s.mu.RUnlock()
+
+	if last.Time.IsZero() {
+		http.Error(w, "no scan has finished yet", http.StatusServiceUnavailable)
+		return
+	}
+	status := r.URL.Query().Get("status")
+	doc := findingsDocument{Account: AccountID, Region: Region, Time: &last.Time, Findings: []findingJSON{}}
+	for _, row := range last.Rows {
+		if row.RuleKey == "" || (status != "" && !strings.EqualFold(row.Status, status)) {
+			continue
+		}
+		doc.Findings = append(doc.Findings, findingJSON{
+			Service:  row.Service,
+			Rule:     row.RuleKey,
+			Level:    last.Levels[row.RuleKey],
+			Status:   row.Status,
+			Resource: row.Resource,
+			Setting:  row.Setting,
+			Issue:    row.Issue,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		log.Printf("Warning: Failed to write findings: %v", err)
+	}
+}
+
+func (s *server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	last, lastScan, scans := s.last, s.lastScan, s.scans
+	s.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeMetrics(w, last, lastScan, scans)
+}
+
+// writeMetrics writes the Prometheus text exposition of the scan results.
+func writeMetrics(w io.Writer, last scanResult, lastScan scanResult, scans int) {
+	type labels struct{ service, rule, level, status string }
+	counts := make(map[labels]int)
+	for _, row := range last.Rows {
+		if row.RuleKey == "" {
+			continue
+		}
+		counts[labels{row.Service, row.RuleKey, last.Levels[row.RuleKey], row.Status}]++
+	}
+	keys := make([]labels, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.service != b.service {
+			return a.service < b.service
+		}
+		if a.rule != b.rule {
+			return a.rule < b.rule
+		}
+		return a.status < b.status
+	})
+
+	fmt.Fprintln(w, "# HELP awsselfrev_findings Number of check results by service, rule, level and status in the last successful scan.")
+	fmt.Fprintln(w, "# TYPE awsselfrev_findings gauge")
+	for _, k := range keys {
+		fmt.Fprintf(w, "awsselfrev_findings{service=%s,rule=%s,level=%s,status=%s} %d\n",
+			labelValue(k.service), labelValue(k.rule), labelValue(k.level), labelValue(k.status), counts[k])
+	}
+
+	success := 0
+	if scans > 0 && lastScan.Err == nil {
+		success = 1
+	}
+	fmt.Fprintln(w, "# HELP awsselfrev_last_scan_success Whether the last scan finished without error.")
+	fmt.Fprintln(w, "# TYPE awsselfrev_last_scan_success gauge")
+	fmt.Fprintf(w, "awsselfrev_last_scan_success %d\n", success)
+	if !last.Time.IsZero() {
+		fmt.Fprintln(w, "# HELP awsselfrev_last_success_timestamp_seconds Time the last successful scan started.")
+		fmt.Fprintln(w, "# TYPE awsselfrev_last_success_timestamp_seconds gauge")
+		fmt.Fprintf(w, "awsselfrev_last_success_timestamp_seconds %d\n", last.Time.Unix())
+	}
+	if scans > 0 {
+		fmt.Fprintln(w, "# HELP awsselfrev_scan_duration_seconds Duration of the last scan.")
+		fmt.Fprintln(w, "# TYPE awsselfrev_scan_duration_seconds gauge")
+		fmt.Fprintf(w, "awsselfrev_scan_duration_seconds %g\n", lastScan.Duration.Seconds())
+	}
+	fmt.Fprintln(w, "# HELP awsselfrev_scans_total Number of scans run since the server started.")
+	fmt.Fprintln(w, "# TYPE awsselfrev_scans_total counter")
+	fmt.Fprintf(w, "awsselfrev_scans_total %d\n", scans)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func labelValue(s string) string {
+	return `"` + labelEscaper.Replace(s) + `"`
+}
+
+func init() {
+	serveCmd.Flags().String("addr", ":8080", "Address to serve HTTP on")
+	serveCmd.Flags().Duration("interval", time.Hour, "Time between the end of a scan and the start of the next")
+	rootCmd.AddCommand(serveCmd)
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"awsselfrev/internal/table"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testScanResult() scanResult {
+	return scanResult{
+		Time:     time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Duration: 1500 * time.Millisecond,
+		Rows: []table.Row{
+			{RuleKey: "s3-public-access", Service: "S3", Status: "Fail", Level: "Alert", Resource: "assets", Setting: "Disabled"},
+			{RuleKey: "s3-public-access", Service: "S3", Status: "Fail", Level: "Alert", Resource: "uploads", Setting: "Disabled"},
+			{RuleKey: "s3-public-access", Service: "S3", Status: "Pass", Level: "-", Resource: "logs", Setting: "Enabled"},
+			{Service: "ECR", Status: "-", Level: "-", Resource: "No repositories"},
+		},
+		Levels: map[string]string{"s3-public-access": "Alert"},
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	var b strings.Builder
+	result := testScanResult()
+	failed := scanResult{Time: result.Time.Add(time.Hour), Duration: time.Second, Err: errors.New("throttled")}
+	writeMetrics(&b, result, failed, 2)
+	out := b.String()
+
+	assert.Contains(t, out, "# TYPE awsselfrev_findings gauge\n"+
+		`awsselfrev_findings{service="S3",rule="s3-public-access",level="Alert",status="Fail"} 2`+"\n"+
+		`awsselfrev_findings{service="S3",rule="s3-public-access",level="Alert",status="Pass"} 1`+"\n")
+	assert.NotContains(t, out, "ECR")
+	assert.Contains(t, out, "awsselfrev_last_scan_success 0\n")
+	assert.Contains(t, out, "awsselfrev_last_success_timestamp_seconds 1767323045\n")
+	assert.Contains(t, out, "awsselfrev_scan_duration_seconds 1\n")
+	assert.Contains(t, out, "awsselfrev_scans_total 2\n")
+}
+
+func TestLabelValue(t *testing.T) {
+	assert.Equal(t, `"a\"b\\c\nd"`, labelValue("a\"b\\c\nd"))
+}
+
+func TestServeFindings(t *testing.T) {
+	s := &server{}
+	handler := s.handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/findings", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	s.last, s.lastScan, s.scans = testScanResult(), testScanResult(), 1
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/findings?status=fail", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var doc findingsDocument
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+	assert.Len(t, doc.Findings, 2)
+	assert.Equal(t, findingJSON{Service: "S3", Rule: "s3-public-access", Level: "Alert", Status: "Fail", Resource: "assets", Setting: "Disabled"}, doc.Findings[0])
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, "ok\n", rec.Body.String())
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// tagMap turns a list of SDK tags into a map. kv reads the key and value of a
+// tag, since each service has its own tag type.
+func tagMap[T any](list []T, kv func(T) (key, value *string)) map[string]string {
+	tags := make(map[string]string, len(list))
+	for _, tag := range list {
+		key, value := kv(tag)
+		tags[aws.ToString(key)] = aws.ToString(value)
+	}
+	return tags
+}
+
+// tagsOf returns the tags function config.Resources.Match takes, for tags
+// that come with the resource description.
+func tagsOf[T any](list []T, kv func(T) (key, value *string)) func() map[string]string {
+	return func() map[string]string {
+		return tagMap(list, kv)
+	}
+}
+
+// lazyTags returns the tags function config.Resources.Match takes, for tags
+// that need an API call. fetch is only called when a tag selector needs the
+// tags; when it fails, the warning names resource and the resource has no
+// tags.
+func lazyTags(resource string, fetch func() (map[string]string, error)) func() map[string]string {
+	return func() map[string]string {
+		tags, err := fetch()
+		if err != nil {
+			log.Printf("Warning: Failed to list tags for %s: %v", resource, err)
+			return map[string]string{}
+		}
+		return tags
+	}
+}
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTags(t *testing.T) {
+	tags := tagsOf([]types.Tag{{Key: aws.String("Team"), Value: aws.String("payments")}, {Key: aws.String("Empty")}}, ec2Tag)
+	assert.Equal(t, map[string]string{"Team": "payments", "Empty": ""}, tags())
+
+	calls := 0
+	lazy := lazyTags("vol-1", func() (map[string]string, error) {
+		calls++
+		return nil, errors.New("AccessDenied")
+	})
+	assert.Equal(t, 0, calls)
+	assert.Equal(t, map[string]string{}, lazy())
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,485 @@
+package cmd
+
+import (
+	"encoding/json"
+	"log"
+
+	ec2Internal "awsselfrev/internal/aws/service/ec2"
+	s3Internal "awsselfrev/internal/aws/service/s3"
+	"awsselfrev/internal/color"
+	"awsselfrev/internal/config"
+	"awsselfrev/internal/table"
+	"awsselfrev/internal/tfplan"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cwltypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/spf13/cobra"
+)
+
+var tfPlanCmd = &cobra.Command{
+	Use:   "tf-plan <plan.json>",
+	Short: "Check a Terraform plan before the resources exist",
+	Long: `The "tf-plan" command checks the resources in a Terraform plan with the same
+rules as the live checks, so problems such as a missing deletion_protection are
+caught before apply.
+
+Create the plan file with:
+
+  terraform plan -out tfplan
+  terraform show -json tfplan > plan.json
+
+The RESOURCE column shows the Terraform address. Settings that depend on other
+resources (S3 bucket encryption, logging and public access block, ECR
+lifecycle policies, VPC flow logs) are looked up among the resources in the
+same plan. Checks that need the live account, such as RDS log parameters or
+target health, are skipped.`,
+	Args: cobra.ExactArgs(1),
+	// tf-plan only reads the plan file, so it does not need AWS credentials.
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		applyFlags(cmd)
+		if emitRemediation != "" {
+			// The resources do not exist yet; fix the Terraform configuration instead.
+			log.Fatalf("--emit-remediation only works with the live checks")
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		rules := config.LoadRules()
+		tbl := table.SetTable()
+		_, _, _ = color.SetLevelColor()
+
+		plan, err := tfplan.Load(args[0])
+		if err != nil {
+			log.Fatalf("Failed to load Terraform plan %s: %v", args[0], err)
+		}
+
+		checkTerraformPlan(plan, tbl, rules)
+
+		renderResults("Terraform plan", tbl, rules)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tfPlanCmd)
+}
+
+func checkTerraformPlan(plan *tfplan.Plan, tbl *table.Table, rules config.RulesConfig) {
+	if len(plan.Resources) == 0 {
+		table.AddRow(tbl, []string{"Terraform", "-", "-", "No resources", "-", "-"})
+		return
+	}
+
+	for _, r := range selectedPlanResources(plan, "aws_s3_bucket") {
+		checkPlanS3Bucket(plan, r, tbl, rules)
+	}
+	for _, r := range selectedPlanResources(plan, "aws_rds_cluster") {
+		checkPlanRDSCluster(r, tbl, rules)
+	}
+	for _, r := range selectedPlanResources(plan, "aws_db_instance", "aws_rds_cluster_instance") {
+		checkPlanDBInstance(r, tbl, rules)
+	}
+	for _, r := range selectedPlanResources(plan, "aws_vpc") {
+		checkPlanVPC(plan, r, tbl, rules)
+	}
+	for _, r := range selectedPlanResources(plan, "aws_lb", "aws_alb") {
+		checkPlanLoadBalancer(r, tbl, rules)
+	}
+	for _, r := range selectedPlanResources(plan, "aws_cloudwatch_log_group") {
+		checkPlanLogGroup(r, tbl, rules)
+	}
+	for _, r := range selectedPlanResources(plan, "aws_ecr_repository") {
+		checkPlanECRRepository(plan, r, tbl, rules)
+	}
+	for _, r := range selectedPlanResources(plan, "aws_ecs_cluster") {
+		checkPlanECSCluster(r, tbl, rules)
+	}
+	for _, r := range selectedPlanResources(plan, "aws_ecs_service") {
+		checkPlanECSService(r, tbl, rules)
+	}
+	for _, r := range selectedPlanResources(plan, "aws_ecs_task_definition") {
+		checkPlanTaskDefinition(r, tbl, rules)
+	}
+}
+
+// selectedPlanResources applies --resource-filter to the Terraform address,
+// the ARN when it is already known and the tags.
+func selectedPlanResources(plan *tfplan.Plan, types ...string) []*tfplan.Resource {
+	var selected []*tfplan.Resource
+	for _, r := range plan.OfType(types...) {
+		if config.Resources.Match(r.Address, r.String("arn"), r.Tags) {
+			selected = append(selected, r)
+		}
+	}
+	return selected
+}
+
+// The plan resources are converted to the API types the live checks use, with
+// the provider defaults for unset attributes, so both evaluate the same way.
+
+func checkPlanS3Bucket(plan *tfplan.Plan, bucket *tfplan.Resource, tbl *table.Table, rules config.RulesConfig) {
+	name := bucket.String("bucket")
+	if name == "" {
+		// bucket_prefix or a computed name; classify by the address instead.
+		name = bucket.Address
+	}
+	linked := func(resourceType string) []*tfplan.Resource {
+		return plan.Linked(bucket, resourceType, "bucket", name)
+	}
+
+	var encryption s3Internal.DefaultEncryption
+	for _, sse := range linked("aws_s3_bucket_server_side_encryption_configuration") {
+		encryption.Algorithm = sse.String("rule", "apply_server_side_encryption_by_default", "sse_algorithm")
+		encryption.BucketKeyEnabled, _ = sse.Bool("rule", "bucket_key_enabled")
+	}
+	if encryption.Algorithm == "" {
+		encryption.Algorithm = bucket.String("server_side_encryption_configuration", "rule", "apply_server_side_encryption_by_default", "sse_algorithm")
+		encryption.BucketKeyEnabled, _ = bucket.Bool("server_side_encryption_configuration", "rule", "bucket_key_enabled")
+	}
+
+	// New buckets are encrypted with SSE-S3 by default.
+	table.AddResult(tbl, rules.Get("s3-encryption"), "Pass", bucket.Address, "Enabled")
+
+	// New buckets also block all public access by default, so only a declared
+	// aws_s3_bucket_public_access_block can turn settings off.
+	bucketBlock := s3Internal.AllPublicAccessBlocked
+	for _, pab := range linked("aws_s3_bucket_public_access_block") {
+		bucketBlock = planPublicAccessBlock(pab)
+	}
+	var accountBlock s3Internal.PublicAccessBlock
+	for _, pab := range plan.OfType("aws_s3_account_public_access_block") {
+		accountBlock = planPublicAccessBlock(pab)
+	}
+	checkPublicAccessBlock(bucketBlock, accountBlock, bucket.Address, tbl, rules)
+
+	// New buckets have ACLs disabled.
+	ownership := string(s3types.ObjectOwnershipBucketOwnerEnforced)
+	for _, oc := range linked("aws_s3_bucket_ownership_controls") {
+		if v := oc.String("rule", "object_ownership"); v != "" {
+			ownership = v
+		}
+	}
+	checkObjectOwnership(ownership, bucket.Address, tbl, rules)
+
+	// Log sources are only detected in the account, so templates and plans
+	// classify buckets by name and tags.
+	logBucket := s3Internal.IsLogBucket(name, bucket.Tags, nil)
+	ruleLife := rules.Get("s3-lifecycle")
+	if logBucket && len(linked("aws_s3_bucket_lifecycle_configuration")) == 0 && !bucket.IsSet("lifecycle_rule") {
+		table.AddResult(tbl, ruleLife, "Fail", bucket.Address, "Disabled")
+	} else {
+		table.AddResult(tbl, ruleLife, "Pass", bucket.Address, "Enabled")
+	}
+
+	ruleLock := rules.Get("s3-object-lock")
+	objectLock, _ := bucket.Bool("object_lock_enabled")
+	if logBucket && !objectLock {
+		table.AddResult(tbl, ruleLock, "Fail", bucket.Address, "Disabled")
+	} else {
+		table.AddResult(tbl, ruleLock, "Pass", bucket.Address, "Enabled")
+	}
+
+	ruleKms := rules.Get("s3-sse-kms-encryption")
+	if !logBucket && !encryption.KMS() {
+		table.AddResult(tbl, ruleKms, "Fail", bucket.Address, "Disabled")
+	} else {
+		table.AddResult(tbl, ruleKms, "Pass", bucket.Address, "Enabled")
+	}
+	checkBucketKey(encryption, bucket.Address, tbl, rules)
+
+	ruleLog := rules.Get("s3-server-access-logging")
+	if !logBucket && len(linked("aws_s3_bucket_logging")) == 0 && !bucket.IsSet("logging") {
+		table.AddResult(tbl, ruleLog, "Fail", bucket.Address, "Disabled")
+	} else {
+		table.AddResult(tbl, ruleLog, "Pass", bucket.Address, "Enabled")
+	}
+}
+
+func checkPlanRDSCluster(r *tfplan.Resource, tbl *table.Table, rules config.RulesConfig) {
+	cluster := rdstypes.DBCluster{
+		DBClusterIdentifier:     aws.String(r.Address),
+		StorageEncrypted:        aws.Bool(planBool(r, false, "storage_encrypted")),
+		DeletionProtection:      aws.Bool(planBool(r, false, "deletion_protection")),
+		BackupRetentionPeriod:   aws.Int32(int32(planInt(r, 1, "backup_retention_period"))),
+		DBClusterParameterGroup: aws.String(planParameterGroup(r, "db_cluster_parameter_group_name")),
+	}
+	if window := r.String("preferred_maintenance_window"); window != "" {
+		cluster.PreferredMaintenanceWindow = aws.String(window)
+	}
+
+	checkStorageEncryption(cluster, tbl, rules)
+	checkDeletionProtection(cluster, tbl, rules)
+	checkClusterBackupEnabled(cluster, tbl, rules)
+	checkClusterDefaultParameterGroup(cluster, tbl, rules)
+	checkClusterMaintenanceWindow(cluster, tbl, rules)
+}
+
+func checkPlanDBInstance(r *tfplan.Resource, tbl *table.Table, rules config.RulesConfig) {
+	pgAttr := "parameter_group_name"
+	windowAttr := "maintenance_window"
+	if r.Type == "aws_rds_cluster_instance" {
+		pgAttr = "db_parameter_group_name"
+		windowAttr = "preferred_maintenance_window"
+	}
+
+	instance := rdstypes.DBInstance{
+		DBInstanceIdentifier:       aws.String(r.Address),
+		AutoMinorVersionUpgrade:    aws.Bool(planBool(r, true, "auto_minor_version_upgrade")),
+		PubliclyAccessible:         aws.Bool(planBool(r, false, "publicly_accessible")),
+		PerformanceInsightsEnabled: aws.Bool(planBool(r, false, "performance_insights_enabled")),
+		DBParameterGroups: []rdstypes.DBParameterGroupStatus{
+			{DBParameterGroupName: aws.String(planParameterGroup(r, pgAttr))},
+		},
+	}
+	if window := r.String(windowAttr); window != "" {
+		instance.PreferredMaintenanceWindow = aws.String(window)
+	}
+
+	checkAutoMinorVersionUpgrade(instance, tbl, rules)
+	checkInstanceDefaultParameterGroup(instance, tbl, rules)
+	checkPublicAccessibility(instance, tbl, rules)
+	checkPerformanceInsights(instance, tbl, rules)
+	checkInstanceMaintenanceWindow(instance, tbl, rules)
+
+	if r.Type == "aws_db_instance" {
+		// A standalone instance has no cluster, so the cluster-level settings
+		// are checked on the instance itself.
+		instance.StorageEncrypted = aws.Bool(planBool(r, false, "storage_encrypted"))
+		instance.DeletionProtection = aws.Bool(planBool(r, false, "deletion_protection"))
+		instance.BackupRetentionPeriod = aws.Int32(int32(planInt(r, 1, "backup_retention_period")))
+		checkInstanceStorageEncryption(instance, tbl, rules)
+		checkInstanceDeletionProtection(instance, tbl, rules)
+		checkInstanceBackupEnabled(instance, tbl, rules)
+	}
+}
+
+// planParameterGroup returns the parameter group name, or the default group
+// RDS assigns when the attribute is unset.
+func planParameterGroup(r *tfplan.Resource, attr string) string {
+	if name := r.String(attr); name != "" {
+		return name
+	}
+	if r.IsSet(attr) {
+		return "(known after apply)"
+	}
+	return "default." + r.String("engine")
+}
+
+func checkPlanVPC(plan *tfplan.Plan, vpc *tfplan.Resource, tbl *table.Table, rules config.RulesConfig) {
+	ruleName := rules.Get("vpc-name-tag")
+	if name, ok := vpc.Tags()["Name"]; !ok {
+		table.AddResult(tbl, ruleName, "Fail", vpc.Address, "Missing")
+	} else {
+		table.AddResult(tbl, ruleName, "Pass", vpc.Address, name)
+	}
+
+	ruleDnsH := rules.Get("vpc-dns-hostname")
+	if !planBool(vpc, false, "enable_dns_hostnames") {
+		table.AddResult(tbl, ruleDnsH, "Fail", vpc.Address, "Disabled")
+	} else {
+		table.AddResult(tbl, ruleDnsH, "Pass", vpc.Address, "Enabled")
+	}
+
+	ruleDnsS := rules.Get("vpc-dns-support")
+	if !planBool(vpc, true, "enable_dns_support") {
+		table.AddResult(tbl, ruleDnsS, "Fail", vpc.Address, "Disabled")
+	} else {
+		table.AddResult(tbl, ruleDnsS, "Pass", vpc.Address, "Enabled")
+	}
+
+	flowLogs := plan.Linked(vpc, "aws_flow_log", "vpc_id", vpc.String("id"))
+	ruleFlow := rules.Get("vpc-flow-logs")
+	if len(flowLogs) == 0 {
+		table.AddResult(tbl, ruleFlow, "Fail", vpc.Address, "Disabled")
+		return
+	}
+	customFormat := false
+	for _, fl := range flowLogs {
+		if ec2Internal.IsCustomFlowLogFormat(fl.String("log_format")) {
+			customFormat = true
+		}
+	}
+	ruleFormat := rules.Get("vpc-flow-logs-custom-format")
+	if !customFormat {
+		table.AddResult(tbl, ruleFormat, "Fail", vpc.Address, "Invalid")
+	} else {
+		table.AddResult(tbl, ruleFormat, "Pass", vpc.Address, "Valid")
+	}
+	table.AddResult(tbl, ruleFlow, "Pass", vpc.Address, "Enabled")
+}
+
+func checkPlanLoadBalancer(r *tfplan.Resource, tbl *table.Table, rules config.RulesConfig) {
+	if lbType := r.String("load_balancer_type"); lbType != "" && lbType != "application" {
+		return
+	}
+
+	lb := elbtypes.LoadBalancer{LoadBalancerName: aws.String(r.Address)}
+	attrs := &elasticloadbalancingv2.DescribeLoadBalancerAttributesOutput{
+		Attributes: []elbtypes.LoadBalancerAttribute{
+			planAttribute("access_logs.s3.enabled", planBool(r, false, "access_logs", "enabled")),
+			planAttribute("connection_logs.s3.enabled", planBool(r, false, "connection_logs", "enabled")),
+			planAttribute("deletion_protection.enabled", planBool(r, false, "enable_deletion_protection")),
+		},
+	}
+
+	checkELBAccessLogs(lb, attrs, tbl, rules)
+	checkELBConnectionLogs(lb, attrs, tbl, rules)
+	checkELBDeletionProtection(lb, attrs, tbl, rules)
+}
+
+func planAttribute(key string, value bool) elbtypes.LoadBalancerAttribute {
+	v := "false"
+	if value {
+		v = "true"
+	}
+	return elbtypes.LoadBalancerAttribute{Key: aws.String(key), Value: aws.String(v)}
+}
+
+func checkPlanLogGroup(r *tfplan.Resource, tbl *table.Table, rules config.RulesConfig) {
+	logGroup := cwltypes.LogGroup{LogGroupName: aws.String(r.Address)}
+	// 0 means the log events never expire.
+	if days, ok := r.Int("retention_in_days"); ok && days > 0 {
+		logGroup.RetentionInDays = aws.Int32(int32(days))
+	}
+	if r.IsSet("kms_key_id") {
+		logGroup.KmsKeyId = aws.String(r.String("kms_key_id"))
+	}
+
+	checkLogGroupRetention(logGroup, tbl, rules)
+	checkLogGroupKmsEncryption(logGroup, tbl, rules)
+}
+
+func checkPlanECRRepository(plan *tfplan.Plan, r *tfplan.Resource, tbl *table.Table, rules config.RulesConfig) {
+	mutability := ecrtypes.ImageTagMutabilityMutable
+	if v := r.String("image_tag_mutability"); v != "" {
+		mutability = ecrtypes.ImageTagMutability(v)
+	}
+	repo := ecrtypes.Repository{
+		RepositoryName:     aws.String(r.Address),
+		ImageTagMutability: mutability,
+		ImageScanningConfiguration: &ecrtypes.ImageScanningConfiguration{
+			ScanOnPush: planBool(r, false, "image_scanning_configuration", "scan_on_push"),
+		},
+	}
+
+	checkTagImmutability(repo, tbl, rules)
+	checkImageScanningConfiguration(repo, tbl, rules)
+
+	rule := rules.Get("ecr-lifecycle-policy")
+	if len(plan.Linked(r, "aws_ecr_lifecycle_policy", "repository", r.String("name"))) == 0 {
+		table.AddResult(tbl, rule, "Fail", r.Address, "Missing")
+	} else {
+		table.AddResult(tbl, rule, "Pass", r.Address, "Set")
+	}
+}
+
+func checkPlanECSCluster(r *tfplan.Resource, tbl *table.Table, rules config.RulesConfig) {
+	cluster := ecstypes.Cluster{ClusterName: aws.String(r.Address)}
+	if settings, ok := r.Values["setting"].([]interface{}); ok {
+		for _, s := range settings {
+			if m, ok := s.(map[string]interface{}); ok {
+				name, _ := m["name"].(string)
+				value, _ := m["value"].(string)
+				cluster.Settings = append(cluster.Settings, ecstypes.ClusterSetting{
+					Name:  ecstypes.ClusterSettingName(name),
+					Value: aws.String(value),
+				})
+			}
+		}
+	}
+	if r.Get("configuration", "execute_command_configuration") != nil {
+		logging := ecstypes.ExecuteCommandLoggingDefault
+		if v := r.String("configuration", "execute_command_configuration", "logging"); v != "" {
+			logging = ecstypes.ExecuteCommandLogging(v)
+		}
+		cluster.Configuration = &ecstypes.ClusterConfiguration{
+			ExecuteCommandConfiguration: &ecstypes.ExecuteCommandConfiguration{Logging: logging},
+		}
+	}
+
+	checkContainerInsights(cluster, tbl, rules)
+	checkECSExecLogging(cluster, tbl, rules)
+}
+
+func checkPlanECSService(r *tfplan.Resource, tbl *table.Table, rules config.RulesConfig) {
+	propagateTags := ecstypes.PropagateTagsNone
+	if v := r.String("propagate_tags"); v != "" {
+		propagateTags = ecstypes.PropagateTags(v)
+	}
+	service := ecstypes.Service{
+		ServiceName:   aws.String(r.Address),
+		PropagateTags: propagateTags,
+		DeploymentConfiguration: &ecstypes.DeploymentConfiguration{
+			DeploymentCircuitBreaker: &ecstypes.DeploymentCircuitBreaker{
+				Enable: planBool(r, false, "deployment_circuit_breaker", "enable"),
+			},
+		},
+	}
+
+	checkCircuitBreaker(service, tbl, rules)
+	checkPropagateTags(service, tbl, rules)
+}
+
+func checkPlanTaskDefinition(r *tfplan.Resource, tbl *table.Table, rules config.RulesConfig) {
+	td := &ecstypes.TaskDefinition{}
+	if arch := r.String("runtime_platform", "cpu_architecture"); arch != "" {
+		td.RuntimePlatform = &ecstypes.RuntimePlatform{CpuArchitecture: ecstypes.CPUArchitecture(arch)}
+	}
+
+	var containers []struct {
+		Name        string `json:"name"`
+		Environment []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"environment"`
+	}
+	if defs := r.String("container_definitions"); defs != "" {
+		if err := json.Unmarshal([]byte(defs), &containers); err != nil {
+			log.Printf("Warning: Failed to parse container definitions of %s: %v", r.Address, err)
+		}
+	}
+	for _, c := range containers {
+		container := ecstypes.ContainerDefinition{Name: aws.String(c.Name)}
+		for _, env := range c.Environment {
+			container.Environment = append(container.Environment, ecstypes.KeyValuePair{
+				Name:  aws.String(env.Name),
+				Value: aws.String(env.Value),
+			})
+		}
+		td.ContainerDefinitions = append(td.ContainerDefinitions, container)
+	}
+
+	checkCpuArchitecture(td, aws.String(r.Address), tbl, rules)
+	checkSensitiveEnvironmentVariables(td, aws.String(r.Address), tbl, rules)
+}
+
+// planPublicAccessBlock reads an aws_s3_bucket_public_access_block or
+// aws_s3_account_public_access_block. The settings default to false.
+func planPublicAccessBlock(r *tfplan.Resource) s3Internal.PublicAccessBlock {
+	return s3Internal.PublicAccessBlock{
+		BlockPublicAcls:       planBool(r, false, "block_public_acls"),
+		IgnorePublicAcls:      planBool(r, false, "ignore_public_acls"),
+		BlockPublicPolicy:     planBool(r, false, "block_public_policy"),
+		RestrictPublicBuckets: planBool(r, false, "restrict_public_buckets"),
+	}
+}
+
+// planBool returns a boolean attribute, or def when it is not set.
+func planBool(r *tfplan.Resource, def bool, path ...string) bool {
+	if v, ok := r.Bool(path...); ok {
+		return v
+	}
+	return def
+}
+
+// planInt returns a number attribute, or def when it is not set.
+func planInt(r *tfplan.Resource, def int, path ...string) int {
+	if v, ok := r.Int(path...); ok {
+		return v
+	}
+	return def
+}
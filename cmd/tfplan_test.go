@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"awsselfrev/internal/table"
+	"awsselfrev/internal/tfplan"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckTerraformPlan(t *testing.T) {
+	plan, err := tfplan.Load("../internal/tfplan/testdata/plan.json")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	rules := s3TestRules()
+	for key, rule := range rdsTestRules().Rules {
+		rules.Rules[key] = rule
+	}
+
+	tbl := table.SetTable()
+	checkTerraformPlan(plan, tbl, rules)
+
+	results := make(map[string][]string)
+	for _, row := range tbl.Rows() {
+		results[row.Resource] = append(results[row.Resource], row.RuleKey+" "+row.Status+" "+row.Setting)
+	}
+	assert.Equal(t, map[string][]string{
+		// The encryption and ownership settings come from the linked
+		// aws_s3_bucket_* resources; there is no logging or lifecycle
+		// resource for the log bucket.
+		"aws_s3_bucket.logs": {
+			"s3-encryption Pass Enabled",
+			"s3-public-access Pass Enabled",
+			"s3-object-ownership Fail BucketOwnerPreferred",
+			"s3-lifecycle Fail Disabled",
+			"s3-object-lock Fail Disabled",
+			"s3-sse-kms-encryption Pass Enabled",
+			"s3-bucket-key Fail Disabled",
+			"s3-server-access-logging Pass Enabled",
+		},
+		// A standalone instance also gets the cluster-level checks;
+		// deletion_protection = false and storage_encrypted unset fail.
+		"module.db.aws_db_instance.main[0]": {
+			"rds-auto-minor-version-upgrade Fail Enabled",
+			"rds-default-parameter-group Fail default.mysql",
+			"rds-public-access Pass Private",
+			"rds-performance-insights Fail Disabled",
+			"rds-storage-encryption Fail Disabled",
+			"rds-deletion-protection Fail Disabled",
+			"rds-backup-enabled Pass 1 days",
+		},
+		// A cluster member leaves those to its aws_rds_cluster.
+		"aws_rds_cluster_instance.aurora[0]": {
+			"rds-auto-minor-version-upgrade Fail Enabled",
+			"rds-default-parameter-group Fail default.aurora-mysql",
+			"rds-public-access Fail Public",
+			"rds-performance-insights Fail Disabled",
+		},
+	}, results)
+}
+
+func TestCheckTerraformPlanS3BucketDefaults(t *testing.T) {
+	plan, err := tfplan.Parse([]byte(`{"resource_changes": [{
+		"address": "aws_s3_bucket.assets", "mode": "managed", "type": "aws_s3_bucket", "name": "assets",
+		"change": {"actions": ["create"], "after": {"bucket": "example-assets"}}
+	}]}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tbl := table.SetTable()
+	checkTerraformPlan(plan, tbl, s3TestRules())
+
+	var results []string
+	for _, row := range tbl.Rows() {
+		switch row.RuleKey {
+		case "s3-encryption", "s3-public-access", "s3-object-ownership":
+			results = append(results, row.RuleKey+" "+row.Status+" "+row.Setting)
+		}
+	}
+	// Without aws_s3_bucket_* resources, the bucket has the defaults of new
+	// buckets.
+	assert.Equal(t, []string{
+		"s3-encryption Pass Enabled",
+		"s3-public-access Pass Enabled",
+		"s3-object-ownership Pass BucketOwnerEnforced",
+	}, results)
+}
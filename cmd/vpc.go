@@ -2,16 +2,15 @@ package cmd
 
 import (
 	"context"
-	"log"
 
 	"awsselfrev/internal/aws/api"
 	ec2Internal "awsselfrev/internal/aws/service/ec2"
 	"awsselfrev/internal/color"
 	"awsselfrev/internal/config"
+	"awsselfrev/internal/inventory"
 	"awsselfrev/internal/table"
 
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
-	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 )
 
@@ -31,14 +30,17 @@ as well as the status of DNS hostnames and DNS support. It also checks if VPC Fl
 
 		checkVPCConfigurations(client, tbl, rules)
 
-		table.Render("VPC", tbl)
+		renderResults("VPC", tbl, rules)
 	},
 }
 
-func checkVPCConfigurations(client api.EC2Client, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkVPCConfigurations(client api.EC2Client, tbl *table.Table, rules config.RulesConfig) {
+	if !needsService(rules, "VPC") {
+		return
+	}
 	resp, err := client.DescribeVpcs(context.TODO(), &ec2.DescribeVpcsInput{})
 	if err != nil {
-		log.Fatalf("Failed to describe VPCs: %v", err)
+		fatalf("Failed to describe VPCs: %v", err)
 	}
 
 	if len(resp.Vpcs) == 0 {
@@ -48,6 +50,9 @@ func checkVPCConfigurations(client api.EC2Client, tbl *tablewriter.Table, rules
 
 	for _, vpc := range resp.Vpcs {
 		vpcID := *vpc.VpcId
+		if !config.Resources.Match(vpcID, "", tagsOf(vpc.Tags, ec2Tag)) {
+			continue
+		}
 		name := "Missing"
 		for _, tag := range vpc.Tags {
 			if *tag.Key == "Name" {
@@ -59,54 +64,62 @@ func checkVPCConfigurations(client api.EC2Client, tbl *tablewriter.Table, rules
 		// 1. Name Tag
 		ruleName := rules.Get("vpc-name-tag")
 		if name == "Missing" {
-			table.AddRow(tbl, []string{ruleName.Service, "Fail", color.ColorizeLevel(ruleName.Level), vpcID, name, ruleName.Issue})
+			table.AddResult(tbl, ruleName, "Fail", vpcID, name)
 		} else {
-			table.AddRow(tbl, []string{ruleName.Service, "Pass", "-", vpcID, name, ruleName.Issue})
+			table.AddResult(tbl, ruleName, "Pass", vpcID, name)
 		}
 
 		// 2. DNS Hostname
-		dnsHostnameEnabled, err := ec2Internal.IsDnsHostnamesEnabled(client, vpcID)
-		if err != nil {
-			log.Fatalf("Failed to check DNS hostname for VPC %s: %v", vpcID, err)
-		}
-		ruleDnsH := rules.Get("vpc-dns-hostname")
-		if !dnsHostnameEnabled {
-			table.AddRow(tbl, []string{ruleDnsH.Service, "Fail", color.ColorizeLevel(ruleDnsH.Level), vpcID, "Disabled", ruleDnsH.Issue})
-		} else {
-			table.AddRow(tbl, []string{ruleDnsH.Service, "Pass", "-", vpcID, "Enabled", ruleDnsH.Issue})
+		if needs(rules, "vpc-dns-hostname") {
+			dnsHostnameEnabled, err := ec2Internal.IsDnsHostnamesEnabled(client, vpcID)
+			if err != nil {
+				fatalf("Failed to check DNS hostname for VPC %s: %v", vpcID, err)
+			}
+			ruleDnsH := rules.Get("vpc-dns-hostname")
+			if !dnsHostnameEnabled {
+				table.AddResult(tbl, ruleDnsH, "Fail", vpcID, "Disabled")
+			} else {
+				table.AddResult(tbl, ruleDnsH, "Pass", vpcID, "Enabled")
+			}
 		}
 
 		// 3. DNS Support
-		dnsSupportEnabled, err := ec2Internal.IsDnsSupportEnabled(client, vpcID)
-		if err != nil {
-			log.Fatalf("Failed to check DNS support for VPC %s: %v", vpcID, err)
-		}
-		ruleDnsS := rules.Get("vpc-dns-support")
-		if !dnsSupportEnabled {
-			table.AddRow(tbl, []string{ruleDnsS.Service, "Fail", color.ColorizeLevel(ruleDnsS.Level), vpcID, "Disabled", ruleDnsS.Issue})
-		} else {
-			table.AddRow(tbl, []string{ruleDnsS.Service, "Pass", "-", vpcID, "Enabled", ruleDnsS.Issue})
+		if needs(rules, "vpc-dns-support") {
+			dnsSupportEnabled, err := ec2Internal.IsDnsSupportEnabled(client, vpcID)
+			if err != nil {
+				fatalf("Failed to check DNS support for VPC %s: %v", vpcID, err)
+			}
+			ruleDnsS := rules.Get("vpc-dns-support")
+			if !dnsSupportEnabled {
+				table.AddResult(tbl, ruleDnsS, "Fail", vpcID, "Disabled")
+			} else {
+				table.AddResult(tbl, ruleDnsS, "Pass", vpcID, "Enabled")
+			}
 		}
 
 		// 4. Flow Logs
-		flowLogsEnabled, err := ec2Internal.IsVpcFlowLogsEnabled(client, vpcID)
-		if err != nil {
-			log.Fatalf("Failed to check Flow Logs for VPC %s: %v", vpcID, err)
-		}
-		ruleFlow := rules.Get("vpc-flow-logs")
-		if !flowLogsEnabled {
-			table.AddRow(tbl, []string{ruleFlow.Service, "Fail", color.ColorizeLevel(ruleFlow.Level), vpcID, "Disabled", ruleFlow.Issue})
-		} else {
-			// Flow logs enabled, check custom format
-			ruleFormat := rules.Get("vpc-flow-logs-custom-format")
-			if !ec2Internal.HasCustomFlowLogFormat(client, vpcID) { // Using new internal function
-				table.AddRow(tbl, []string{ruleFormat.Service, "Fail", color.ColorizeLevel(ruleFormat.Level), vpcID, "Invalid", ruleFormat.Issue})
+		if needs(rules, "vpc-flow-logs", "vpc-flow-logs-custom-format") {
+			flowLogsEnabled, err := ec2Internal.IsVpcFlowLogsEnabled(client, vpcID)
+			if err != nil {
+				fatalf("Failed to check Flow Logs for VPC %s: %v", vpcID, err)
+			}
+			ruleFlow := rules.Get("vpc-flow-logs")
+			if !flowLogsEnabled {
+				table.AddResult(tbl, ruleFlow, "Fail", vpcID, "Disabled")
 			} else {
-				table.AddRow(tbl, []string{ruleFormat.Service, "Pass", "-", vpcID, "Valid", ruleFormat.Issue})
+				// Flow logs enabled, check custom format
+				ruleFormat := rules.Get("vpc-flow-logs-custom-format")
+				if !ec2Internal.HasCustomFlowLogFormat(client, vpcID) { // Using new internal function
+					table.AddResult(tbl, ruleFormat, "Fail", vpcID, "Invalid")
+				} else {
+					table.AddResult(tbl, ruleFormat, "Pass", vpcID, "Valid")
+				}
+				// Also report flow logs enabled as Pass
+				table.AddResult(tbl, ruleFlow, "Pass", vpcID, "Enabled")
 			}
-			// Also report flow logs enabled as Pass
-			table.AddRow(tbl, []string{ruleFlow.Service, "Pass", "-", vpcID, "Enabled", ruleFlow.Issue})
 		}
+
+		collectResource(tbl, rules, inventory.TypeVPC, vpcID, "", vpc)
 	}
 }
 
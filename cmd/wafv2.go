@@ -3,14 +3,13 @@ package cmd
 import (
 	"awsselfrev/internal/aws/api"
 	wafv2Internal "awsselfrev/internal/aws/service/wafv2"
-	"awsselfrev/internal/color"
 	"awsselfrev/internal/config"
+	"awsselfrev/internal/inventory"
 	"awsselfrev/internal/table"
 	"fmt"
 
 	"github.com/aws/aws-sdk-go-v2/service/wafv2"
 	"github.com/aws/aws-sdk-go-v2/service/wafv2/types"
-	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 )
 
@@ -32,11 +31,14 @@ var wafv2Cmd = &cobra.Command{
 
 		checkWAFV2Configurations(client, cfClient, tbl, rules)
 
-		table.Render("WAF v2", tbl)
+		renderResults("WAF v2", tbl, rules)
 	},
 }
 
-func checkWAFV2Configurations(client api.WAFV2Client, cfClient api.WAFV2Client, tbl *tablewriter.Table, rules config.RulesConfig) {
+func checkWAFV2Configurations(client api.WAFV2Client, cfClient api.WAFV2Client, tbl *table.Table, rules config.RulesConfig) {
+	if !needsService(rules, "WAFV2") {
+		return
+	}
 	regionalACLs := wafv2Internal.ListWebACLs(client, types.ScopeRegional)
 	cfACLs := wafv2Internal.ListWebACLs(cfClient, types.ScopeCloudfront)
 
@@ -46,20 +48,36 @@ func checkWAFV2Configurations(client api.WAFV2Client, cfClient api.WAFV2Client,
 	}
 
 	for _, acl := range regionalACLs {
-		checkWebACLLogging(client, acl, tbl, rules, "Regional")
+		if webACLSelected(client, acl) {
+			checkWebACLLogging(client, acl, tbl, rules, "Regional")
+			collectResource(tbl, rules, inventory.TypeWebACL, acl.Name, acl.ARN, webACLDocument(acl, types.ScopeRegional))
+		}
 	}
 	for _, acl := range cfACLs {
-		checkWebACLLogging(cfClient, acl, tbl, rules, "CloudFront")
+		if webACLSelected(cfClient, acl) {
+			checkWebACLLogging(cfClient, acl, tbl, rules, "CloudFront")
+			collectResource(tbl, rules, inventory.TypeWebACL, acl.Name, acl.ARN, webACLDocument(acl, types.ScopeCloudfront))
+		}
 	}
 }
 
-func checkWebACLLogging(client api.WAFV2Client, acl wafv2Internal.WebACLInfo, tbl *tablewriter.Table, rules config.RulesConfig, scope string) {
+func webACLSelected(client api.WAFV2Client, acl wafv2Internal.WebACLInfo) bool {
+	return config.Resources.Match(acl.Name, acl.ARN, func() map[string]string {
+		return wafv2Internal.GetWebACLTags(client, acl.ARN)
+	})
+}
+
+func webACLDocument(acl wafv2Internal.WebACLInfo, scope types.Scope) map[string]string {
+	return map[string]string{"Name": acl.Name, "ARN": acl.ARN, "Scope": string(scope)}
+}
+
+func checkWebACLLogging(client api.WAFV2Client, acl wafv2Internal.WebACLInfo, tbl *table.Table, rules config.RulesConfig, scope string) {
 	rule := rules.Get("wafv2-logging-enabled")
 	resourceName := fmt.Sprintf("%s (%s)", acl.Name, scope)
 	if !wafv2Internal.IsWAFV2LoggingEnabled(client, acl.ARN) {
-		table.AddRow(tbl, []string{rule.Service, "Fail", color.ColorizeLevel(rule.Level), resourceName, "Disabled", rule.Issue})
+		table.AddResult(tbl, rule, "Fail", resourceName, "Disabled")
 	} else {
-		table.AddRow(tbl, []string{rule.Service, "Pass", "-", resourceName, "Enabled", rule.Issue})
+		table.AddResult(tbl, rule, "Pass", resourceName, "Enabled")
 	}
 }
 
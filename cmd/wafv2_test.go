@@ -30,6 +30,14 @@ func (m *MockWAFV2Client) GetLoggingConfiguration(ctx context.Context, params *w
 	return args.Get(0).(*wafv2.GetLoggingConfigurationOutput), args.Error(1)
 }
 
+func (m *MockWAFV2Client) ListTagsForResource(ctx context.Context, params *wafv2.ListTagsForResourceInput, optFns ...func(*wafv2.Options)) (*wafv2.ListTagsForResourceOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*wafv2.ListTagsForResourceOutput), args.Error(1)
+}
+
 func TestCheckWAFV2Configurations(t *testing.T) {
 	regClient := new(MockWAFV2Client)
 	cfClient := new(MockWAFV2Client)
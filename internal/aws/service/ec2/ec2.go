@@ -110,15 +110,18 @@ func HasCustomFlowLogFormat(client api.EC2Client, vpcID string) bool {
 	}
 
 	for _, fl := range resp.FlowLogs {
-		if fl.LogFormat != nil {
-			f := *fl.LogFormat
-			if strings.Contains(f, "tcp-flags") &&
-				strings.Contains(f, "pkt-srcaddr") &&
-				strings.Contains(f, "pkt-dstaddr") &&
-				strings.Contains(f, "flow-direction") {
-				return true
-			}
+		if fl.LogFormat != nil && IsCustomFlowLogFormat(*fl.LogFormat) {
+			return true
 		}
 	}
 	return false
 }
+
+// IsCustomFlowLogFormat reports whether a flow log format includes the
+// fields needed for troubleshooting beyond the default format.
+func IsCustomFlowLogFormat(format string) bool {
+	return strings.Contains(format, "tcp-flags") &&
+		strings.Contains(format, "pkt-srcaddr") &&
+		strings.Contains(format, "pkt-dstaddr") &&
+		strings.Contains(format, "flow-direction")
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"awsselfrev/internal/aws/api"
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// Key is what the checks need to know about a KMS key.
+type Key struct {
+	ARN string
+	// State is the key state, e.g. "Enabled" or "PendingDeletion", or "" when
+	// the key does not exist.
+	State           string
+	CustomerManaged bool
+	// RotationEnabled is set for customer managed keys with automatic rotation
+	// and for AWS managed keys, which AWS rotates every year.
+	RotationEnabled bool
+}
+
+// Exists reports whether the key was found.
+func (k Key) Exists() bool {
+	return k.State != ""
+}
+
+// Keys looks up KMS keys with a client of the key's region and remembers
+// them: buckets often share a key.
+type Keys struct {
+	newClient func(region string) api.KMSClient
+	clients   map[string]api.KMSClient
+	keys      map[string]describedKey
+}
+
+type describedKey struct {
+	key Key
+	ok  bool
+}
+
+// NewKeys returns Keys that create clients with newClient.
+func NewKeys(newClient func(region string) api.KMSClient) *Keys {
+	return &Keys{
+		newClient: newClient,
+		clients:   make(map[string]api.KMSClient),
+		keys:      make(map[string]describedKey),
+	}
+}
+
+// Describe returns the key given by ID, ARN or alias. Key IDs and alias names
+// are looked up in region; an ARN names its own region. ok is false when the
+// key cannot be read (e.g. the key policy does not allow it).
+func (k *Keys) Describe(region, keyID string) (key Key, ok bool) {
+	if r := arnRegion(keyID); r != "" {
+		region = r
+	}
+	cacheKey := region + "/" + keyID
+	if d, found := k.keys[cacheKey]; found {
+		return d.key, d.ok
+	}
+	client, found := k.clients[region]
+	if !found {
+		client = k.newClient(region)
+		k.clients[region] = client
+	}
+	key, ok = DescribeKey(client, keyID)
+	k.keys[cacheKey] = describedKey{key, ok}
+	return key, ok
+}
+
+// arnRegion returns the region of an ARN, or "" for a key ID or alias name.
+func arnRegion(id string) string {
+	parts := strings.Split(id, ":")
+	if len(parts) < 6 || parts[0] != "arn" {
+		return ""
+	}
+	return parts[3]
+}
+
+// DescribeKey returns the key given by ID, ARN or alias. ok is false when the
+// key cannot be read.
+func DescribeKey(client api.KMSClient, keyID string) (key Key, ok bool) {
+	resp, err := client.DescribeKey(context.TODO(), &kms.DescribeKeyInput{
+		KeyId: aws.String(keyID),
+	})
+	if err != nil {
+		var notFound *types.NotFoundException
+		if errors.As(err, &notFound) {
+			return Key{}, true
+		}
+		log.Printf("Warning: Failed to describe KMS key %s: %v", keyID, err)
+		return Key{}, false
+	}
+	m := resp.KeyMetadata
+	key = Key{
+		ARN:             aws.ToString(m.Arn),
+		State:           string(m.KeyState),
+		CustomerManaged: m.KeyManager == types.KeyManagerTypeCustomer,
+	}
+	if !key.CustomerManaged {
+		key.RotationEnabled = true
+		return key, true
+	}
+	// Automatic rotation is only available for symmetric keys with key
+	// material generated by KMS, and cannot be read for keys pending deletion.
+	if m.KeySpec != types.KeySpecSymmetricDefault || m.Origin != types.OriginTypeAwsKms {
+		return key, true
+	}
+	if m.KeyState != types.KeyStateEnabled && m.KeyState != types.KeyStateDisabled {
+		return key, true
+	}
+	rotation, err := client.GetKeyRotationStatus(context.TODO(), &kms.GetKeyRotationStatusInput{
+		KeyId: m.Arn,
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to get the rotation status of KMS key %s: %v", key.ARN, err)
+		return key, true
+	}
+	key.RotationEnabled = rotation.KeyRotationEnabled
+	return key, true
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"awsselfrev/internal/aws/api"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	allUsersURI           = "http://acs.amazonaws.com/groups/global/AllUsers"
+	authenticatedUsersURI = "http://acs.amazonaws.com/groups/global/AuthenticatedUsers"
+)
+
+// ACL is a bucket ACL.
+type ACL struct {
+	OwnerID string
+	Grants  []types.Grant
+}
+
+// GetBucketACL returns the bucket ACL.
+func GetBucketACL(client api.S3Client, bucket string) ACL {
+	resp, err := client.GetBucketAcl(context.TODO(), &s3.GetBucketAclInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		handleS3Error(err)
+		return ACL{}
+	}
+	acl := ACL{Grants: resp.Grants}
+	if resp.Owner != nil {
+		acl.OwnerID = aws.ToString(resp.Owner.ID)
+	}
+	return acl
+}
+
+// PublicGrants returns the grants to everyone (AllUsers) or to any AWS
+// account (AuthenticatedUsers), as "Group: PERMISSION".
+func (a ACL) PublicGrants() []string {
+	var grants []string
+	for _, g := range a.Grants {
+		if g.Grantee == nil || g.Grantee.Type != types.TypeGroup {
+			continue
+		}
+		switch aws.ToString(g.Grantee.URI) {
+		case allUsersURI, authenticatedUsersURI:
+			grants = append(grants, granteeName(g.Grantee)+": "+string(g.Permission))
+		}
+	}
+	return grants
+}
+
+// ExtraGrants returns the grants other than the bucket owner's full control,
+// which is the only grant of a new bucket, as "Grantee: PERMISSION".
+func (a ACL) ExtraGrants() []string {
+	var grants []string
+	for _, g := range a.Grants {
+		if g.Grantee == nil {
+			continue
+		}
+		if g.Grantee.Type == types.TypeCanonicalUser && aws.ToString(g.Grantee.ID) == a.OwnerID && g.Permission == types.PermissionFullControl {
+			continue
+		}
+		grants = append(grants, granteeName(g.Grantee)+": "+string(g.Permission))
+	}
+	return grants
+}
+
+// granteeName names a grantee by its group (e.g. "LogDelivery"), display
+// name, email address or the start of its canonical user ID.
+func granteeName(g *types.Grantee) string {
+	switch {
+	case g.Type == types.TypeGroup:
+		uri := aws.ToString(g.URI)
+		return uri[strings.LastIndex(uri, "/")+1:]
+	case aws.ToString(g.DisplayName) != "":
+		return aws.ToString(g.DisplayName)
+	case aws.ToString(g.EmailAddress) != "":
+		return aws.ToString(g.EmailAddress)
+	}
+	id := aws.ToString(g.ID)
+	if len(id) > 12 {
+		id = id[:12] + "..."
+	}
+	return fmt.Sprintf("id=%s", id)
+}
+
+// DefaultObjectOwnership is the Object Ownership of buckets without ownership
+// controls, which were created before ACLs were disabled by default.
+const DefaultObjectOwnership = string(types.ObjectOwnershipObjectWriter)
+
+// GetObjectOwnership returns the Object Ownership setting of the bucket, e.g.
+// "BucketOwnerEnforced".
+func GetObjectOwnership(client api.S3Client, bucket string) string {
+	resp, err := client.GetBucketOwnershipControls(context.TODO(), &s3.GetBucketOwnershipControlsInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		handleS3Error(err)
+		return DefaultObjectOwnership
+	}
+	if resp.OwnershipControls == nil || len(resp.OwnershipControls.Rules) == 0 {
+		return DefaultObjectOwnership
+	}
+	return string(resp.OwnershipControls.Rules[0].ObjectOwnership)
+}
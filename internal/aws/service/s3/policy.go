@@ -0,0 +1,280 @@
+package service
+
+import (
+	"awsselfrev/internal/aws/api"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Policy is a parsed bucket policy.
+type Policy struct {
+	Statements []Statement
+}
+
+// Statement is one statement of a bucket policy. Principal "*" is stored as
+// the AWS principal "*".
+type Statement struct {
+	Sid        string
+	Effect     string
+	Principals map[string][]string
+	Actions    []string
+	Conditions map[string]map[string][]string
+}
+
+// stringList accepts a JSON string or list. Booleans and numbers, which
+// appear in conditions, are kept as text.
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	items, ok := raw.([]interface{})
+	if !ok {
+		items = []interface{}{raw}
+	}
+	for _, item := range items {
+		*l = append(*l, fmt.Sprint(item))
+	}
+	return nil
+}
+
+type statementJSON struct {
+	Sid       string                           `json:"Sid"`
+	Effect    string                           `json:"Effect"`
+	Principal json.RawMessage                  `json:"Principal"`
+	Action    stringList                       `json:"Action"`
+	Condition map[string]map[string]stringList `json:"Condition"`
+}
+
+// ParsePolicy parses a bucket policy document.
+func ParsePolicy(document string) (*Policy, error) {
+	var raw struct {
+		Statement json.RawMessage `json:"Statement"`
+	}
+	if err := json.Unmarshal([]byte(document), &raw); err != nil {
+		return nil, err
+	}
+	var statements []statementJSON
+	if err := json.Unmarshal(raw.Statement, &statements); err != nil {
+		// A policy with a single statement may give it as an object.
+		var single statementJSON
+		if err := json.Unmarshal(raw.Statement, &single); err != nil {
+			return nil, err
+		}
+		statements = []statementJSON{single}
+	}
+
+	p := &Policy{}
+	for _, s := range statements {
+		st := Statement{
+			Sid:        s.Sid,
+			Effect:     s.Effect,
+			Principals: make(map[string][]string),
+			Actions:    s.Action,
+			Conditions: make(map[string]map[string][]string),
+		}
+		var wildcard string
+		if err := json.Unmarshal(s.Principal, &wildcard); err == nil {
+			st.Principals["AWS"] = []string{wildcard}
+		} else {
+			var principals map[string]stringList
+			if err := json.Unmarshal(s.Principal, &principals); err == nil {
+				for typ, values := range principals {
+					st.Principals[typ] = values
+				}
+			}
+		}
+		for op, keys := range s.Condition {
+			st.Conditions[op] = make(map[string][]string)
+			for key, values := range keys {
+				st.Conditions[op][strings.ToLower(key)] = values
+			}
+		}
+		p.Statements = append(p.Statements, st)
+	}
+	return p, nil
+}
+
+// GetBucketPolicy returns the bucket policy, or nil when the bucket has none.
+// ok is false when the policy cannot be parsed.
+func GetBucketPolicy(client api.S3Client, bucket string) (policy *Policy, ok bool) {
+	resp, err := client.GetBucketPolicy(context.TODO(), &s3.GetBucketPolicyInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		handleS3Error(err)
+		return nil, true
+	}
+	p, err := ParsePolicy(aws.ToString(resp.Policy))
+	if err != nil {
+		log.Printf("Warning: Failed to parse the policy of bucket %s: %v", bucket, err)
+		return nil, false
+	}
+	return p, true
+}
+
+// restrictiveConditionKeys limit who can use a statement, so a "*" principal
+// with one of them is not public.
+var restrictiveConditionKeys = []string{
+	"aws:principalaccount",
+	"aws:principalarn",
+	"aws:principalorgid",
+	"aws:principalorgpaths",
+	"aws:sourceaccount",
+	"aws:sourcearn",
+	"aws:sourceip",
+	"aws:sourceorgid",
+	"aws:sourcevpc",
+	"aws:sourcevpce",
+	"aws:userid",
+}
+
+// PublicStatements returns the Allow statements granted to everyone ("*")
+// without a condition that restricts the caller, named by Sid or by their
+// position in the policy.
+func (p *Policy) PublicStatements() []string {
+	var public []string
+	for i, s := range p.Statements {
+		if s.Effect != "Allow" || !s.hasPrincipal("*") || s.isRestricted() {
+			continue
+		}
+		public = append(public, s.name(i))
+	}
+	return public
+}
+
+// WildcardActionStatements returns the Allow statements that grant every S3
+// action ("*" or "s3:*"), named like PublicStatements.
+func (p *Policy) WildcardActionStatements() []string {
+	var wildcard []string
+	for i, s := range p.Statements {
+		if s.Effect != "Allow" {
+			continue
+		}
+		for _, action := range s.Actions {
+			if action == "*" || strings.EqualFold(action, "s3:*") {
+				wildcard = append(wildcard, s.name(i))
+				break
+			}
+		}
+	}
+	return wildcard
+}
+
+// name returns the Sid of the statement at index i, or its position.
+func (s Statement) name(i int) string {
+	if s.Sid != "" {
+		return s.Sid
+	}
+	return fmt.Sprintf("#%d", i+1)
+}
+
+func (s Statement) hasPrincipal(value string) bool {
+	for _, v := range s.Principals["AWS"] {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// restrictiveOperators only match requests whose key has one of the given
+// values. Negated operators (StringNotEquals, ...), IfExists variants and
+// Null also match requests without the key or from anyone else, and a
+// ForAnyValue: or ForAllValues: prefix changes how multivalued keys match,
+// so none of them restrict the caller.
+var restrictiveOperators = map[string]bool{
+	"StringEquals": true,
+	"StringLike":   true,
+	"ArnEquals":    true,
+	"ArnLike":      true,
+	"IpAddress":    true,
+}
+
+// isRestricted reports whether a condition limits the statement to callers
+// matching one of restrictiveConditionKeys.
+func (s Statement) isRestricted() bool {
+	for op, keys := range s.Conditions {
+		if !restrictiveOperators[op] {
+			continue
+		}
+		for key, values := range keys {
+			for _, restrictive := range restrictiveConditionKeys {
+				if key == restrictive && !anyWildcard(values) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// anyWildcard reports whether one of the condition values matches every
+// caller, e.g. "*" or 0.0.0.0/0. Values are ORed, so one is enough.
+func anyWildcard(values []string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if strings.Trim(v, "*?") == "" || v == "0.0.0.0/0" || v == "::/0" {
+			return true
+		}
+	}
+	return false
+}
+
+var accountIDPattern = regexp.MustCompile(`^(?:arn:aws[a-z-]*:iam::)?(\d{12})(?::|$)`)
+
+// Accounts returns the sorted IDs of the AWS accounts granted access by Allow
+// statements, from principals given as account IDs or IAM ARNs.
+func (p *Policy) Accounts() []string {
+	seen := make(map[string]bool)
+	var accounts []string
+	for _, s := range p.Statements {
+		if s.Effect != "Allow" {
+			continue
+		}
+		for _, principal := range s.Principals["AWS"] {
+			m := accountIDPattern.FindStringSubmatch(principal)
+			if m == nil || seen[m[1]] {
+				continue
+			}
+			seen[m[1]] = true
+			accounts = append(accounts, m[1])
+		}
+	}
+	sort.Strings(accounts)
+	return accounts
+}
+
+// DeniesInsecureTransport reports whether a Deny statement applies to
+// requests made without TLS, i.e. has an aws:SecureTransport "false"
+// condition.
+func (p *Policy) DeniesInsecureTransport() bool {
+	for _, s := range p.Statements {
+		if s.Effect != "Deny" {
+			continue
+		}
+		for op, keys := range s.Conditions {
+			if op != "Bool" && op != "BoolIfExists" {
+				continue
+			}
+			for _, v := range keys["aws:securetransport"] {
+				if strings.EqualFold(v, "false") {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package service
+
+import "awsselfrev/internal/aws/api"
+
+// RegionalClients creates an S3 client per region on first use. Requests for
+// a bucket must be sent to the bucket's region: other regions answer with a
+// 301 redirect.
+type RegionalClients struct {
+	newClient func(region string) api.S3Client
+	clients   map[string]api.S3Client
+}
+
+// NewRegionalClients returns RegionalClients that create clients with
+// newClient. newClient("") returns a client of the configured region.
+func NewRegionalClients(newClient func(region string) api.S3Client) *RegionalClients {
+	return &RegionalClients{newClient: newClient, clients: make(map[string]api.S3Client)}
+}
+
+// For returns the client of the region, or of the configured region for "".
+func (c *RegionalClients) For(region string) api.S3Client {
+	client, ok := c.clients[region]
+	if !ok {
+		client = c.newClient(region)
+		c.clients[region] = client
+	}
+	return client
+}
+
+// Location returns the client used to look up bucket regions in the
+// partition (see PartitionRegion).
+func (c *RegionalClients) Location(partition string) api.S3Client {
+	return c.For(PartitionRegion(partition))
+}
+
+// PartitionRegion returns the region whose S3 endpoint answers for buckets in
+// every region of the partition, or "" (the configured region) for a
+// partition without one. It is also the home of the default Storage Lens
+// dashboard.
+func PartitionRegion(partition string) string {
+	switch partition {
+	case "aws":
+		return "us-east-1"
+	case "aws-cn":
+		return "cn-north-1"
+	case "aws-us-gov":
+		return "us-gov-west-1"
+	}
+	return ""
+}
+
+// ControlClients creates an S3 Control client per region on first use, like
+// RegionalClients. Access points are listed in the region of their bucket.
+type ControlClients struct {
+	newClient func(region string) api.S3ControlClient
+	clients   map[string]api.S3ControlClient
+}
+
+// NewControlClients returns ControlClients that create clients with
+// newClient. newClient("") returns a client of the configured region.
+func NewControlClients(newClient func(region string) api.S3ControlClient) *ControlClients {
+	return &ControlClients{newClient: newClient, clients: make(map[string]api.S3ControlClient)}
+}
+
+// For returns the client of the region, or of the configured region for "".
+func (c *ControlClients) For(region string) api.S3ControlClient {
+	client, ok := c.clients[region]
+	if !ok {
+		client = c.newClient(region)
+		c.clients[region] = client
+	}
+	return client
+}
+
+// MultiRegion returns the client for Multi-Region Access Points, which are
+// managed through us-west-2. ok is false for a partition without them.
+func (c *ControlClients) MultiRegion(partition string) (client api.S3ControlClient, ok bool) {
+	if partition != "aws" {
+		return nil, false
+	}
+	return c.For("us-west-2"), true
+}
@@ -2,21 +2,27 @@ package service
 
 import (
 	"awsselfrev/internal/aws/api"
+	"awsselfrev/internal/config"
 	"context"
 	"errors"
 	"log"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
+// Fatalf stops the checks on an unexpected error. serve replaces it so that a
+// failed scan does not stop the server.
+var Fatalf = log.Fatalf
+
 func ListBuckets(client api.S3Client) []string {
 	var buckets []string
 	resp, err := client.ListBuckets(context.TODO(), &s3.ListBucketsInput{})
 	if err != nil {
-		log.Fatal(err)
+		Fatalf("Failed to list buckets: %v", err)
 	}
 	for _, bucket := range resp.Buckets {
 		buckets = append(buckets, *bucket.Name)
@@ -31,81 +37,340 @@ func IsBucketEncrypted(client api.S3Client, bucket string) bool {
 	return handleS3Error(err)
 }
 
-func IsBlockPublicAccessEnabled(client api.S3Client, bucket string) bool {
-	_, err := client.GetPublicAccessBlock(context.TODO(), &s3.GetPublicAccessBlockInput{
+// PublicAccessBlock holds the four Block Public Access settings of a bucket
+// or an account.
+type PublicAccessBlock struct {
+	BlockPublicAcls       bool
+	IgnorePublicAcls      bool
+	BlockPublicPolicy     bool
+	RestrictPublicBuckets bool
+}
+
+// AllPublicAccessBlocked has every setting on.
+var AllPublicAccessBlocked = PublicAccessBlock{true, true, true, true}
+
+// Merge returns the effective settings of a bucket combined with those of its
+// account: a setting is on when either turns it on.
+func (b PublicAccessBlock) Merge(account PublicAccessBlock) PublicAccessBlock {
+	return PublicAccessBlock{
+		BlockPublicAcls:       b.BlockPublicAcls || account.BlockPublicAcls,
+		IgnorePublicAcls:      b.IgnorePublicAcls || account.IgnorePublicAcls,
+		BlockPublicPolicy:     b.BlockPublicPolicy || account.BlockPublicPolicy,
+		RestrictPublicBuckets: b.RestrictPublicBuckets || account.RestrictPublicBuckets,
+	}
+}
+
+// Off returns the names of the settings that are off.
+func (b PublicAccessBlock) Off() []string {
+	var off []string
+	for _, s := range []struct {
+		name string
+		on   bool
+	}{
+		{"BlockPublicAcls", b.BlockPublicAcls},
+		{"IgnorePublicAcls", b.IgnorePublicAcls},
+		{"BlockPublicPolicy", b.BlockPublicPolicy},
+		{"RestrictPublicBuckets", b.RestrictPublicBuckets},
+	} {
+		if !s.on {
+			off = append(off, s.name)
+		}
+	}
+	return off
+}
+
+// GetBucketPublicAccessBlock returns the bucket's Block Public Access
+// settings. A bucket without a configuration has every setting off.
+func GetBucketPublicAccessBlock(client api.S3Client, bucket string) PublicAccessBlock {
+	resp, err := client.GetPublicAccessBlock(context.TODO(), &s3.GetPublicAccessBlockInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		handleS3Error(err)
+		return PublicAccessBlock{}
+	}
+	c := resp.PublicAccessBlockConfiguration
+	if c == nil {
+		return PublicAccessBlock{}
+	}
+	return PublicAccessBlock{
+		BlockPublicAcls:       aws.ToBool(c.BlockPublicAcls),
+		IgnorePublicAcls:      aws.ToBool(c.IgnorePublicAcls),
+		BlockPublicPolicy:     aws.ToBool(c.BlockPublicPolicy),
+		RestrictPublicBuckets: aws.ToBool(c.RestrictPublicBuckets),
+	}
+}
+
+// LogTargets maps the buckets that receive logs to the first source found
+// delivering to them, e.g. "ALB access logs of my-alb".
+type LogTargets map[string]string
+
+// Add records a source delivering logs to the bucket.
+func (t LogTargets) Add(bucket string, source string) {
+	if bucket != "" && t[bucket] == "" {
+		t[bucket] = source
+	}
+}
+
+// IsLogBucket reports whether the bucket stores logs: it is the target of a
+// known log source, or it matches the --log-bucket name patterns or tags
+// (config.LogBuckets). Lifecycle and Object Lock are only required for log
+// buckets, and SSE-KMS and server access logging only for the others.
+func IsLogBucket(bucket string, tags func() map[string]string, targets LogTargets) bool {
+	if targets[bucket] != "" {
+		return true
+	}
+	return config.LogBuckets.Match(bucket, tags)
+}
+
+func IsLifecycleConfigured(client api.S3Client, bucket string) bool {
+	_, err := client.GetBucketLifecycleConfiguration(context.TODO(), &s3.GetBucketLifecycleConfigurationInput{
 		Bucket: aws.String(bucket),
 	})
 	return handleS3Error(err)
 }
 
-func IsLifeCycleRuleConfiguredLogBucket(client api.S3Client, bucket string) bool {
-	if strings.Contains(bucket, "log") {
-		_, err := client.GetBucketLifecycleConfiguration(context.TODO(), &s3.GetBucketLifecycleConfigurationInput{
-			Bucket: aws.String(bucket),
-		})
+func IsObjectLockEnabled(client api.S3Client, bucket string) bool {
+	resp, err := client.GetObjectLockConfiguration(context.TODO(), &s3.GetObjectLockConfigurationInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
 		return handleS3Error(err)
 	}
-	return true
+	return resp.ObjectLockConfiguration != nil && resp.ObjectLockConfiguration.ObjectLockEnabled == types.ObjectLockEnabledEnabled
 }
 
-func IsObjectLockEnabled(client api.S3Client, bucket string) bool {
-	if strings.Contains(bucket, "log") {
-		resp, err := client.GetObjectLockConfiguration(context.TODO(), &s3.GetObjectLockConfigurationInput{
-			Bucket: aws.String(bucket),
-		})
-		if err != nil {
-			return handleS3Error(err)
+// DefaultEncryption is the default encryption of a bucket.
+type DefaultEncryption struct {
+	// Algorithm is "AES256", "aws:kms", "aws:kms:dsse" or "" when the bucket
+	// has no default encryption.
+	Algorithm string
+	// KMSKeyID is the key ID, ARN or alias of an SSE-KMS bucket, or "" for
+	// the AWS managed key (aws/s3).
+	KMSKeyID         string
+	BucketKeyEnabled bool
+}
+
+// KMS reports whether objects are encrypted with a KMS key.
+func (e DefaultEncryption) KMS() bool {
+	return e.Algorithm == string(types.ServerSideEncryptionAwsKms) || e.Algorithm == string(types.ServerSideEncryptionAwsKmsDsse)
+}
+
+// GetBucketDefaultEncryption returns the default encryption of the bucket.
+func GetBucketDefaultEncryption(client api.S3Client, bucket string) DefaultEncryption {
+	resp, err := client.GetBucketEncryption(context.TODO(), &s3.GetBucketEncryptionInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		handleS3Error(err)
+		return DefaultEncryption{}
+	}
+	for _, rule := range resp.ServerSideEncryptionConfiguration.Rules {
+		if rule.ApplyServerSideEncryptionByDefault == nil {
+			continue
+		}
+		return DefaultEncryption{
+			Algorithm:        string(rule.ApplyServerSideEncryptionByDefault.SSEAlgorithm),
+			KMSKeyID:         aws.ToString(rule.ApplyServerSideEncryptionByDefault.KMSMasterKeyID),
+			BucketKeyEnabled: aws.ToBool(rule.BucketKeyEnabled),
 		}
-		return resp.ObjectLockConfiguration != nil && resp.ObjectLockConfiguration.ObjectLockEnabled == types.ObjectLockEnabledEnabled
 	}
-	return true
+	return DefaultEncryption{}
 }
 
-func IsBucketEncryptedWithKMS(client api.S3Client, bucket string) bool {
-	if !strings.Contains(bucket, "log") {
-		resp, err := client.GetBucketEncryption(context.TODO(), &s3.GetBucketEncryptionInput{
-			Bucket: aws.String(bucket),
-		})
-		if err != nil {
-			return handleS3Error(err)
+// Versioning is the versioning state of a bucket. Status is "Enabled",
+// "Suspended" or "" for a bucket that was never versioned.
+type Versioning struct {
+	Status    string
+	MFADelete bool
+}
+
+// GetBucketVersioning returns the versioning state of the bucket.
+func GetBucketVersioning(client api.S3Client, bucket string) Versioning {
+	resp, err := client.GetBucketVersioning(context.TODO(), &s3.GetBucketVersioningInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		handleS3Error(err)
+		return Versioning{}
+	}
+	return Versioning{
+		Status:    string(resp.Status),
+		MFADelete: resp.MFADelete == types.MFADeleteStatusEnabled,
+	}
+}
+
+// HasNoncurrentVersionExpiration reports whether an enabled lifecycle rule
+// expires noncurrent object versions.
+func HasNoncurrentVersionExpiration(client api.S3Client, bucket string) bool {
+	resp, err := client.GetBucketLifecycleConfiguration(context.TODO(), &s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		return handleS3Error(err)
+	}
+	for _, rule := range resp.Rules {
+		if rule.Status == types.ExpirationStatusEnabled && rule.NoncurrentVersionExpiration != nil {
+			return true
 		}
-		for _, rule := range resp.ServerSideEncryptionConfiguration.Rules {
-			if rule.ApplyServerSideEncryptionByDefault != nil && rule.ApplyServerSideEncryptionByDefault.SSEAlgorithm == types.ServerSideEncryptionAwsKms {
-				return true
-			}
+	}
+	return false
+}
+
+// GetNotificationDestinations returns the kinds of destination the bucket
+// sends event notifications to: "SNS", "SQS", "Lambda" and "EventBridge".
+func GetNotificationDestinations(client api.S3Client, bucket string) []string {
+	resp, err := client.GetBucketNotificationConfiguration(context.TODO(), &s3.GetBucketNotificationConfigurationInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		handleS3Error(err)
+		return nil
+	}
+	var destinations []string
+	if len(resp.TopicConfigurations) > 0 {
+		destinations = append(destinations, "SNS")
+	}
+	if len(resp.QueueConfigurations) > 0 {
+		destinations = append(destinations, "SQS")
+	}
+	if len(resp.LambdaFunctionConfigurations) > 0 {
+		destinations = append(destinations, "Lambda")
+	}
+	if resp.EventBridgeConfiguration != nil {
+		destinations = append(destinations, "EventBridge")
+	}
+	return destinations
+}
+
+// GetReplicationDestinations returns the destination buckets of the enabled
+// replication rules.
+func GetReplicationDestinations(client api.S3Client, bucket string) []string {
+	resp, err := client.GetBucketReplication(context.TODO(), &s3.GetBucketReplicationInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		handleS3Error(err)
+		return nil
+	}
+	if resp.ReplicationConfiguration == nil {
+		return nil
+	}
+	var destinations []string
+	seen := make(map[string]bool)
+	for _, rule := range resp.ReplicationConfiguration.Rules {
+		if rule.Status != types.ReplicationRuleStatusEnabled || rule.Destination == nil {
+			continue
+		}
+		// The destination is given as a bucket ARN.
+		dest := aws.ToString(rule.Destination.Bucket)
+		if i := strings.LastIndex(dest, ":"); i >= 0 {
+			dest = dest[i+1:]
+		}
+		if !seen[dest] {
+			seen[dest] = true
+			destinations = append(destinations, dest)
 		}
-		return false
 	}
-	return true
+	return destinations
 }
 
-func IsServerAccessLoggingEnabled(client api.S3Client, bucket string) bool {
-	if !strings.Contains(bucket, "log") {
-		resp, err := client.GetBucketLogging(context.TODO(), &s3.GetBucketLoggingInput{
-			Bucket: aws.String(bucket),
-		})
-		if err != nil {
-			return handleS3Error(err)
+// GetBucketRegion returns the region of the bucket. The client should be
+// that of RegionalClients.Location, whose endpoint answers for buckets in
+// every region. When GetBucketLocation is denied (e.g.
+// by the bucket policy or an SCP), the region is read from HeadBucket, whose
+// responses, including 301 and 403 errors, name the bucket's region. The error
+// is that of GetBucketLocation when neither gives the region.
+func GetBucketRegion(client api.S3LocationClient, bucket string) (string, error) {
+	resp, err := client.GetBucketLocation(context.TODO(), &s3.GetBucketLocationInput{
+		Bucket: aws.String(bucket),
+	})
+	if err == nil {
+		switch resp.LocationConstraint {
+		case "":
+			// Buckets in us-east-1 have no location constraint.
+			return "us-east-1", nil
+		case types.BucketLocationConstraintEu:
+			return "eu-west-1", nil
 		}
-		return resp.LoggingEnabled != nil
+		return string(resp.LocationConstraint), nil
+	}
+	if region := regionHeader(err); region != "" {
+		return region, nil
 	}
-	return true
+
+	head, headErr := client.HeadBucket(context.TODO(), &s3.HeadBucketInput{
+		Bucket: aws.String(bucket),
+	})
+	if headErr == nil {
+		if region := aws.ToString(head.BucketRegion); region != "" {
+			return region, nil
+		}
+	} else if region := regionHeader(headErr); region != "" {
+		return region, nil
+	}
+	return "", err
 }
 
-type HTTPStatusError interface {
-	HTTPStatusCode() int
+// regionHeader returns the x-amz-bucket-region header of the response of a
+// failed request, or "".
+func regionHeader(err error) string {
+	var re *awshttp.ResponseError
+	if !errors.As(err, &re) || re.Response == nil || re.Response.Response == nil {
+		return ""
+	}
+	return re.Response.Header.Get("x-amz-bucket-region")
 }
 
-func handleS3Error(err error) bool {
+// GetServerAccessLogging reports whether server access logging is enabled on
+// the bucket, and the bucket the logs are delivered to.
+func GetServerAccessLogging(client api.S3Client, bucket string) (enabled bool, target string) {
+	resp, err := client.GetBucketLogging(context.TODO(), &s3.GetBucketLoggingInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		return handleS3Error(err), ""
+	}
+	if resp.LoggingEnabled == nil {
+		return false, ""
+	}
+	return true, aws.ToString(resp.LoggingEnabled.TargetBucket)
+}
+
+// GetBucketTags returns the bucket tags, or an empty map if the bucket has none.
+func GetBucketTags(client api.S3Client, bucket string) map[string]string {
+	tags := make(map[string]string)
+	resp, err := client.GetBucketTagging(context.TODO(), &s3.GetBucketTaggingInput{
+		Bucket: aws.String(bucket),
+	})
 	if err != nil {
 		var se HTTPStatusError
-		if errors.As(err, &se) {
-			if se.HTTPStatusCode() == 404 {
-				return false
-			} else if se.HTTPStatusCode() != 301 {
-				log.Fatal(err)
-			}
+		if !errors.As(err, &se) || se.HTTPStatusCode() != 404 {
+			log.Printf("Warning: Failed to get tags for bucket %s: %v", bucket, err)
 		}
+		return tags
+	}
+	for _, tag := range resp.TagSet {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return tags
+}
+
+type HTTPStatusError interface {
+	HTTPStatusCode() int
+}
+
+// handleS3Error reports whether the call succeeded. A 404 means the bucket has
+// no such configuration; any other error, including the 301 redirect returned
+// when a bucket is called from the wrong region, stops the checks.
+func handleS3Error(err error) bool {
+	if err == nil {
+		return true
+	}
+	var se HTTPStatusError
+	if errors.As(err, &se) && se.HTTPStatusCode() == 404 {
+		return false
 	}
-	return true
+	Fatalf("%v", err)
+	return false
 }
@@ -3,25 +3,253 @@ package service
 import (
 	"awsselfrev/internal/aws/api"
 	"context"
+	"errors"
 	"log"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3control"
+	"github.com/aws/aws-sdk-go-v2/service/s3control/types"
 )
 
-func IsStorageLensEnabled(client api.S3ControlClient, accountID string) bool {
-	resp, err := client.ListStorageLensConfigurations(context.TODO(), &s3control.ListStorageLensConfigurationsInput{
+// StorageLensConfiguration is what the checks need to know about an S3
+// Storage Lens configuration.
+type StorageLensConfiguration struct {
+	ID      string
+	ARN     string
+	Enabled bool
+	// AdvancedMetrics is set when activity metrics or another advanced
+	// metrics group is on, at the account or bucket level.
+	AdvancedMetrics bool
+	// AllRegions is set when no region is left out of the dashboard.
+	AllRegions bool
+	// ExportBucket is the ARN of the bucket the metrics are exported to, or "".
+	ExportBucket string
+}
+
+// Missing returns what the configuration lacks to give a full view of the
+// storage: advanced metrics, every region and a daily export.
+func (c StorageLensConfiguration) Missing() []string {
+	var missing []string
+	if !c.Enabled {
+		missing = append(missing, "disabled")
+	}
+	if !c.AdvancedMetrics {
+		missing = append(missing, "no advanced metrics")
+	}
+	if !c.AllRegions {
+		missing = append(missing, "not all regions")
+	}
+	if c.ExportBucket == "" {
+		missing = append(missing, "no export")
+	}
+	return missing
+}
+
+// ListStorageLensConfigurations returns the Storage Lens configurations whose
+// home region is the region of the client.
+func ListStorageLensConfigurations(client api.S3ControlClient, accountID string) []StorageLensConfiguration {
+	var configs []StorageLensConfiguration
+	input := &s3control.ListStorageLensConfigurationsInput{AccountId: aws.String(accountID)}
+	for {
+		resp, err := client.ListStorageLensConfigurations(context.TODO(), input)
+		if err != nil {
+			log.Printf("Warning: Failed to list S3 Storage Lens configurations: %v", err)
+			return configs
+		}
+		for _, entry := range resp.StorageLensConfigurationList {
+			configs = append(configs, getStorageLensConfiguration(client, accountID, entry))
+		}
+		if resp.NextToken == nil {
+			return configs
+		}
+		input.NextToken = resp.NextToken
+	}
+}
+
+// getStorageLensConfiguration reads the configuration of a list entry. When
+// it cannot be read, only what the entry tells is known.
+func getStorageLensConfiguration(client api.S3ControlClient, accountID string, entry types.ListStorageLensConfigurationEntry) StorageLensConfiguration {
+	config := StorageLensConfiguration{
+		ID:      aws.ToString(entry.Id),
+		ARN:     aws.ToString(entry.StorageLensArn),
+		Enabled: entry.IsEnabled,
+	}
+	resp, err := client.GetStorageLensConfiguration(context.TODO(), &s3control.GetStorageLensConfigurationInput{
 		AccountId: aws.String(accountID),
+		ConfigId:  entry.Id,
 	})
 	if err != nil {
-		log.Printf("Warning: Failed to list S3 Storage Lens configurations: %v", err)
-		return false
+		log.Printf("Warning: Failed to get S3 Storage Lens configuration %s: %v", config.ID, err)
+		return config
+	}
+	c := resp.StorageLensConfiguration
+	if c == nil {
+		return config
+	}
+	if c.AccountLevel != nil {
+		config.AdvancedMetrics = hasAdvancedMetrics(c.AccountLevel.ActivityMetrics, c.AccountLevel.AdvancedCostOptimizationMetrics,
+			c.AccountLevel.AdvancedDataProtectionMetrics, c.AccountLevel.AdvancedPerformanceMetrics, c.AccountLevel.DetailedStatusCodesMetrics)
+		if b := c.AccountLevel.BucketLevel; b != nil && !config.AdvancedMetrics {
+			config.AdvancedMetrics = hasAdvancedMetrics(b.ActivityMetrics, b.AdvancedCostOptimizationMetrics,
+				b.AdvancedDataProtectionMetrics, b.AdvancedPerformanceMetrics, b.DetailedStatusCodesMetrics)
+		}
 	}
+	config.AllRegions = (c.Include == nil || len(c.Include.Regions) == 0) && (c.Exclude == nil || len(c.Exclude.Regions) == 0)
+	if c.DataExport != nil && c.DataExport.S3BucketDestination != nil {
+		config.ExportBucket = aws.ToString(c.DataExport.S3BucketDestination.Arn)
+	}
+	return config
+}
 
-	for _, config := range resp.StorageLensConfigurationList {
-		if config.IsEnabled {
-			return true
+func hasAdvancedMetrics(activity *types.ActivityMetrics, cost *types.AdvancedCostOptimizationMetrics, protection *types.AdvancedDataProtectionMetrics,
+	performance *types.AdvancedPerformanceMetrics, statusCodes *types.DetailedStatusCodesMetrics) bool {
+	return (activity != nil && activity.IsEnabled) ||
+		(cost != nil && cost.IsEnabled) ||
+		(protection != nil && protection.IsEnabled) ||
+		(performance != nil && performance.IsEnabled) ||
+		(statusCodes != nil && statusCodes.IsEnabled)
+}
+
+// GetAccountPublicAccessBlock returns the account-level Block Public Access
+// settings, which apply to every bucket. Without a configuration, or when it
+// cannot be read, every setting is off, so only the bucket settings count.
+func GetAccountPublicAccessBlock(client api.S3ControlClient, accountID string) PublicAccessBlock {
+	if accountID == "" {
+		return PublicAccessBlock{}
+	}
+	resp, err := client.GetPublicAccessBlock(context.TODO(), &s3control.GetPublicAccessBlockInput{
+		AccountId: aws.String(accountID),
+	})
+	if err != nil {
+		var se HTTPStatusError
+		if !errors.As(err, &se) || se.HTTPStatusCode() != 404 {
+			log.Printf("Warning: Failed to get account-level public access block: %v", err)
 		}
+		return PublicAccessBlock{}
 	}
+	return ControlPublicAccessBlock(resp.PublicAccessBlockConfiguration)
+}
+
+// ControlPublicAccessBlock converts Block Public Access settings returned by
+// S3 Control.
+func ControlPublicAccessBlock(c *types.PublicAccessBlockConfiguration) PublicAccessBlock {
+	if c == nil {
+		return PublicAccessBlock{}
+	}
+	return PublicAccessBlock{
+		BlockPublicAcls:       aws.ToBool(c.BlockPublicAcls),
+		IgnorePublicAcls:      aws.ToBool(c.IgnorePublicAcls),
+		BlockPublicPolicy:     aws.ToBool(c.BlockPublicPolicy),
+		RestrictPublicBuckets: aws.ToBool(c.RestrictPublicBuckets),
+	}
+}
+
+// ListAccessPoints returns the access points of the account in the region of
+// the client.
+func ListAccessPoints(client api.S3ControlClient, accountID string) []types.AccessPoint {
+	if accountID == "" {
+		return nil
+	}
+	var accessPoints []types.AccessPoint
+	input := &s3control.ListAccessPointsInput{AccountId: aws.String(accountID)}
+	for {
+		resp, err := client.ListAccessPoints(context.TODO(), input)
+		if err != nil {
+			log.Printf("Warning: Failed to list S3 access points: %v", err)
+			return accessPoints
+		}
+		accessPoints = append(accessPoints, resp.AccessPointList...)
+		if resp.NextToken == nil {
+			return accessPoints
+		}
+		input.NextToken = resp.NextToken
+	}
+}
+
+// GetAccessPointPublicAccessBlock returns the Block Public Access settings of
+// the access point. They are set when it is created and cannot be changed.
+func GetAccessPointPublicAccessBlock(client api.S3ControlClient, accountID, name string) PublicAccessBlock {
+	resp, err := client.GetAccessPoint(context.TODO(), &s3control.GetAccessPointInput{
+		AccountId: aws.String(accountID),
+		Name:      aws.String(name),
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to get access point %s: %v", name, err)
+		return PublicAccessBlock{}
+	}
+	return ControlPublicAccessBlock(resp.PublicAccessBlockConfiguration)
+}
+
+// GetAccessPointPolicy returns the access point policy, or nil when it has
+// none. ok is false when the policy cannot be read.
+func GetAccessPointPolicy(client api.S3ControlClient, accountID, name string) (policy *Policy, ok bool) {
+	resp, err := client.GetAccessPointPolicy(context.TODO(), &s3control.GetAccessPointPolicyInput{
+		AccountId: aws.String(accountID),
+		Name:      aws.String(name),
+	})
+	if err != nil {
+		return nil, isNotFound(err, "Warning: Failed to get the policy of access point "+name)
+	}
+	return parseControlPolicy(aws.ToString(resp.Policy), "access point "+name)
+}
+
+// ListMultiRegionAccessPoints returns the Multi-Region Access Points of the
+// account. The client must be that of ControlClients.MultiRegion.
+func ListMultiRegionAccessPoints(client api.S3ControlClient, accountID string) []types.MultiRegionAccessPointReport {
+	if accountID == "" {
+		return nil
+	}
+	var accessPoints []types.MultiRegionAccessPointReport
+	input := &s3control.ListMultiRegionAccessPointsInput{AccountId: aws.String(accountID)}
+	for {
+		resp, err := client.ListMultiRegionAccessPoints(context.TODO(), input)
+		if err != nil {
+			log.Printf("Warning: Failed to list S3 Multi-Region Access Points: %v", err)
+			return accessPoints
+		}
+		accessPoints = append(accessPoints, resp.AccessPoints...)
+		if resp.NextToken == nil {
+			return accessPoints
+		}
+		input.NextToken = resp.NextToken
+	}
+}
+
+// GetMultiRegionAccessPointPolicy returns the established policy of the
+// Multi-Region Access Point, or nil when it has none. ok is false when the
+// policy cannot be read.
+func GetMultiRegionAccessPointPolicy(client api.S3ControlClient, accountID, name string) (policy *Policy, ok bool) {
+	resp, err := client.GetMultiRegionAccessPointPolicy(context.TODO(), &s3control.GetMultiRegionAccessPointPolicyInput{
+		AccountId: aws.String(accountID),
+		Name:      aws.String(name),
+	})
+	if err != nil {
+		return nil, isNotFound(err, "Warning: Failed to get the policy of Multi-Region Access Point "+name)
+	}
+	if resp.Policy == nil || resp.Policy.Established == nil {
+		return nil, true
+	}
+	return parseControlPolicy(aws.ToString(resp.Policy.Established.Policy), "Multi-Region Access Point "+name)
+}
+
+// isNotFound reports whether err is a 404, and logs the warning otherwise.
+func isNotFound(err error, warning string) bool {
+	var se HTTPStatusError
+	if errors.As(err, &se) && se.HTTPStatusCode() == 404 {
+		return true
+	}
+	log.Printf("%s: %v", warning, err)
 	return false
 }
+
+func parseControlPolicy(document, resource string) (*Policy, bool) {
+	if document == "" {
+		return nil, true
+	}
+	p, err := ParsePolicy(document)
+	if err != nil {
+		log.Printf("Warning: Failed to parse the policy of %s: %v", resource, err)
+		return nil, false
+	}
+	return p, true
+}
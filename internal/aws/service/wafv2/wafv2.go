@@ -35,6 +35,23 @@ func ListWebACLs(client api.WAFV2Client, scope types.Scope) []WebACLInfo {
 	return webACLs
 }
 
+func GetWebACLTags(client api.WAFV2Client, resourceArn string) map[string]string {
+	tags := make(map[string]string)
+	resp, err := client.ListTagsForResource(context.TODO(), &wafv2.ListTagsForResourceInput{
+		ResourceARN: aws.String(resourceArn),
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to list tags for WAF v2 Web ACL %s: %v", resourceArn, err)
+		return tags
+	}
+	if resp.TagInfoForResource != nil {
+		for _, tag := range resp.TagInfoForResource.TagList {
+			tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+	}
+	return tags
+}
+
 func IsWAFV2LoggingEnabled(client api.WAFV2Client, resourceArn string) bool {
 	_, err := client.GetLoggingConfiguration(context.TODO(), &wafv2.GetLoggingConfigurationInput{
 		ResourceArn: aws.String(resourceArn),
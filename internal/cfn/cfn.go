@@ -0,0 +1,318 @@
+package cfn
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Template is a parsed CloudFormation template.
+type Template struct {
+	Path       string
+	Parameters map[string]interface{}
+	Resources  []*Resource
+}
+
+// Resource is one entry of the template's Resources section.
+type Resource struct {
+	LogicalID  string
+	Type       string
+	Properties map[string]interface{}
+
+	template *Template
+}
+
+// Load reads a YAML or JSON template. Short-form intrinsic functions such as
+// !Ref or !GetAtt are kept as their long form ({"Ref": ...}), so properties
+// that use them are reported as set but unresolved instead of failing to parse.
+func Load(path string) (*Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	t.Path = path
+	return t, nil
+}
+
+// Parse parses a YAML or JSON template.
+func Parse(data []byte) (*Template, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	doc, ok := convert(&root).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("not a CloudFormation template")
+	}
+	resources, ok := doc["Resources"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("not a CloudFormation template: Resources section is missing")
+	}
+
+	t := &Template{Parameters: make(map[string]interface{})}
+	if params, ok := doc["Parameters"].(map[string]interface{}); ok {
+		for name, p := range params {
+			if m, ok := p.(map[string]interface{}); ok {
+				t.Parameters[name] = m["Default"]
+			}
+		}
+	}
+	for _, id := range orderedKeys(&root, "Resources") {
+		m, ok := resources[id].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		typ, _ := m["Type"].(string)
+		props, _ := m["Properties"].(map[string]interface{})
+		if props == nil {
+			props = make(map[string]interface{})
+		}
+		t.Resources = append(t.Resources, &Resource{LogicalID: id, Type: typ, Properties: props, template: t})
+	}
+	return t, nil
+}
+
+// convert turns a YAML node into plain values. Short-form intrinsic function
+// tags become their long form: !Ref X is {"Ref": "X"}, !GetAtt A.B is
+// {"Fn::GetAtt": ["A", "B"]} and !Sub s is {"Fn::Sub": s}.
+func convert(n *yaml.Node) interface{} {
+	var v interface{}
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil
+		}
+		return convert(n.Content[0])
+	case yaml.AliasNode:
+		return convert(n.Alias)
+	case yaml.MappingNode:
+		m := make(map[string]interface{})
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			m[n.Content[i].Value] = convert(n.Content[i+1])
+		}
+		v = m
+	case yaml.SequenceNode:
+		list := make([]interface{}, 0, len(n.Content))
+		for _, c := range n.Content {
+			list = append(list, convert(c))
+		}
+		v = list
+	case yaml.ScalarNode:
+		if strings.HasPrefix(n.Tag, "!!") || n.Tag == "" {
+			var scalar interface{}
+			if err := n.Decode(&scalar); err != nil {
+				return n.Value
+			}
+			return scalar
+		}
+		v = n.Value
+	}
+
+	if !strings.HasPrefix(n.Tag, "!") || strings.HasPrefix(n.Tag, "!!") {
+		return v
+	}
+	name := strings.TrimPrefix(n.Tag, "!")
+	switch name {
+	case "Ref", "Condition":
+		return map[string]interface{}{name: v}
+	case "GetAtt":
+		if s, ok := v.(string); ok {
+			id, attr, _ := strings.Cut(s, ".")
+			v = []interface{}{id, attr}
+		}
+	}
+	return map[string]interface{}{"Fn::" + name: v}
+}
+
+// orderedKeys returns the keys of a top-level mapping in document order.
+func orderedKeys(root *yaml.Node, section string) []string {
+	doc := root
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+	var keys []string
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		if doc.Content[i].Value != section {
+			continue
+		}
+		m := doc.Content[i+1]
+		for j := 0; j+1 < len(m.Content); j += 2 {
+			keys = append(keys, m.Content[j].Value)
+		}
+	}
+	return keys
+}
+
+// TemplatePath returns the path of the template defining the resource.
+func (r *Resource) TemplatePath() string {
+	return r.template.Path
+}
+
+// OfType returns the resources of the given types, in template order.
+func (t *Template) OfType(types ...string) []*Resource {
+	var out []*Resource
+	for _, r := range t.Resources {
+		for _, typ := range types {
+			if r.Type == typ {
+				out = append(out, r)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// Get walks nested properties. Lists are descended into through their first
+// element. A Ref to a template parameter resolves to its default value.
+func (r *Resource) Get(path ...string) interface{} {
+	var cur interface{} = r.Properties
+	for _, key := range path {
+		cur = r.resolve(cur)
+		if list, ok := cur.([]interface{}); ok {
+			if len(list) == 0 {
+				return nil
+			}
+			cur = r.resolve(list[0])
+		}
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = m[key]
+	}
+	return r.resolve(cur)
+}
+
+func (r *Resource) resolve(v interface{}) interface{} {
+	if m, ok := v.(map[string]interface{}); ok && len(m) == 1 {
+		if name, ok := m["Ref"].(string); ok {
+			if def, ok := r.template.Parameters[name]; ok && def != nil {
+				return def
+			}
+		}
+	}
+	return v
+}
+
+// IsSet reports whether the property is present, including values computed
+// by intrinsic functions.
+func (r *Resource) IsSet(path ...string) bool {
+	switch v := r.Get(path...).(type) {
+	case nil:
+		return false
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	}
+	return true
+}
+
+// String returns the property as a string, or "" when it is not set or not
+// a literal.
+func (r *Resource) String(path ...string) string {
+	switch v := r.Get(path...).(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+// Bool returns the property as a bool and whether it is a literal. Templates
+// often write booleans as strings, so "true" and "false" are accepted.
+func (r *Resource) Bool(path ...string) (bool, bool) {
+	switch v := r.Get(path...).(type) {
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(v)
+		return b, err == nil
+	}
+	return false, false
+}
+
+// Int returns the property as an int and whether it is a literal.
+func (r *Resource) Int(path ...string) (int, bool) {
+	switch v := r.Get(path...).(type) {
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
+	case string:
+		i, err := strconv.Atoi(v)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// List returns a list property, or nil when it is not a literal list.
+func (r *Resource) List(path ...string) []interface{} {
+	list, _ := r.Get(path...).([]interface{})
+	return list
+}
+
+// RefersTo reports whether the property refers to the resource with the
+// given logical ID through Ref, Fn::GetAtt or Fn::Sub.
+func (r *Resource) RefersTo(logicalID string, path ...string) bool {
+	return refersTo(r.Get(path...), logicalID)
+}
+
+func refersTo(v interface{}, logicalID string) bool {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for fn, arg := range t {
+			switch fn {
+			case "Ref":
+				if arg == logicalID {
+					return true
+				}
+			case "Fn::GetAtt":
+				if list, ok := arg.([]interface{}); ok && len(list) > 0 && list[0] == logicalID {
+					return true
+				}
+			case "Fn::Sub":
+				if s, ok := arg.(string); ok && strings.Contains(s, "${"+logicalID+"}") {
+					return true
+				}
+			}
+			if refersTo(arg, logicalID) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range t {
+			if refersTo(item, logicalID) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Tags returns the literal Key/Value pairs of the Tags property.
+func (r *Resource) Tags() map[string]string {
+	tags := make(map[string]string)
+	for _, item := range r.List("Tags") {
+		if m, ok := item.(map[string]interface{}); ok {
+			key, _ := m["Key"].(string)
+			value, _ := m["Value"].(string)
+			if key != "" {
+				tags[key] = value
+			}
+		}
+	}
+	return tags
+}
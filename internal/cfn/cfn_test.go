@@ -0,0 +1,55 @@
+package cfn
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	tmpl, err := Load("testdata/template.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, "testdata/template.yaml", tmpl.Path)
+
+	// Resources keep their template order.
+	var ids []string
+	for _, r := range tmpl.Resources {
+		ids = append(ids, r.LogicalID)
+	}
+	assert.Equal(t, []string{"Vpc", "FlowLog", "Logs", "Database"}, ids)
+
+	vpc := tmpl.OfType("AWS::EC2::VPC")[0]
+	assert.Equal(t, map[string]string{"Team": "platform"}, vpc.Tags())
+	flowLog := tmpl.OfType("AWS::EC2::FlowLog")[0]
+	assert.True(t, flowLog.RefersTo("Vpc", "ResourceId"))
+	assert.False(t, flowLog.RefersTo("Logs", "ResourceId"))
+
+	// A Ref to a parameter resolves to its default; without a default it
+	// stays an intrinsic value that counts as set.
+	logs := tmpl.OfType("AWS::Logs::LogGroup")[0]
+	retention, ok := logs.Int("RetentionInDays")
+	assert.True(t, ok)
+	assert.Equal(t, 30, retention)
+	assert.True(t, logs.IsSet("KmsKeyId"))
+	assert.Equal(t, "", logs.String("KmsKeyId"))
+	assert.Equal(t, map[string]interface{}{"Fn::Sub": "/app/${AWS::StackName}"}, logs.Get("LogGroupName"))
+
+	db := tmpl.OfType("AWS::RDS::DBCluster")[0]
+	encrypted, ok := db.Bool("StorageEncrypted")
+	assert.True(t, ok)
+	assert.True(t, encrypted)
+	_, ok = db.Bool("DeletionProtection")
+	assert.False(t, ok)
+	assert.Equal(t, map[string]interface{}{"Fn::GetAtt": []interface{}{"Key", "Arn"}}, db.Get("KmsKeyId"))
+}
+
+func TestParseJSON(t *testing.T) {
+	tmpl, err := Parse([]byte(`{"Resources": {"Bucket": {"Type": "AWS::S3::Bucket", "Properties": {"BucketName": {"Ref": "Name"}}}}}`))
+	assert.NoError(t, err)
+	assert.Len(t, tmpl.Resources, 1)
+	assert.True(t, tmpl.Resources[0].IsSet("BucketName"))
+	assert.Equal(t, "", tmpl.Resources[0].String("BucketName"))
+
+	_, err = Parse([]byte(`{"foo": "bar"}`))
+	assert.Error(t, err)
+}
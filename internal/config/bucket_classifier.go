@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BucketClassifier classifies S3 buckets by name and tags, e.g. as log
+// buckets from the --log-bucket flags. The values take the --resource-filter
+// forms except arn:, and a bucket matches when it matches any one of them.
+type BucketClassifier struct {
+	selectors ResourceFilter
+}
+
+// DefaultLogBucketPattern matches "log", "logs" or "logging" as a whole word
+// of the bucket name, e.g. "alb-logs" or "logs.example.com" but not
+// "catalog-assets".
+const DefaultLogBucketPattern = `re:(^|[-_.])(logs?|logging)([-_.]|$)`
+
+// DefaultCriticalBucketSelector selects the buckets tagged Critical=true.
+const DefaultCriticalBucketSelector = "tag:Critical=true"
+
+var (
+	// LogBuckets selects the buckets the S3 checks treat as log buckets.
+	LogBuckets = mustParseBucketClassifier("--log-bucket", nil, DefaultLogBucketPattern)
+	// CriticalBuckets selects the buckets that must have MFA delete,
+	// cross-region replication and event notifications.
+	CriticalBuckets = mustParseBucketClassifier("--critical-bucket", nil, DefaultCriticalBucketSelector)
+)
+
+// ParseBucketClassifier builds a BucketClassifier from the values of flag,
+// or from defaults when no value is given.
+func ParseBucketClassifier(flag string, values []string, defaults ...string) (BucketClassifier, error) {
+	if len(values) == 0 {
+		values = defaults
+	}
+	for _, v := range values {
+		if strings.HasPrefix(strings.TrimSpace(v), "arn:") {
+			return BucketClassifier{}, fmt.Errorf("invalid %s %q: ARN patterns are not supported", flag, v)
+		}
+	}
+	selectors, err := parseSelectors(flag, values)
+	if err != nil {
+		return BucketClassifier{}, err
+	}
+	return BucketClassifier{selectors: selectors}, nil
+}
+
+func mustParseBucketClassifier(flag string, values []string, defaults ...string) BucketClassifier {
+	c, err := ParseBucketClassifier(flag, values, defaults...)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Match reports whether the bucket matches. tags is only called when no name
+// pattern matches and the classifier has tag selectors.
+func (c BucketClassifier) Match(bucket string, tags func() map[string]string) bool {
+	for _, re := range c.selectors.names {
+		if re.MatchString(bucket) {
+			return true
+		}
+	}
+	if len(c.selectors.tags) == 0 || tags == nil {
+		return false
+	}
+	bucketTags := tags()
+	for _, sel := range c.selectors.tags {
+		if value, ok := bucketTags[sel.key]; ok && (sel.value == nil || sel.value.MatchString(value)) {
+			return true
+		}
+	}
+	return false
+}
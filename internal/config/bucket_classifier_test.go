@@ -0,0 +1,35 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucketClassifierMatch(t *testing.T) {
+	noTags := func() map[string]string { return nil }
+	auditTags := func() map[string]string { return map[string]string{"Purpose": "audit-logs"} }
+
+	f, err := ParseBucketClassifier("--log-bucket", nil, DefaultLogBucketPattern)
+	assert.NoError(t, err)
+	assert.True(t, f.Match("alb-logs", noTags))
+	assert.True(t, f.Match("log-archive-123", noTags))
+	assert.True(t, f.Match("logs.example.com", noTags))
+	assert.True(t, f.Match("app_logging_bucket", noTags))
+	assert.False(t, f.Match("catalog-assets", noTags))
+	assert.False(t, f.Match("audit-trail", noTags))
+
+	f, err = ParseBucketClassifier("--log-bucket", []string{"*-trail", "tag:Purpose=*logs"}, DefaultLogBucketPattern)
+	assert.NoError(t, err)
+	assert.True(t, f.Match("audit-trail", noTags))
+	assert.True(t, f.Match("anything", auditTags))
+	assert.False(t, f.Match("alb-logs", noTags))
+
+	_, err = ParseBucketClassifier("--log-bucket", []string{"arn:aws:s3:::*-logs"})
+	assert.Error(t, err)
+
+	f, err = ParseBucketClassifier("--critical-bucket", nil, DefaultCriticalBucketSelector)
+	assert.NoError(t, err)
+	assert.True(t, f.Match("payments", func() map[string]string { return map[string]string{"Critical": "true"} }))
+	assert.False(t, f.Match("payments", noTags))
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+var levels = []string{"Info", "Warning", "Alert"}
+
+// Filter selects which rules are evaluated, from the --rules, --exclude-rules
+// and --min-level flags. The zero value selects every rule.
+type Filter struct {
+	Include  []string
+	Exclude  []string
+	MinLevel string
+}
+
+// RuleFilter is applied by LoadRules to every rules file it loads.
+var RuleFilter Filter
+
+// ParseFilter builds a Filter from comma-separated rule keys and a level name.
+func ParseFilter(include string, exclude string, minLevel string) (Filter, error) {
+	f := Filter{
+		Include: splitKeys(include),
+		Exclude: splitKeys(exclude),
+	}
+	if minLevel != "" {
+		for _, level := range levels {
+			if strings.EqualFold(level, minLevel) {
+				f.MinLevel = level
+			}
+		}
+		if f.MinLevel == "" {
+			return Filter{}, fmt.Errorf("invalid --min-level value %q (must be one of %s)", minLevel, strings.Join(levels, ", "))
+		}
+	}
+	return f, nil
+}
+
+// Apply disables every rule the filter does not select.
+// Unknown keys in Include or Exclude are reported as an error to catch typos.
+func (f Filter) Apply(rules *RulesConfig) error {
+	for _, key := range append(append([]string(nil), f.Include...), f.Exclude...) {
+		if _, ok := rules.Rules[key]; !ok {
+			return fmt.Errorf("unknown rule key %q", key)
+		}
+	}
+
+	disabled := false
+	for key, rule := range rules.Rules {
+		if !f.selects(key, rule) {
+			rule.Enabled = &disabled
+			rules.Rules[key] = rule
+		}
+	}
+	return nil
+}
+
+func (f Filter) selects(key string, rule Rule) bool {
+	if len(f.Include) > 0 && !contains(f.Include, key) {
+		return false
+	}
+	if contains(f.Exclude, key) {
+		return false
+	}
+	if f.MinLevel != "" && LevelRank(rule.Level) < LevelRank(f.MinLevel) {
+		return false
+	}
+	return true
+}
+
+// LevelRank orders levels by severity: Info < Warning < Alert.
+// Unknown levels rank below Info.
+func LevelRank(level string) int {
+	for i, l := range levels {
+		if strings.EqualFold(l, level) {
+			return i
+		}
+	}
+	return -1
+}
+
+func splitKeys(s string) []string {
+	var keys []string
+	for _, key := range strings.Split(s, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterApply(t *testing.T) {
+	newRules := func() RulesConfig {
+		return RulesConfig{Rules: map[string]Rule{
+			"s3-encryption":        {Service: "S3", Level: "Alert"},
+			"s3-lifecycle":         {Service: "S3", Level: "Warning"},
+			"ecs-cpu-architecture": {Service: "ECS", Level: "Warning"},
+			"vpc-name-tag":         {Service: "VPC", Level: "Info"},
+		}}
+	}
+	enabled := func(rules RulesConfig) []string {
+		var keys []string
+		for _, key := range []string{"s3-encryption", "s3-lifecycle", "ecs-cpu-architecture", "vpc-name-tag"} {
+			if rules.Rules[key].IsEnabled() {
+				keys = append(keys, key)
+			}
+		}
+		return keys
+	}
+
+	f, err := ParseFilter("", "ecs-cpu-architecture", "warning")
+	assert.NoError(t, err)
+	rules := newRules()
+	assert.NoError(t, f.Apply(&rules))
+	assert.Equal(t, []string{"s3-encryption", "s3-lifecycle"}, enabled(rules))
+
+	f, err = ParseFilter("s3-encryption, vpc-name-tag", "", "")
+	assert.NoError(t, err)
+	rules = newRules()
+	assert.NoError(t, f.Apply(&rules))
+	assert.Equal(t, []string{"s3-encryption", "vpc-name-tag"}, enabled(rules))
+
+	f, err = ParseFilter("s3-encrypt", "", "")
+	assert.NoError(t, err)
+	rules = newRules()
+	assert.Error(t, f.Apply(&rules))
+
+	_, err = ParseFilter("", "", "Critical")
+	assert.Error(t, err)
+}
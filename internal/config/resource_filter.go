@@ -0,0 +1,135 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ResourceFilter selects resources by name, ARN and tags, from the
+// --resource-filter flags. The zero value selects every resource.
+//
+// Each flag value is one of:
+//
+//	prod-*              glob on the resource name
+//	re:^payments-.*$    regular expression on the resource name
+//	arn:aws:s3:::prod-* glob on the resource ARN
+//	tag:Team=payments   tag selector; the value may be a glob
+//	tag:Team            tag selector that only requires the key
+//
+// A resource is selected when it matches at least one name or ARN pattern
+// (if any are given) and every tag selector.
+type ResourceFilter struct {
+	names []*regexp.Regexp
+	arns  []*regexp.Regexp
+	tags  []tagSelector
+}
+
+type tagSelector struct {
+	key   string
+	value *regexp.Regexp
+}
+
+// Resources is the filter applied by the checks.
+var Resources ResourceFilter
+
+func ParseResourceFilter(values []string) (ResourceFilter, error) {
+	return parseSelectors("--resource-filter", values)
+}
+
+// parseSelectors parses the selector forms shared by --resource-filter and
+// --log-bucket. flag names the flag in errors.
+func parseSelectors(flag string, values []string) (ResourceFilter, error) {
+	var f ResourceFilter
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		switch {
+		case v == "":
+			continue
+		case strings.HasPrefix(v, "tag:"):
+			key, value, hasValue := strings.Cut(strings.TrimPrefix(v, "tag:"), "=")
+			if key == "" {
+				return ResourceFilter{}, fmt.Errorf("invalid %s %q: tag key is empty", flag, v)
+			}
+			sel := tagSelector{key: key}
+			if hasValue {
+				sel.value = globToRegexp(value)
+			}
+			f.tags = append(f.tags, sel)
+		case strings.HasPrefix(v, "re:"):
+			re, err := regexp.Compile(strings.TrimPrefix(v, "re:"))
+			if err != nil {
+				return ResourceFilter{}, fmt.Errorf("invalid %s %q: %v", flag, v, err)
+			}
+			f.names = append(f.names, re)
+		case strings.HasPrefix(v, "arn:"):
+			f.arns = append(f.arns, globToRegexp(v))
+		default:
+			f.names = append(f.names, globToRegexp(v))
+		}
+	}
+	return f, nil
+}
+
+// HasTagSelectors reports whether matching needs the resource tags.
+// Checks use it to avoid tag API calls when no tag selector is given.
+func (f ResourceFilter) HasTagSelectors() bool {
+	return len(f.tags) > 0
+}
+
+// Match reports whether the resource is selected. tags is only called when
+// the filter has tag selectors and may be nil for resources without tags.
+func (f ResourceFilter) Match(name string, arn string, tags func() map[string]string) bool {
+	if len(f.names) > 0 || len(f.arns) > 0 {
+		matched := false
+		for _, re := range f.names {
+			if re.MatchString(name) {
+				matched = true
+				break
+			}
+		}
+		for _, re := range f.arns {
+			if arn != "" && re.MatchString(arn) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if len(f.tags) > 0 {
+		var resourceTags map[string]string
+		if tags != nil {
+			resourceTags = tags()
+		}
+		for _, sel := range f.tags {
+			value, ok := resourceTags[sel.key]
+			if !ok || (sel.value != nil && !sel.value.MatchString(value)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// globToRegexp converts a glob where * matches any run of characters
+// (including "/", so "/aws/*" matches every log group under /aws/) and ?
+// matches a single character.
+func globToRegexp(glob string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResourceFilterMatch(t *testing.T) {
+	noTags := func() map[string]string { return nil }
+	teamTags := func() map[string]string { return map[string]string{"Team": "payments-api"} }
+
+	f, err := ParseResourceFilter([]string{"prod-*", "re:^payments-[0-9]+$"})
+	assert.NoError(t, err)
+	assert.True(t, f.Match("prod-assets", "", noTags))
+	assert.True(t, f.Match("payments-42", "", noTags))
+	assert.False(t, f.Match("catalog-prod-assets", "", noTags))
+	assert.False(t, f.HasTagSelectors())
+
+	f, err = ParseResourceFilter([]string{"/aws/ecs/*"})
+	assert.NoError(t, err)
+	assert.True(t, f.Match("/aws/ecs/payments/app", "", noTags))
+
+	f, err = ParseResourceFilter([]string{"arn:aws:ecs:*:cluster/payments-*"})
+	assert.NoError(t, err)
+	assert.True(t, f.Match("payments-prod", "arn:aws:ecs:ap-northeast-1:123456789012:cluster/payments-prod", noTags))
+	assert.False(t, f.Match("payments-prod", "", noTags))
+
+	f, err = ParseResourceFilter([]string{"tag:Team=payments-*", "tag:Team"})
+	assert.NoError(t, err)
+	assert.True(t, f.HasTagSelectors())
+	assert.True(t, f.Match("anything", "", teamTags))
+	assert.False(t, f.Match("anything", "", noTags))
+
+	_, err = ParseResourceFilter([]string{"re:("})
+	assert.Error(t, err)
+	_, err = ParseResourceFilter([]string{"tag:=x"})
+	assert.Error(t, err)
+}
@@ -3,14 +3,43 @@ package config
 import (
 	"log"
 	"os"
+	"sort"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Rule struct {
-	Service string `yaml:"service"`
-	Level   string `yaml:"level"`
-	Issue   string `yaml:"issue"`
+	Key         string   `yaml:"-"`
+	Service     string   `yaml:"service"`
+	Level       string   `yaml:"level"`
+	Issue       string   `yaml:"issue"`
+	Remediation string   `yaml:"remediation"`
+	CLI         string   `yaml:"cli"`
+	Terraform   string   `yaml:"terraform"`
+	Docs        []string `yaml:"docs"`
+	Enabled     *bool    `yaml:"enabled"`
+
+	// Custom rules are evaluated against collected resource documents
+	// instead of by a built-in check. See IsCustom.
+	Resource string `yaml:"resource"`
+	When     string `yaml:"when"`
+	Assert   string `yaml:"assert"`
+	Setting  string `yaml:"setting"`
+
+	// Policy marks a rule whose findings come from Rego policies (--policy).
+	Policy bool `yaml:"policy"`
+}
+
+// IsEnabled reports whether the rule should be evaluated.
+// Rules are enabled unless rules.yaml sets "enabled: false".
+func (r Rule) IsEnabled() bool {
+	return r.Enabled == nil || *r.Enabled
+}
+
+// IsCustom reports whether the rule is a declarative rule with JMESPath
+// expressions over resources of type Resource, rather than a built-in check.
+func (r Rule) IsCustom() bool {
+	return r.Resource != ""
 }
 
 type RulesConfig struct {
@@ -28,6 +57,10 @@ func LoadRules() RulesConfig {
 		log.Fatalf("Failed to parse rules.yaml: %v", err)
 	}
 
+	if err := RuleFilter.Apply(&rules); err != nil {
+		log.Fatalf("Failed to filter rules: %v", err)
+	}
+
 	return rules
 }
 
@@ -38,5 +71,28 @@ func (r RulesConfig) Get(key string) Rule {
 	if !ok {
 		log.Fatalf("Rule not found for key: %s", key)
 	}
+	rule.Key = key
 	return rule
 }
+
+// Validate compares the loaded rules with the keys referenced by the checks.
+// missing lists keys the checks need but the rules file lacks, and unused lists
+// keys defined in the rules file that no check refers to. Custom rules and
+// policy rules are never reported as unused.
+func (r RulesConfig) Validate(keys []string) (missing []string, unused []string) {
+	referenced := make(map[string]bool)
+	for _, key := range keys {
+		referenced[key] = true
+		if _, ok := r.Rules[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	for key, rule := range r.Rules {
+		if !referenced[key] && !rule.IsCustom() && !rule.Policy {
+			unused = append(unused, key)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(unused)
+	return missing, unused
+}
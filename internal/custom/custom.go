@@ -0,0 +1,166 @@
+package custom
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"awsselfrev/internal/config"
+	"awsselfrev/internal/inventory"
+
+	"github.com/jmespath/go-jmespath"
+)
+
+// compiled caches parsed expressions by their source text.
+var compiled = make(map[string]*jmespath.JMESPath)
+
+// Result is the outcome of a custom rule for one resource.
+type Result struct {
+	Rule    config.Rule
+	Status  string
+	Setting string
+}
+
+// Validate checks that a custom rule refers to a known resource type and
+// that its expressions compile.
+func Validate(rule config.Rule) error {
+	if !inventory.IsType(rule.Resource) {
+		return fmt.Errorf("unknown resource type %q", rule.Resource)
+	}
+	if rule.Assert == "" {
+		return fmt.Errorf("assert is empty")
+	}
+	for _, expr := range []string{rule.When, rule.Assert, rule.Setting} {
+		if expr == "" {
+			continue
+		}
+		if _, err := compile(expr); err != nil {
+			return fmt.Errorf("invalid expression %q: %v", expr, err)
+		}
+	}
+	return nil
+}
+
+// Targets reports whether an enabled custom rule targets the resource type.
+func Targets(rules config.RulesConfig, resourceType string) bool {
+	for _, rule := range rules.Rules {
+		if rule.Resource == resourceType && rule.IsEnabled() {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate runs every enabled custom rule for the resource type, in key order.
+// Rules whose "when" expression is falsy for the resource are skipped. The
+// resource passes when "assert" is truthy. A rule whose expressions fail on the
+// resource is left out of the results and reported in the returned error.
+func Evaluate(rules config.RulesConfig, res inventory.Resource) ([]Result, error) {
+	keys := make([]string, 0, len(rules.Rules))
+	for key, rule := range rules.Rules {
+		if rule.Resource == res.Type && rule.IsEnabled() {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var results []Result
+	var errs []error
+	for _, key := range keys {
+		result, applies, err := evaluate(rules.Get(key), res)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %s on %s: %v", key, res.Name, err))
+			continue
+		}
+		if applies {
+			results = append(results, result)
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+func evaluate(rule config.Rule, res inventory.Resource) (Result, bool, error) {
+	if rule.When != "" {
+		applies, err := search(rule.When, res.Attributes)
+		if err != nil {
+			return Result{}, false, err
+		}
+		if !truthy(applies) {
+			return Result{}, false, nil
+		}
+	}
+
+	passed, err := search(rule.Assert, res.Attributes)
+	if err != nil {
+		return Result{}, false, err
+	}
+	status := "Fail"
+	if truthy(passed) {
+		status = "Pass"
+	}
+
+	setting := "-"
+	if rule.Setting != "" {
+		value, err := search(rule.Setting, res.Attributes)
+		if err != nil {
+			return Result{}, false, err
+		}
+		setting = format(value)
+	}
+	return Result{Rule: rule, Status: status, Setting: setting}, true, nil
+}
+
+func compile(expr string) (*jmespath.JMESPath, error) {
+	if c, ok := compiled[expr]; ok {
+		return c, nil
+	}
+	c, err := jmespath.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	compiled[expr] = c
+	return c, nil
+}
+
+func search(expr string, data interface{}) (interface{}, error) {
+	c, err := compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return c.Search(data)
+}
+
+// truthy follows the JMESPath definition of false values: false, null, and
+// empty strings, arrays and objects.
+func truthy(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	case string:
+		return t != ""
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map:
+		return rv.Len() > 0
+	}
+	return true
+}
+
+func format(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "-"
+	case string:
+		return t
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,69 @@
+package custom
+
+import (
+	"testing"
+
+	"awsselfrev/internal/config"
+	"awsselfrev/internal/inventory"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluate(t *testing.T) {
+	rules := config.RulesConfig{Rules: map[string]config.Rule{
+		"prod-ecr-immutable": {
+			Service:  "ECR",
+			Level:    "Alert",
+			Resource: inventory.TypeECRRepository,
+			When:     "starts_with(RepositoryName, 'prod-')",
+			Assert:   "ImageTagMutability == 'IMMUTABLE'",
+			Setting:  "ImageTagMutability",
+		},
+		"ecr-scan-on-push": {
+			Service:  "ECR",
+			Level:    "Warning",
+			Resource: inventory.TypeECRRepository,
+			Assert:   "ImageScanningConfiguration.ScanOnPush",
+		},
+		"vpc-not-default": {
+			Service:  "VPC",
+			Level:    "Info",
+			Resource: inventory.TypeVPC,
+			Assert:   "!IsDefault",
+		},
+	}}
+
+	prod := inventory.New(inventory.TypeECRRepository, "prod-api", "", types.Repository{
+		RepositoryName:             aws.String("prod-api"),
+		ImageTagMutability:         types.ImageTagMutabilityMutable,
+		ImageScanningConfiguration: &types.ImageScanningConfiguration{ScanOnPush: true},
+	})
+	results, err := Evaluate(rules, prod)
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, "ecr-scan-on-push", results[0].Rule.Key)
+	assert.Equal(t, "Pass", results[0].Status)
+	assert.Equal(t, "-", results[0].Setting)
+	assert.Equal(t, "prod-ecr-immutable", results[1].Rule.Key)
+	assert.Equal(t, "Fail", results[1].Status)
+	assert.Equal(t, "MUTABLE", results[1].Setting)
+
+	// "when" skips the rule for non-production repositories.
+	dev := inventory.New(inventory.TypeECRRepository, "dev-api", "", types.Repository{
+		RepositoryName: aws.String("dev-api"),
+	})
+	results, err = Evaluate(rules, dev)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, "ecr-scan-on-push", results[0].Rule.Key)
+	assert.Equal(t, "Fail", results[0].Status)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(config.Rule{Resource: inventory.TypeVPC, Assert: "IsDefault"}))
+	assert.Error(t, Validate(config.Rule{Resource: "vpcs", Assert: "IsDefault"}))
+	assert.Error(t, Validate(config.Rule{Resource: inventory.TypeVPC}))
+	assert.Error(t, Validate(config.Rule{Resource: inventory.TypeVPC, Assert: "IsDefault =="}))
+}
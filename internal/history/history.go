@@ -0,0 +1,239 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AllServices is the scope of a run of every service's checks.
+const AllServices = "All Services"
+
+// Run is one recorded run of the checks. Scope is the service checked, or
+// AllServices; only failed results are kept. Filters are the rule and
+// resource filters of the run (e.g. "--rules=s3-encryption"), empty when the
+// run checked its whole scope.
+type Run struct {
+	Time     time.Time `json:"time"`
+	Account  string    `json:"account"`
+	Region   string    `json:"region"`
+	Scope    string    `json:"scope"`
+	Filters  []string  `json:"filters,omitempty"`
+	Findings []Finding `json:"findings"`
+}
+
+// Finding is a failed result.
+type Finding struct {
+	Rule     string `json:"rule"`
+	Service  string `json:"service"`
+	Level    string `json:"level"`
+	Resource string `json:"resource"`
+	Setting  string `json:"setting"`
+}
+
+func (f Finding) key() string {
+	return f.Rule + "\x00" + f.Resource
+}
+
+// covers reports whether the run checked the resources of a finding of the
+// service that was seen in runs with the given scopes. A filtered run may have
+// skipped the rule or the resource, so it covers nothing.
+func (r Run) covers(service string, scopes map[string]bool) bool {
+	if len(r.Filters) > 0 {
+		return false
+	}
+	return r.Scope == AllServices || r.Scope == service || scopes[r.Scope]
+}
+
+// Append adds a run to the JSONL history file, creating it if needed.
+func Append(path string, run Run) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load reads the runs of a history file, oldest first. A missing file is an
+// empty history.
+func Load(path string) ([]Run, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var runs []Run
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var run Run
+		if err := json.Unmarshal(scanner.Bytes(), &run); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		runs = append(runs, run)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].Time.Before(runs[j].Time) })
+	return runs, nil
+}
+
+// Select returns the runs of an account and region; empty values match all.
+func Select(runs []Run, account string, region string) []Run {
+	var out []Run
+	for _, run := range runs {
+		if (account == "" || run.Account == account) && (region == "" || run.Region == region) {
+			out = append(out, run)
+		}
+	}
+	return out
+}
+
+// Period is a row of the trend: the fail count per service at the end of a
+// day, week or month.
+type Period struct {
+	Start  time.Time
+	Counts map[string]int
+}
+
+// PeriodStart returns the start of the day, ISO week or month containing t.
+func PeriodStart(t time.Time, period string) (time.Time, error) {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case "day":
+		return day, nil
+	case "week":
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7), nil
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid period %q (must be day, week or month)", period)
+}
+
+// Trend counts the failed results per service in each period, taking the last
+// run of each scope, filters and account/region in the period, so a filtered
+// run does not replace the full run of its scope. A finding reported by
+// several of those runs is counted once.
+func Trend(runs []Run, period string) ([]Period, error) {
+	type scopeKey struct{ account, region, scope, filters string }
+	var periods []Period
+	last := make(map[scopeKey]Run)
+	var current time.Time
+
+	flush := func() {
+		if len(last) == 0 {
+			return
+		}
+		seen := make(map[string]bool)
+		counts := make(map[string]int)
+		for _, run := range last {
+			for _, f := range run.Findings {
+				id := run.Account + "\x00" + run.Region + "\x00" + f.key()
+				if seen[id] {
+					continue
+				}
+				seen[id] = true
+				counts[f.Service]++
+			}
+		}
+		periods = append(periods, Period{Start: current, Counts: counts})
+		last = make(map[scopeKey]Run)
+	}
+
+	for _, run := range runs {
+		start, err := PeriodStart(run.Time, period)
+		if err != nil {
+			return nil, err
+		}
+		if !start.Equal(current) {
+			flush()
+			current = start
+		}
+		last[scopeKey{run.Account, run.Region, run.Scope, strings.Join(run.Filters, " ")}] = run
+	}
+	flush()
+	return periods, nil
+}
+
+// Lifetime is when a finding appeared and, unless it is still open, when it
+// was resolved. A finding that comes back after being resolved starts a new
+// lifetime.
+type Lifetime struct {
+	Finding
+	Account   string
+	Region    string
+	FirstSeen time.Time
+	LastSeen  time.Time
+	Resolved  *time.Time
+}
+
+// Lifetimes follows each finding through the runs. A finding is resolved by
+// the first later unfiltered run that checked it (a run of all services, of
+// the finding's service, or of a scope that reported it) and did not report
+// it.
+func Lifetimes(runs []Run) []Lifetime {
+	type open struct {
+		index  int
+		scopes map[string]bool
+	}
+	var lifetimes []Lifetime
+	opened := make(map[string]*open)
+
+	for _, run := range runs {
+		reported := make(map[string]bool)
+		for _, f := range run.Findings {
+			id := run.Account + "\x00" + run.Region + "\x00" + f.key()
+			reported[id] = true
+			if o, ok := opened[id]; ok {
+				lifetimes[o.index].LastSeen = run.Time
+				lifetimes[o.index].Finding = f
+				o.scopes[run.Scope] = true
+				continue
+			}
+			lifetimes = append(lifetimes, Lifetime{
+				Finding:   f,
+				Account:   run.Account,
+				Region:    run.Region,
+				FirstSeen: run.Time,
+				LastSeen:  run.Time,
+			})
+			opened[id] = &open{index: len(lifetimes) - 1, scopes: map[string]bool{run.Scope: true}}
+		}
+
+		for id, o := range opened {
+			l := &lifetimes[o.index]
+			if reported[id] || l.Account != run.Account || l.Region != run.Region {
+				continue
+			}
+			if run.covers(l.Service, o.scopes) {
+				resolved := run.Time
+				l.Resolved = &resolved
+				delete(opened, id)
+			}
+		}
+	}
+	return lifetimes
+}
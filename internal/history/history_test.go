@@ -0,0 +1,112 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func day(d int) time.Time {
+	return time.Date(2026, 1, d, 12, 0, 0, 0, time.UTC)
+}
+
+var (
+	bucketPublic = Finding{Rule: "s3-public-access", Service: "S3", Level: "Alert", Resource: "assets", Setting: "Disabled"}
+	bucketLogs   = Finding{Rule: "s3-server-access-logging", Service: "S3", Level: "Warning", Resource: "assets", Setting: "Disabled"}
+	repoScan     = Finding{Rule: "ecr-image-scanning", Service: "ECR", Level: "Warning", Resource: "app", Setting: "Disabled"}
+)
+
+func TestAppendLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+
+	runs, err := Load(path)
+	assert.NoError(t, err)
+	assert.Empty(t, runs)
+
+	second := Run{Time: day(2), Account: "111111111111", Region: "eu-west-1", Scope: "S3", Findings: []Finding{bucketPublic}}
+	first := Run{Time: day(1), Account: "111111111111", Region: "eu-west-1", Scope: AllServices, Findings: []Finding{bucketPublic, repoScan}}
+	assert.NoError(t, Append(path, second))
+	assert.NoError(t, Append(path, first))
+
+	runs, err = Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []Run{first, second}, runs)
+	assert.Len(t, Select(runs, "111111111111", "us-east-1"), 0)
+}
+
+func TestTrend(t *testing.T) {
+	runs := []Run{
+		{Time: day(1), Scope: AllServices, Findings: []Finding{bucketPublic, bucketLogs, repoScan}},
+		{Time: day(20), Scope: AllServices, Findings: []Finding{bucketPublic, repoScan}},
+		// The last run of each scope counts; the finding in both is counted once.
+		{Time: day(20).AddDate(0, 1, 0), Scope: AllServices, Findings: []Finding{bucketPublic}},
+		{Time: day(21).AddDate(0, 1, 0), Scope: "ECR", Findings: []Finding{repoScan}},
+	}
+	periods, err := Trend(runs, "month")
+	assert.NoError(t, err)
+	assert.Equal(t, []Period{
+		{Start: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), Counts: map[string]int{"S3": 1, "ECR": 1}},
+		{Start: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), Counts: map[string]int{"S3": 1, "ECR": 1}},
+	}, periods)
+
+	_, err = Trend(runs, "year")
+	assert.Error(t, err)
+}
+
+func TestTrendFilteredRuns(t *testing.T) {
+	runs := []Run{
+		{Time: day(1), Scope: AllServices, Findings: []Finding{bucketPublic, bucketLogs, repoScan}},
+		// A run of one rule does not replace the full run.
+		{Time: day(2), Scope: AllServices, Filters: []string{"--rules=s3-public-access"}, Findings: []Finding{bucketPublic}},
+	}
+	periods, err := Trend(runs, "month")
+	assert.NoError(t, err)
+	assert.Equal(t, []Period{
+		{Start: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), Counts: map[string]int{"S3": 2, "ECR": 1}},
+	}, periods)
+}
+
+func TestPeriodStartWeek(t *testing.T) {
+	// 2026-01-07 is a Wednesday; weeks start on Monday.
+	start, err := PeriodStart(day(7), "week")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC), start)
+}
+
+func TestLifetimes(t *testing.T) {
+	runs := []Run{
+		{Time: day(1), Scope: AllServices, Findings: []Finding{bucketPublic, repoScan}},
+		// An ECR run does not resolve S3 findings.
+		{Time: day(2), Scope: "ECR", Findings: []Finding{}},
+		{Time: day(3), Scope: "S3", Findings: []Finding{bucketPublic}},
+		{Time: day(4), Scope: AllServices, Findings: []Finding{}},
+		{Time: day(5), Scope: "S3", Findings: []Finding{bucketPublic}},
+	}
+	lifetimes := Lifetimes(runs)
+
+	resolved2, resolved4 := day(2), day(4)
+	assert.Equal(t, []Lifetime{
+		{Finding: bucketPublic, FirstSeen: day(1), LastSeen: day(3), Resolved: &resolved4},
+		{Finding: repoScan, FirstSeen: day(1), LastSeen: day(1), Resolved: &resolved2},
+		{Finding: bucketPublic, FirstSeen: day(5), LastSeen: day(5)},
+	}, lifetimes)
+}
+
+func TestLifetimesFilteredRuns(t *testing.T) {
+	runs := []Run{
+		{Time: day(1), Scope: AllServices, Findings: []Finding{bucketPublic, repoScan}},
+		// Filtered runs report findings but resolve none.
+		{Time: day(2), Scope: AllServices, Filters: []string{"--rules=s3-public-access"}, Findings: []Finding{bucketPublic}},
+		{Time: day(3), Scope: "ECR", Filters: []string{"--resource-filter=other"}, Findings: []Finding{}},
+		{Time: day(4), Scope: "ECR", Findings: []Finding{}},
+	}
+	lifetimes := Lifetimes(runs)
+
+	resolved4 := day(4)
+	assert.Equal(t, []Lifetime{
+		{Finding: bucketPublic, FirstSeen: day(1), LastSeen: day(2)},
+		{Finding: repoScan, FirstSeen: day(1), LastSeen: day(1), Resolved: &resolved4},
+	}, lifetimes)
+}
@@ -0,0 +1,90 @@
+package inventory
+
+import (
+	"encoding/json"
+	"log"
+)
+
+// Resource types produced by the collectors. Custom rules refer to these in
+// their "resource" field.
+const (
+	TypeS3Bucket                 = "s3-bucket"
+	TypeS3AccessPoint            = "s3-access-point"
+	TypeS3MultiRegionAccessPoint = "s3-multi-region-access-point"
+	TypeRDSCluster               = "rds-cluster"
+	TypeRDSInstance              = "rds-instance"
+	TypeVPC                      = "vpc"
+	TypeEC2Volume                = "ec2-volume"
+	TypeEC2Snapshot              = "ec2-snapshot"
+	TypeLoadBalancer             = "elb-load-balancer"
+	TypeCloudFrontDistribution   = "cloudfront-distribution"
+	TypeLogGroup                 = "cloudwatch-log-group"
+	TypeECSCluster               = "ecs-cluster"
+	TypeECSService               = "ecs-service"
+	TypeECSTaskDefinition        = "ecs-task-definition"
+	TypeECRRepository            = "ecr-repository"
+	TypeHostedZone               = "route53-hosted-zone"
+	TypeWebACL                   = "wafv2-web-acl"
+)
+
+var Types = []string{
+	TypeS3Bucket,
+	TypeS3AccessPoint,
+	TypeS3MultiRegionAccessPoint,
+	TypeRDSCluster,
+	TypeRDSInstance,
+	TypeVPC,
+	TypeEC2Volume,
+	TypeEC2Snapshot,
+	TypeLoadBalancer,
+	TypeCloudFrontDistribution,
+	TypeLogGroup,
+	TypeECSCluster,
+	TypeECSService,
+	TypeECSTaskDefinition,
+	TypeECRRepository,
+	TypeHostedZone,
+	TypeWebACL,
+}
+
+func IsType(t string) bool {
+	for _, v := range Types {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Resource is a normalized document describing one collected resource.
+// Attributes holds the API object as plain JSON values, keyed by the SDK field
+// names (e.g. "RepositoryName", "ImageTagMutability").
+type Resource struct {
+	Type       string                 `json:"type"`
+	Name       string                 `json:"name"`
+	ARN        string                 `json:"arn,omitempty"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// New normalizes v, usually an AWS SDK struct or a map of them, into a Resource.
+func New(resourceType string, name string, arn string, v interface{}) Resource {
+	return Resource{
+		Type:       resourceType,
+		Name:       name,
+		ARN:        arn,
+		Attributes: normalize(v),
+	}
+}
+
+func normalize(v interface{}) map[string]interface{} {
+	attrs := make(map[string]interface{})
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Warning: Failed to normalize resource attributes: %v", err)
+		return attrs
+	}
+	if err := json.Unmarshal(data, &attrs); err != nil {
+		log.Printf("Warning: Failed to normalize resource attributes: %v", err)
+	}
+	return attrs
+}
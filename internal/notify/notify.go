@@ -0,0 +1,319 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"awsselfrev/internal/config"
+	"awsselfrev/internal/history"
+)
+
+// Kinds of notifier.
+const (
+	Webhook = "webhook"
+	Slack   = "slack"
+	Teams   = "teams"
+)
+
+// maxListed is the number of findings written out in Slack and Teams
+// messages; the rest are counted.
+const maxListed = 50
+
+// Notifier posts digests to a webhook URL.
+type Notifier struct {
+	Kind string
+	URL  string
+}
+
+// ParseNotifier parses a --notify value, "kind=url" or a bare URL for the
+// generic webhook.
+func ParseNotifier(value string) (Notifier, error) {
+	kind, url, found := strings.Cut(value, "=")
+	if !found || strings.HasPrefix(value, "http") {
+		kind, url = Webhook, value
+	}
+	switch kind {
+	case Webhook, Slack, Teams:
+	default:
+		return Notifier{}, fmt.Errorf("invalid --notify kind %q (must be %s, %s or %s)", kind, Webhook, Slack, Teams)
+	}
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return Notifier{}, fmt.Errorf("invalid --notify URL %q", url)
+	}
+	return Notifier{Kind: kind, URL: url}, nil
+}
+
+// Finding is a failed result.
+type Finding struct {
+	Service  string `json:"service"`
+	Rule     string `json:"rule"`
+	Level    string `json:"level"`
+	Resource string `json:"resource"`
+	Setting  string `json:"setting"`
+	Issue    string `json:"issue"`
+}
+
+func (f Finding) key() string {
+	return f.Rule + "\x00" + f.Resource
+}
+
+// Group is the findings of one level and service.
+type Group struct {
+	Level    string    `json:"level"`
+	Service  string    `json:"service"`
+	Findings []Finding `json:"findings"`
+}
+
+// Digest is the message posted after a run. NewOnly is set when the findings
+// are only those missing from the baseline.
+type Digest struct {
+	Account string    `json:"account"`
+	Region  string    `json:"region"`
+	Scope   string    `json:"scope"`
+	Time    time.Time `json:"time"`
+	NewOnly bool      `json:"new_only"`
+	Total   int       `json:"total"`
+	Groups  []Group   `json:"groups"`
+}
+
+// NewDigest groups the findings by level, most severe first, and service.
+func NewDigest(account string, region string, scope string, newOnly bool, findings []Finding) Digest {
+	d := Digest{Account: account, Region: region, Scope: scope, Time: time.Now().UTC(), NewOnly: newOnly, Total: len(findings), Groups: []Group{}}
+
+	sorted := append([]Finding(nil), findings...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if ra, rb := config.LevelRank(a.Level), config.LevelRank(b.Level); ra != rb {
+			return ra > rb
+		}
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+		if a.Rule != b.Rule {
+			return a.Rule < b.Rule
+		}
+		return a.Resource < b.Resource
+	})
+	for _, f := range sorted {
+		n := len(d.Groups)
+		if n == 0 || d.Groups[n-1].Level != f.Level || d.Groups[n-1].Service != f.Service {
+			d.Groups = append(d.Groups, Group{Level: f.Level, Service: f.Service})
+			n++
+		}
+		d.Groups[n-1].Findings = append(d.Groups[n-1].Findings, f)
+	}
+	return d
+}
+
+// Send posts the digest in the notifier's format.
+func (n Notifier) Send(client *http.Client, d Digest) error {
+	var payload interface{}
+	switch n.Kind {
+	case Slack:
+		payload = map[string]string{"text": d.text("*", "•")}
+	case Teams:
+		payload = teamsCard(d)
+	default:
+		payload = d
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Post(n.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s webhook returned %s: %s", n.Kind, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func (d Digest) title() string {
+	what := "failed checks"
+	if d.NewOnly {
+		what = "new failed checks"
+	}
+	if d.Total == 1 {
+		what = strings.TrimSuffix(what, "s")
+	}
+	title := fmt.Sprintf("awsselfrev: %d %s in account %s", d.Total, what, d.Account)
+	if d.Region != "" {
+		title += " (" + d.Region + ")"
+	}
+	return title
+}
+
+// text renders the digest as lines of Markdown, with bold and bullet as the
+// target's syntax for them.
+func (d Digest) text(bold string, bullet string) string {
+	var b strings.Builder
+	b.WriteString(bold + d.title() + bold + "\n")
+	listed := 0
+	for _, g := range d.Groups {
+		if listed >= maxListed {
+			break
+		}
+		fmt.Fprintf(&b, "\n%s%s: %s%s\n", bold, g.Level, g.Service, bold)
+		for _, f := range g.Findings {
+			if listed >= maxListed {
+				break
+			}
+			fmt.Fprintf(&b, "%s %s (%s) %s: %s\n", bullet, f.Resource, f.Setting, f.Rule, f.Issue)
+			listed++
+		}
+	}
+	if d.Total > listed {
+		fmt.Fprintf(&b, "\n…and %d more\n", d.Total-listed)
+	}
+	return b.String()
+}
+
+// teamsCard wraps the digest in an Adaptive Card message, the format accepted
+// by Teams incoming webhooks and workflows.
+func teamsCard(d Digest) map[string]interface{} {
+	body := []interface{}{
+		map[string]interface{}{"type": "TextBlock", "text": d.title(), "weight": "Bolder", "size": "Medium", "wrap": true},
+	}
+	listed := 0
+	for _, g := range d.Groups {
+		if listed >= maxListed {
+			break
+		}
+		var lines []string
+		for _, f := range g.Findings {
+			if listed >= maxListed {
+				break
+			}
+			lines = append(lines, fmt.Sprintf("- %s (%s) %s: %s", f.Resource, f.Setting, f.Rule, f.Issue))
+			listed++
+		}
+		body = append(body,
+			map[string]interface{}{"type": "TextBlock", "text": g.Level + ": " + g.Service, "weight": "Bolder", "spacing": "Medium", "wrap": true},
+			map[string]interface{}{"type": "TextBlock", "text": strings.Join(lines, "\n"), "wrap": true},
+		)
+	}
+	if d.Total > listed {
+		body = append(body, map[string]interface{}{"type": "TextBlock", "text": fmt.Sprintf("…and %d more", d.Total-listed), "wrap": true})
+	}
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []interface{}{
+			map[string]interface{}{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content": map[string]interface{}{
+					"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+					"type":    "AdaptiveCard",
+					"version": "1.4",
+					"body":    body,
+				},
+			},
+		},
+	}
+}
+
+// Entry is a baseline finding with the account, region and scope of the run
+// that reported it.
+type Entry struct {
+	Account string `json:"account"`
+	Region  string `json:"region"`
+	Scope   string `json:"scope"`
+	Finding
+}
+
+// inScope reports whether a run of the scope in the account and region checks
+// the entry again: a run of all services, of the entry's service or of the
+// scope that reported it.
+func (e Entry) inScope(account, region, scope string) bool {
+	if e.Account != account || e.Region != region {
+		return false
+	}
+	return scope == history.AllServices || e.Scope == scope || e.Service == scope
+}
+
+// LoadBaseline reads the entries saved by SaveBaseline. A missing file is an
+// empty baseline.
+func LoadBaseline(path string) ([]Entry, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return entries, nil
+}
+
+// SaveBaseline writes the entries as the baseline for the next run.
+func SaveBaseline(path string, entries []Entry) error {
+	if entries == nil {
+		entries = []Entry{}
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// UpdateBaseline returns the baseline after a run of the scope in the account
+// and region reported findings. The entries the run checked again are
+// replaced by its findings and the others are kept, so runs of other
+// services, accounts or regions can share a file. A partial run (with rule or
+// resource filters) may have skipped entries, so it only adds its findings.
+func UpdateBaseline(baseline []Entry, account, region, scope string, partial bool, findings []Finding) []Entry {
+	var out []Entry
+	known := make(map[string]bool)
+	for _, e := range baseline {
+		if !partial && e.inScope(account, region, scope) {
+			continue
+		}
+		out = append(out, e)
+		if e.Account == account && e.Region == region {
+			known[e.key()] = true
+		}
+	}
+	for _, f := range findings {
+		if known[f.key()] {
+			continue
+		}
+		known[f.key()] = true
+		out = append(out, Entry{Account: account, Region: region, Scope: scope, Finding: f})
+	}
+	return out
+}
+
+// New returns the findings that are not in the baseline of the account and
+// region, by rule and resource.
+func New(findings []Finding, baseline []Entry, account, region string) []Finding {
+	known := make(map[string]bool)
+	for _, e := range baseline {
+		if e.Account == account && e.Region == region {
+			known[e.key()] = true
+		}
+	}
+	var out []Finding
+	for _, f := range findings {
+		if !known[f.key()] {
+			out = append(out, f)
+		}
+	}
+	return out
+}
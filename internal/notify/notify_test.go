@@ -0,0 +1,126 @@
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	publicDB   = Finding{Service: "RDS", Rule: "rds-public-access", Level: "Alert", Resource: "orders", Setting: "Public", Issue: "Instance is publicly accessible"}
+	publicS3   = Finding{Service: "S3", Rule: "s3-public-access", Level: "Alert", Resource: "assets", Setting: "Disabled", Issue: "Block public access is all off"}
+	scanOnPush = Finding{Service: "ECR", Rule: "ecr-image-scanning", Level: "Warning", Resource: "app", Setting: "Disabled", Issue: "Image scanning is not enabled"}
+)
+
+func TestParseNotifier(t *testing.T) {
+	n, err := ParseNotifier("slack=https://hooks.slack.com/services/T/B/X")
+	assert.NoError(t, err)
+	assert.Equal(t, Notifier{Kind: Slack, URL: "https://hooks.slack.com/services/T/B/X"}, n)
+
+	// A bare URL is a generic webhook, even with "=" in its query.
+	n, err = ParseNotifier("https://example.com/hook?token=abc")
+	assert.NoError(t, err)
+	assert.Equal(t, Notifier{Kind: Webhook, URL: "https://example.com/hook?token=abc"}, n)
+
+	_, err = ParseNotifier("pager=https://example.com")
+	assert.Error(t, err)
+	_, err = ParseNotifier("teams=example.com")
+	assert.Error(t, err)
+}
+
+func TestNewDigest(t *testing.T) {
+	d := NewDigest("123456789012", "eu-west-1", "All Services", false, []Finding{scanOnPush, publicS3, publicDB})
+	assert.Equal(t, 3, d.Total)
+	assert.Equal(t, []Group{
+		{Level: "Alert", Service: "RDS", Findings: []Finding{publicDB}},
+		{Level: "Alert", Service: "S3", Findings: []Finding{publicS3}},
+		{Level: "Warning", Service: "ECR", Findings: []Finding{scanOnPush}},
+	}, d.Groups)
+}
+
+func TestSend(t *testing.T) {
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]interface{}
+		assert.NoError(t, json.Unmarshal(data, &body))
+		bodies = append(bodies, body)
+	}))
+	defer server.Close()
+
+	d := NewDigest("123456789012", "eu-west-1", "RDS", true, []Finding{publicDB})
+	for _, kind := range []string{Webhook, Slack, Teams} {
+		assert.NoError(t, Notifier{Kind: kind, URL: server.URL}.Send(server.Client(), d))
+	}
+
+	assert.Len(t, bodies, 3)
+	assert.Equal(t, float64(1), bodies[0]["total"])
+	assert.Equal(t, true, bodies[0]["new_only"])
+	assert.Equal(t, "*awsselfrev: 1 new failed check in account 123456789012 (eu-west-1)*\n\n"+
+		"*Alert: RDS*\n"+
+		"• orders (Public) rds-public-access: Instance is publicly accessible\n", bodies[1]["text"])
+	assert.Equal(t, "message", bodies[2]["type"])
+	assert.Contains(t, bodies[2]["attachments"].([]interface{})[0].(map[string]interface{})["contentType"], "adaptive")
+}
+
+func TestSendError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_token", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	err := Notifier{Kind: Slack, URL: server.URL}.Send(server.Client(), NewDigest("1", "", "S3", false, []Finding{publicS3}))
+	assert.EqualError(t, err, "slack webhook returned 403 Forbidden: invalid_token")
+}
+
+func TestBaseline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline.json")
+
+	baseline, err := LoadBaseline(path)
+	assert.NoError(t, err)
+	assert.Empty(t, baseline)
+
+	entries := UpdateBaseline(baseline, "111111111111", "eu-west-1", "S3", false, []Finding{publicS3})
+	assert.NoError(t, SaveBaseline(path, entries))
+	baseline, err = LoadBaseline(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []Entry{{Account: "111111111111", Region: "eu-west-1", Scope: "S3", Finding: publicS3}}, baseline)
+
+	// A changed setting does not make a finding new.
+	changed := publicS3
+	changed.Setting = "Partial"
+	assert.Equal(t, []Finding{publicDB}, New([]Finding{changed, publicDB}, baseline, "111111111111", "eu-west-1"))
+	// The baseline of another account does not count.
+	assert.Equal(t, []Finding{changed}, New([]Finding{changed}, baseline, "222222222222", "eu-west-1"))
+}
+
+func TestUpdateBaselineScopes(t *testing.T) {
+	const account, region = "111111111111", "eu-west-1"
+	baseline := UpdateBaseline(nil, account, region, "S3", false, []Finding{publicS3})
+	baseline = UpdateBaseline(baseline, account, region, "RDS", false, []Finding{publicDB})
+	baseline = UpdateBaseline(baseline, account, "us-east-1", "S3", false, []Finding{publicS3})
+
+	// A run of S3 with nothing failed resolves only the S3 entry of its
+	// account and region; the RDS run and the other region keep theirs.
+	baseline = UpdateBaseline(baseline, account, region, "S3", false, nil)
+	assert.Equal(t, []Entry{
+		{Account: account, Region: region, Scope: "RDS", Finding: publicDB},
+		{Account: account, Region: "us-east-1", Scope: "S3", Finding: publicS3},
+	}, baseline)
+
+	// A filtered run only adds.
+	baseline = UpdateBaseline(baseline, account, region, "All Services", true, []Finding{scanOnPush})
+	assert.Len(t, baseline, 3)
+
+	// A full run of all services replaces every entry of its account and region.
+	baseline = UpdateBaseline(baseline, account, region, "All Services", false, []Finding{publicS3})
+	assert.Equal(t, []Entry{
+		{Account: account, Region: "us-east-1", Scope: "S3", Finding: publicS3},
+		{Account: account, Region: region, Scope: "All Services", Finding: publicS3},
+	}, baseline)
+}
@@ -0,0 +1,98 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"awsselfrev/internal/inventory"
+)
+
+// Query is the Rego rule policies define their findings in.
+const Query = "data.awsselfrev.findings"
+
+// OPA is the opa binary used to evaluate policies.
+var OPA = "opa"
+
+// Input is the document policies see as "input".
+type Input struct {
+	Resources []inventory.Resource `json:"resources"`
+}
+
+// Finding is one element of the findings set. Rule is a key in rules.yaml,
+// which provides the service, level, issue and remediation. Status defaults
+// to "Fail" and Setting to "-".
+type Finding struct {
+	Rule     string `json:"rule"`
+	Resource string `json:"resource"`
+	Status   string `json:"status"`
+	Setting  string `json:"setting"`
+}
+
+// Evaluate runs "opa eval" with the policy files or directories in paths
+// against the collected resources and returns the findings.
+func Evaluate(paths []string, resources []inventory.Resource) ([]Finding, error) {
+	input, err := json.Marshal(Input{Resources: resources})
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"eval", "--format", "json", "--stdin-input"}
+	for _, path := range paths {
+		args = append(args, "--data", path)
+	}
+	args = append(args, Query)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(OPA, args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = strings.TrimSpace(stdout.String())
+		}
+		return nil, fmt.Errorf("opa eval failed: %v: %s", err, msg)
+	}
+	return parseResult(stdout.Bytes())
+}
+
+// parseResult reads the output of "opa eval --format json". An undefined
+// query yields no findings.
+func parseResult(data []byte) ([]Finding, error) {
+	var out struct {
+		Result []struct {
+			Expressions []struct {
+				Value []Finding `json:"value"`
+			} `json:"expressions"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("failed to parse opa output (%s must be a set of objects): %v", Query, err)
+	}
+
+	var findings []Finding
+	for _, result := range out.Result {
+		for _, expr := range result.Expressions {
+			for _, f := range expr.Value {
+				if f.Rule == "" {
+					return nil, fmt.Errorf("finding for %q has no rule", f.Resource)
+				}
+				if f.Status == "" {
+					f.Status = "Fail"
+				}
+				if f.Status != "Fail" && f.Status != "Pass" {
+					return nil, fmt.Errorf("finding %s for %q has invalid status %q (must be Fail or Pass)", f.Rule, f.Resource, f.Status)
+				}
+				if f.Setting == "" {
+					f.Setting = "-"
+				}
+				findings = append(findings, f)
+			}
+		}
+	}
+	return findings, nil
+}
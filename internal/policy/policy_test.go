@@ -0,0 +1,32 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseResult(t *testing.T) {
+	out := `{"result":[{"expressions":[{"value":[
+		{"rule":"ecr-prod-immutable-tags","resource":"prod-api","setting":"MUTABLE"},
+		{"rule":"ecr-prod-immutable-tags","resource":"prod-web","status":"Pass"}
+	],"text":"data.awsselfrev.findings"}]}]}`
+
+	findings, err := parseResult([]byte(out))
+	assert.NoError(t, err)
+	assert.Equal(t, []Finding{
+		{Rule: "ecr-prod-immutable-tags", Resource: "prod-api", Status: "Fail", Setting: "MUTABLE"},
+		{Rule: "ecr-prod-immutable-tags", Resource: "prod-web", Status: "Pass", Setting: "-"},
+	}, findings)
+
+	// The query is undefined when no policy defines findings.
+	findings, err = parseResult([]byte(`{}`))
+	assert.NoError(t, err)
+	assert.Empty(t, findings)
+
+	_, err = parseResult([]byte(`{"result":[{"expressions":[{"value":[{"resource":"prod-api"}]}]}]}`))
+	assert.Error(t, err)
+
+	_, err = parseResult([]byte(`{"result":[{"expressions":[{"value":[{"rule":"r","status":"Warn"}]}]}]}`))
+	assert.Error(t, err)
+}
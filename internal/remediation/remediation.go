@@ -0,0 +1,178 @@
+package remediation
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"awsselfrev/internal/config"
+)
+
+// Formats of the generated remediation file.
+const (
+	Terraform = "terraform"
+	AWSCLI    = "awscli"
+)
+
+// ValidateFormat checks an --emit-remediation value.
+func ValidateFormat(format string) error {
+	if format != Terraform && format != AWSCLI {
+		return fmt.Errorf("invalid --emit-remediation value %q (must be %s or %s)", format, Terraform, AWSCLI)
+	}
+	return nil
+}
+
+// DefaultPath is the file written when no path is given.
+func DefaultPath(format string) string {
+	if format == Terraform {
+		return "remediation.tf"
+	}
+	return "remediation.sh"
+}
+
+// Finding is a failed result to write a remediation for.
+type Finding struct {
+	Rule     config.Rule
+	Resource string
+}
+
+// placeholder is a snippet placeholder that identifies the failed resource.
+// When the check reports a name where the CLI needs an ID or ARN, lookup is
+// an AWS CLI command that resolves it.
+type placeholder struct {
+	name   string
+	lookup string
+}
+
+// resourcePlaceholders are tried in order; the first one in a snippet is
+// replaced by the resource. The others are left for the reviewer.
+var resourcePlaceholders = []placeholder{
+	{name: "<access-point>"},
+	{name: "<bucket>"},
+	{name: "<log-group>"},
+	{name: "<repository>"},
+	{name: "<service>"},
+	{name: "<instance>"},
+	{name: "<cluster>"},
+	{name: "<vpc-id>"},
+	{name: "<volume-id>"},
+	{name: "<snapshot-id>"},
+	{name: "<distribution-id>"},
+	{name: "<config-id>"},
+	{name: "<load-balancer-arn>", lookup: "aws elbv2 describe-load-balancers --names %s --query 'LoadBalancers[0].LoadBalancerArn' --output text"},
+	{name: "<hosted-zone-id>", lookup: "aws route53 list-hosted-zones-by-name --dns-name %s --max-items 1 --query 'HostedZones[0].Id' --output text"},
+}
+
+var placeholderPattern = regexp.MustCompile(`<[a-z0-9-]+>`)
+
+// Write writes a reviewable file with the suggested change for each finding:
+// a shell script of AWS CLI commands or Terraform blocks to merge into the
+// configuration. Commands that still have placeholders are commented out.
+func Write(w io.Writer, format string, account string, findings []Finding) error {
+	var b strings.Builder
+	if format == AWSCLI {
+		b.WriteString("#!/bin/sh\n")
+	}
+	fmt.Fprintf(&b, "# Suggested remediations generated by awsselfrev for account %s.\n", account)
+	b.WriteString("# Review every change before applying it.\n")
+	if format == AWSCLI {
+		b.WriteString("set -eu\n")
+	}
+
+	for _, f := range findings {
+		fmt.Fprintf(&b, "\n# %s: %s (%s)\n", f.Rule.Key, f.Rule.Issue, f.Resource)
+		snippet := f.Rule.CLI
+		if format == Terraform {
+			snippet = f.Rule.Terraform
+		}
+		if strings.TrimSpace(snippet) == "" {
+			if f.Rule.Remediation != "" {
+				fmt.Fprintf(&b, "# %s\n", f.Rule.Remediation)
+			}
+			b.WriteString("# No snippet available for this rule.\n")
+			continue
+		}
+
+		snippet = fill(snippet, format, account, f.Resource)
+		remaining := placeholderPattern.FindAllString(snippet, -1)
+		if len(remaining) > 0 {
+			fmt.Fprintf(&b, "# Replace the placeholders: %s\n", strings.Join(unique(remaining), " "))
+		}
+		for _, line := range strings.Split(strings.TrimRight(snippet, "\n"), "\n") {
+			if format == AWSCLI && placeholderPattern.MatchString(line) {
+				line = "# " + line
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// fill replaces the account ID and the placeholder that identifies the
+// resource. Terraform resource labels are named after the resource.
+func fill(snippet string, format string, account string, resource string) string {
+	if account != "" {
+		snippet = strings.ReplaceAll(snippet, "<account-id>", account)
+	}
+	if format == Terraform {
+		snippet = strings.ReplaceAll(snippet, `"this"`, `"`+terraformLabel(resource)+`"`)
+	}
+	if resource == "" || resource == "-" {
+		return snippet
+	}
+
+	for _, p := range resourcePlaceholders {
+		if !strings.Contains(snippet, p.name) {
+			continue
+		}
+		value := resource
+		if format == AWSCLI {
+			value = shellQuote(resource)
+			if p.lookup != "" {
+				value = `"$(` + fmt.Sprintf(p.lookup, value) + `)"`
+			}
+		} else if p.lookup != "" {
+			// Terraform needs the ID itself; leave it to the reviewer.
+			return snippet
+		}
+		return strings.ReplaceAll(snippet, p.name, value)
+	}
+	return snippet
+}
+
+var unsafeShell = regexp.MustCompile(`[^A-Za-z0-9_./:=@%+,-]`)
+
+func shellQuote(s string) string {
+	if s != "" && !unsafeShell.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+var invalidLabel = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+func terraformLabel(resource string) string {
+	label := invalidLabel.ReplaceAllString(resource, "_")
+	if label == "" || label == "_" {
+		return "this"
+	}
+	if label[0] >= '0' && label[0] <= '9' || label[0] == '-' {
+		label = "_" + label
+	}
+	return label
+}
+
+func unique(values []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,68 @@
+package remediation
+
+import (
+	"strings"
+	"testing"
+
+	"awsselfrev/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	retention = config.Rule{
+		Key:   "cloudwatch-retention",
+		Issue: "Retention is set to never expire",
+		CLI:   "aws logs put-retention-policy --log-group-name <log-group> --retention-in-days 365\n",
+	}
+	accessLogging = config.Rule{
+		Key:       "alb-access-logging",
+		Issue:     "Access logs are not enabled",
+		CLI:       "aws elbv2 modify-load-balancer-attributes --load-balancer-arn <load-balancer-arn> --attributes Key=access_logs.s3.enabled,Value=true Key=access_logs.s3.bucket,Value=<log-bucket>\n",
+		Terraform: "resource \"aws_lb\" \"this\" {\n  access_logs {\n    bucket  = \"<log-bucket>\"\n    enabled = true\n  }\n}\n",
+	}
+	publicAccess = config.Rule{
+		Key:       "s3-public-access",
+		Issue:     "Block public access is all off",
+		Terraform: "resource \"aws_s3_bucket_public_access_block\" \"this\" {\n  bucket = \"<bucket>\"\n}\n",
+	}
+)
+
+func TestWriteAWSCLI(t *testing.T) {
+	var b strings.Builder
+	err := Write(&b, AWSCLI, "123456789012", []Finding{
+		{Rule: retention, Resource: "/aws/lambda/my app"},
+		{Rule: accessLogging, Resource: "web"},
+		{Rule: publicAccess, Resource: "assets"},
+	})
+	assert.NoError(t, err)
+	out := b.String()
+
+	assert.True(t, strings.HasPrefix(out, "#!/bin/sh\n"))
+	assert.Contains(t, out, "# cloudwatch-retention: Retention is set to never expire (/aws/lambda/my app)\n"+
+		"aws logs put-retention-policy --log-group-name '/aws/lambda/my app' --retention-in-days 365\n")
+	// The ARN is looked up from the name; the log bucket is left to the reviewer.
+	assert.Contains(t, out, "# Replace the placeholders: <log-bucket>\n"+
+		"# aws elbv2 modify-load-balancer-attributes --load-balancer-arn \"$(aws elbv2 describe-load-balancers --names web")
+	assert.Contains(t, out, "# s3-public-access: Block public access is all off (assets)\n# No snippet available for this rule.\n")
+}
+
+func TestWriteTerraform(t *testing.T) {
+	var b strings.Builder
+	err := Write(&b, Terraform, "123456789012", []Finding{
+		{Rule: publicAccess, Resource: "my.assets"},
+		{Rule: accessLogging, Resource: "web"},
+	})
+	assert.NoError(t, err)
+	out := b.String()
+
+	assert.Contains(t, out, "resource \"aws_s3_bucket_public_access_block\" \"my_assets\" {\n  bucket = \"my.assets\"\n}\n")
+	assert.Contains(t, out, "# Replace the placeholders: <log-bucket>\nresource \"aws_lb\" \"web\" {\n")
+}
+
+func TestValidateFormat(t *testing.T) {
+	assert.NoError(t, ValidateFormat("terraform"))
+	assert.NoError(t, ValidateFormat("awscli"))
+	assert.Error(t, ValidateFormat("cloudformation"))
+	assert.Equal(t, "remediation.sh", DefaultPath(AWSCLI))
+}
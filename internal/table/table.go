@@ -1,40 +1,249 @@
 package table
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"sort"
+	"strings"
+
+	"awsselfrev/internal/color"
+	"awsselfrev/internal/config"
 
 	"github.com/olekukonko/tablewriter"
 )
 
 var FailOnly bool
 
-func SetTable() *tablewriter.Table {
+// ShowRemediation adds a REMEDIATION column for failed checks.
+var ShowRemediation bool
 
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetAutoWrapText(false)
-	table.SetRowLine(true)
-	table.SetHeader([]string{"SERVICE", "STATUS", "LEVEL", "RESOURCE", "SETTING", "ISSUE"})
+// GroupBy and SortBy control the row order at render time.
+// Both are empty by default, which keeps rows in the order they were added.
+var GroupBy string
+var SortBy []string
+
+var orderKeys = []string{"service", "resource", "rule", "level", "status"}
+
+// Row is a single line of check output.
+// RuleKey is empty for placeholder rows such as "No buckets".
+type Row struct {
+	RuleKey     string
+	Service     string
+	Status      string
+	Level       string
+	Resource    string
+	Setting     string
+	Issue       string
+	Remediation string
+}
+
+type Table struct {
+	rows []Row
+}
+
+func SetTable() *Table {
+	return &Table{}
+}
+
+func (t *Table) NumLines() int {
+	return len(t.rows)
+}
+
+// Rows returns a copy of the rows added so far, in the order they were added.
+func (t *Table) Rows() []Row {
+	return append([]Row(nil), t.rows...)
+}
 
-	return table
+// AddRow appends a raw row of SERVICE, STATUS, LEVEL, RESOURCE, SETTING and ISSUE.
+func AddRow(t *Table, row []string) {
+	r := Row{}
+	fields := []*string{&r.Service, &r.Status, &r.Level, &r.Resource, &r.Setting, &r.Issue}
+	for i, v := range row {
+		if i < len(fields) {
+			*fields[i] = v
+		}
+	}
+	appendRow(t, r)
 }
 
-func AddRow(t *tablewriter.Table, row []string) {
-	if FailOnly && len(row) > 1 {
-		status := row[1]
-		if status == "Pass" || status == "-" {
-			return
+// AddResult appends the result of evaluating rule against resource.
+// The level is only reported for failed checks. Results of disabled rules are dropped.
+func AddResult(t *Table, rule config.Rule, status string, resource string, setting string) {
+	if !rule.IsEnabled() {
+		return
+	}
+	level, remediation := "-", "-"
+	if status == "Fail" {
+		level = rule.Level
+		if rule.Remediation != "" {
+			remediation = rule.Remediation
 		}
 	}
-	t.Append(row)
+	appendRow(t, Row{
+		RuleKey:     rule.Key,
+		Service:     rule.Service,
+		Status:      status,
+		Level:       level,
+		Resource:    resource,
+		Setting:     setting,
+		Issue:       rule.Issue,
+		Remediation: remediation,
+	})
+}
+
+func appendRow(t *Table, r Row) {
+	if FailOnly && (r.Status == "Pass" || r.Status == "-") {
+		return
+	}
+	t.rows = append(t.rows, r)
 }
 
-func Render(serviceName string, table *tablewriter.Table) {
-	if table.NumLines() > 0 {
-		table.Render()
-	} else {
+// SetOrder validates and applies the --group-by and --sort flag values.
+func SetOrder(groupBy string, sortBy string) error {
+	if groupBy != "" && !isOrderKey(groupBy) {
+		return fmt.Errorf("invalid --group-by value %q (must be one of %s)", groupBy, strings.Join(orderKeys, ", "))
+	}
+	var keys []string
+	for _, key := range strings.Split(sortBy, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		if !isOrderKey(key) {
+			return fmt.Errorf("invalid --sort value %q (must be one of %s)", key, strings.Join(orderKeys, ", "))
+		}
+		keys = append(keys, key)
+	}
+	GroupBy = groupBy
+	SortBy = keys
+	return nil
+}
+
+func isOrderKey(key string) bool {
+	for _, k := range orderKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Sort orders rows by the group key first and then by each sort key.
+// Rows that compare equal keep the order in which they were added.
+func Sort(rows []Row) {
+	var keys []string
+	if GroupBy != "" {
+		keys = append(keys, GroupBy)
+	}
+	keys = append(keys, SortBy...)
+	if len(keys) == 0 {
+		return
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, key := range keys {
+			a, b := sortValue(rows[i], key), sortValue(rows[j], key)
+			if a != b {
+				return a < b
+			}
+		}
+		return false
+	})
+}
+
+func sortValue(r Row, key string) string {
+	switch key {
+	case "service":
+		return r.Service
+	case "resource":
+		return r.Resource
+	case "rule":
+		return r.RuleKey
+	case "level":
+		// Most severe first
+		switch r.Level {
+		case "Alert":
+			return "0"
+		case "Warning":
+			return "1"
+		case "Info":
+			return "2"
+		default:
+			return "3"
+		}
+	case "status":
+		switch r.Status {
+		case "Fail":
+			return "0"
+		case "Pass":
+			return "1"
+		default:
+			return "2"
+		}
+	}
+	return ""
+}
+
+func Render(serviceName string, t *Table) {
+	if t.NumLines() == 0 {
 		if !FailOnly {
 			log.Println(serviceName + ": No data to render.")
 		}
+		return
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetRowLine(true)
+	table.SetHeader(header())
+
+	// Merge identical cells in the grouped column so each group reads as one block.
+	// The rule key is not shown otherwise, so grouping by rule adds a RULE column.
+	switch GroupBy {
+	case "rule", "service":
+		table.SetAutoMergeCellsByColumnIndex([]int{0})
+	case "status":
+		table.SetAutoMergeCellsByColumnIndex([]int{1})
+	case "level":
+		table.SetAutoMergeCellsByColumnIndex([]int{2})
+	case "resource":
+		table.SetAutoMergeCellsByColumnIndex([]int{3})
+	}
+
+	rows := append([]Row(nil), t.rows...)
+	Sort(rows)
+	for _, r := range rows {
+		table.Append(cells(r))
+	}
+	table.Render()
+}
+
+func header() []string {
+	header := []string{"SERVICE", "STATUS", "LEVEL", "RESOURCE", "SETTING", "ISSUE"}
+	if GroupBy == "rule" {
+		header = append([]string{"RULE"}, header...)
+	}
+	if ShowRemediation {
+		header = append(header, "REMEDIATION")
+	}
+	return header
+}
+
+func cells(r Row) []string {
+	row := []string{r.Service, r.Status, color.ColorizeLevel(r.Level), r.Resource, r.Setting, r.Issue}
+	if GroupBy == "rule" {
+		ruleKey := r.RuleKey
+		if ruleKey == "" {
+			ruleKey = "-"
+		}
+		row = append([]string{ruleKey}, row...)
+	}
+	if ShowRemediation {
+		remediation := r.Remediation
+		if remediation == "" {
+			remediation = "-"
+		}
+		row = append(row, remediation)
 	}
+	return row
 }
@@ -0,0 +1,58 @@
+package table
+
+import (
+	"testing"
+
+	"awsselfrev/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortGroupByResource(t *testing.T) {
+	defer SetOrder("", "")
+
+	tbl := SetTable()
+	AddResult(tbl, config.Rule{Key: "rds-storage-encryption", Service: "RDS", Level: "Alert"}, "Fail", "cluster-1", "Disabled")
+	AddResult(tbl, config.Rule{Key: "rds-public-access", Service: "RDS", Level: "Alert"}, "Pass", "instance-1", "Private")
+	AddResult(tbl, config.Rule{Key: "rds-deletion-protection", Service: "RDS", Level: "Warning"}, "Fail", "cluster-1", "Disabled")
+	AddResult(tbl, config.Rule{Key: "rds-performance-insights", Service: "RDS", Level: "Warning"}, "Fail", "instance-1", "Disabled")
+
+	assert.NoError(t, SetOrder("resource", "level"))
+	rows := append([]Row(nil), tbl.rows...)
+	Sort(rows)
+
+	var got []string
+	for _, r := range rows {
+		got = append(got, r.Resource+"/"+r.RuleKey)
+	}
+	assert.Equal(t, []string{
+		"cluster-1/rds-storage-encryption",
+		"cluster-1/rds-deletion-protection",
+		"instance-1/rds-performance-insights",
+		"instance-1/rds-public-access",
+	}, got)
+}
+
+func TestSetOrderRejectsUnknownKey(t *testing.T) {
+	defer SetOrder("", "")
+
+	assert.Error(t, SetOrder("region", ""))
+	assert.Error(t, SetOrder("", "level,name"))
+	assert.NoError(t, SetOrder("service", "level, resource"))
+	assert.Equal(t, []string{"level", "resource"}, SortBy)
+}
+
+func TestGroupByRuleAddsRuleColumn(t *testing.T) {
+	defer SetOrder("", "")
+
+	rule := config.Rule{Key: "rds-public-access", Service: "RDS", Level: "Alert", Issue: "Publicly accessible"}
+	row := Row{RuleKey: rule.Key, Service: "RDS", Status: "Pass", Level: "-", Resource: "instance-1", Setting: "Private", Issue: rule.Issue}
+
+	assert.Equal(t, "SERVICE", header()[0])
+	assert.Equal(t, "RDS", cells(row)[0])
+
+	assert.NoError(t, SetOrder("rule", ""))
+	assert.Equal(t, []string{"RULE", "SERVICE", "STATUS", "LEVEL", "RESOURCE", "SETTING", "ISSUE"}, header())
+	assert.Equal(t, []string{"rds-public-access", "RDS", "Pass", "-", "instance-1", "Private", "Publicly accessible"}, cells(row))
+	assert.Equal(t, "-", cells(Row{Service: "RDS", Status: "-"})[0])
+}
@@ -0,0 +1,250 @@
+package tfplan
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Resource is a managed resource as it will be after the plan is applied.
+type Resource struct {
+	Address string
+	Type    string
+	Values  map[string]interface{}
+
+	// unknown marks the attributes only known after apply.
+	unknown map[string]interface{}
+	// configAddress is the address without the instance key, as used by
+	// references in the configuration (e.g. module.logs.aws_s3_bucket.this).
+	configAddress string
+	index         string
+	references    map[string][]string
+}
+
+// Plan holds the resources of a "terraform show -json" plan that are
+// created, updated or left unchanged. Resources being deleted are left out.
+type Plan struct {
+	Resources []*Resource
+}
+
+type planJSON struct {
+	ResourceChanges []struct {
+		Address       string          `json:"address"`
+		ModuleAddress string          `json:"module_address"`
+		Mode          string          `json:"mode"`
+		Type          string          `json:"type"`
+		Name          string          `json:"name"`
+		Index         json.RawMessage `json:"index"`
+		Change        struct {
+			Actions      []string               `json:"actions"`
+			After        map[string]interface{} `json:"after"`
+			AfterUnknown map[string]interface{} `json:"after_unknown"`
+		} `json:"change"`
+	} `json:"resource_changes"`
+	Configuration struct {
+		RootModule configModule `json:"root_module"`
+	} `json:"configuration"`
+}
+
+type configModule struct {
+	Resources []struct {
+		Address     string `json:"address"`
+		Expressions map[string]struct {
+			References []string `json:"references"`
+		} `json:"expressions"`
+	} `json:"resources"`
+	ModuleCalls map[string]struct {
+		Module configModule `json:"module"`
+	} `json:"module_calls"`
+}
+
+// Load reads a plan written by "terraform show -json".
+func Load(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse parses the output of "terraform show -json".
+func Parse(data []byte) (*Plan, error) {
+	var raw planJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("not a Terraform JSON plan: %v", err)
+	}
+
+	references := make(map[string]map[string][]string)
+	collectReferences(raw.Configuration.RootModule, "", references)
+
+	plan := &Plan{}
+	for _, rc := range raw.ResourceChanges {
+		if rc.Mode != "managed" || isDelete(rc.Change.Actions) || rc.Change.After == nil {
+			continue
+		}
+		configAddress := rc.Type + "." + rc.Name
+		if rc.ModuleAddress != "" {
+			configAddress = stripIndexes(rc.ModuleAddress) + "." + configAddress
+		}
+		plan.Resources = append(plan.Resources, &Resource{
+			Address:       rc.Address,
+			Type:          rc.Type,
+			Values:        rc.Change.After,
+			unknown:       rc.Change.AfterUnknown,
+			configAddress: configAddress,
+			index:         string(rc.Index),
+			references:    references[configAddress],
+		})
+	}
+	return plan, nil
+}
+
+func isDelete(actions []string) bool {
+	return len(actions) == 1 && actions[0] == "delete"
+}
+
+func collectReferences(module configModule, prefix string, out map[string]map[string][]string) {
+	for _, r := range module.Resources {
+		refs := make(map[string][]string)
+		for attr, expr := range r.Expressions {
+			for _, ref := range expr.References {
+				refs[attr] = append(refs[attr], prefix+ref)
+			}
+		}
+		out[prefix+r.Address] = refs
+	}
+	for name, call := range module.ModuleCalls {
+		collectReferences(call.Module, prefix+"module."+name+".", out)
+	}
+}
+
+var indexPattern = regexp.MustCompile(`\[[^\]]*\]`)
+
+func stripIndexes(address string) string {
+	return indexPattern.ReplaceAllString(address, "")
+}
+
+// OfType returns the resources of the given types, in plan order.
+func (p *Plan) OfType(types ...string) []*Resource {
+	var out []*Resource
+	for _, r := range p.Resources {
+		for _, t := range types {
+			if r.Type == t {
+				out = append(out, r)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// Linked returns the resources of the given type whose attr refers to parent,
+// e.g. the aws_s3_bucket_logging whose "bucket" is a given aws_s3_bucket.
+// A reference matches when the planned value equals one of the parent's
+// identifying values, or when the configuration expression references the
+// parent (the value is often unknown until apply).
+func (p *Plan) Linked(parent *Resource, resourceType string, attr string, parentValues ...string) []*Resource {
+	var out []*Resource
+	for _, r := range p.OfType(resourceType) {
+		if r.refersTo(parent, attr, parentValues) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func (r *Resource) refersTo(parent *Resource, attr string, parentValues []string) bool {
+	if v, ok := r.Values[attr].(string); ok && v != "" {
+		for _, pv := range parentValues {
+			if v == pv {
+				return true
+			}
+		}
+	}
+	for _, ref := range r.references[attr] {
+		ref = stripIndexes(ref)
+		if ref == parent.configAddress || strings.HasPrefix(ref, parent.configAddress+".") {
+			// With count or for_each, both sides usually share the same key.
+			return parent.index == "" || r.index == "" || parent.index == r.index
+		}
+	}
+	return false
+}
+
+// Get walks nested attributes. Nested blocks are lists in the plan; Get
+// descends into their first element.
+func (r *Resource) Get(path ...string) interface{} {
+	var cur interface{} = r.Values
+	for _, key := range path {
+		if list, ok := cur.([]interface{}); ok {
+			if len(list) == 0 {
+				return nil
+			}
+			cur = list[0]
+		}
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = m[key]
+	}
+	return cur
+}
+
+// IsSet reports whether a top-level attribute has a value, or will have one
+// after apply (e.g. a KMS key ARN from a key created in the same plan).
+func (r *Resource) IsSet(attr string) bool {
+	if unknown, _ := r.unknown[attr].(bool); unknown {
+		return true
+	}
+	switch v := r.Values[attr].(type) {
+	case nil:
+		return false
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	}
+	return true
+}
+
+// String returns the attribute as a string, or "" when it is not set.
+func (r *Resource) String(path ...string) string {
+	switch v := r.Get(path...).(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+// Bool returns the attribute as a bool and whether it is set.
+func (r *Resource) Bool(path ...string) (bool, bool) {
+	v, ok := r.Get(path...).(bool)
+	return v, ok
+}
+
+// Int returns the attribute as an int and whether it is set.
+func (r *Resource) Int(path ...string) (int, bool) {
+	v, ok := r.Get(path...).(float64)
+	return int(v), ok
+}
+
+// Tags returns the resource tags.
+func (r *Resource) Tags() map[string]string {
+	tags := make(map[string]string)
+	if m, ok := r.Values["tags"].(map[string]interface{}); ok {
+		for k, v := range m {
+			if s, ok := v.(string); ok {
+				tags[k] = s
+			}
+		}
+	}
+	return tags
+}
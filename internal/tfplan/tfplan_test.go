@@ -0,0 +1,44 @@
+package tfplan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	plan, err := Load("testdata/plan.json")
+	assert.NoError(t, err)
+
+	// Deleted resources and data sources are left out.
+	var addresses []string
+	for _, r := range plan.Resources {
+		addresses = append(addresses, r.Address)
+	}
+	assert.Equal(t, []string{
+		"aws_s3_bucket.logs",
+		"aws_s3_bucket_public_access_block.logs",
+		"module.db.aws_db_instance.main[0]",
+		"aws_s3_bucket_server_side_encryption_configuration.logs",
+		"aws_s3_bucket_ownership_controls.logs",
+		"aws_rds_cluster_instance.aurora[0]",
+	}, addresses)
+
+	bucket := plan.OfType("aws_s3_bucket")[0]
+	assert.Equal(t, "example-logs", bucket.String("bucket"))
+	assert.Equal(t, map[string]string{"Team": "platform"}, bucket.Tags())
+	assert.True(t, bucket.IsSet("arn"))
+	assert.False(t, bucket.IsSet("logging"))
+
+	// The public access block refers to the bucket only through the configuration.
+	assert.Len(t, plan.Linked(bucket, "aws_s3_bucket_public_access_block", "bucket", "example-logs"), 1)
+	assert.Empty(t, plan.Linked(bucket, "aws_s3_bucket_logging", "bucket", "example-logs"))
+
+	db := plan.OfType("aws_db_instance")[0]
+	deletionProtection, ok := db.Bool("deletion_protection")
+	assert.True(t, ok)
+	assert.False(t, deletionProtection)
+	_, ok = db.Bool("publicly_accessible")
+	assert.False(t, ok)
+	assert.True(t, db.IsSet("kms_key_id"))
+}